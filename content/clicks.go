@@ -0,0 +1,68 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"time"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// ClickCounter counts successive presses of a mouse button to set the
+// ClickCount of mouse Events. The zero ClickCounter uses
+// DOUBLE_CLICK_INTERVAL and DOUBLE_CLICK_SLOP.
+type ClickCounter struct {
+	// The longest time and the largest distance in pixels between presses
+	// that make up a multiple click.
+	Interval time.Duration
+	Slop     float32
+
+	// The most recent press.
+	count  int
+	button uint32
+	at     graphics.Pointf
+	time   time.Duration
+}
+
+// Press records a press of button at p at time t and returns its click
+// count.
+func (c *ClickCounter) Press(button uint32, p graphics.Pointf, t time.Duration) int {
+	interval, slop := c.Interval, c.Slop
+	if interval == 0 {
+		interval = DOUBLE_CLICK_INTERVAL
+	}
+	if slop == 0 {
+		slop = DOUBLE_CLICK_SLOP
+	}
+
+	d := p.Sub(c.at)
+	if c.count > 0 && button == c.button && t-c.time <= interval &&
+		graphics.MaxF(graphics.AbsF(d.X), graphics.AbsF(d.Y)) <= slop {
+		c.count++
+	} else {
+		c.count = 1
+	}
+	c.button, c.at, c.time = button, p, t
+	return c.count
+}
+
+// Release returns the click count of a release of button: that of the
+// press it ends.
+func (c *ClickCounter) Release(button uint32) int {
+	if button == c.button && c.count > 0 {
+		return c.count
+	}
+	return 1
+}
@@ -0,0 +1,39 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func Test_ClickCounter(t *testing.T) {
+	var c ClickCounter
+	ms := time.Millisecond
+	testhelpers.AssertInt(t, 1, c.Press(0, graphics.Ptf(10, 10), 0))
+	testhelpers.AssertInt(t, 2, c.Press(0, graphics.Ptf(12, 13), 100*ms))
+	testhelpers.AssertInt(t, 2, c.Release(0))
+	testhelpers.AssertInt(t, 1, c.Release(2))
+	// Too late, too far and another button.
+	testhelpers.AssertInt(t, 1, c.Press(0, graphics.Ptf(12, 13), 700*ms))
+	testhelpers.AssertInt(t, 1, c.Press(0, graphics.Ptf(20, 13), 800*ms))
+	testhelpers.AssertInt(t, 1, c.Press(2, graphics.Ptf(20, 13), 900*ms))
+
+	c.Interval, c.Slop = time.Second, 20
+	testhelpers.AssertInt(t, 2, c.Press(2, graphics.Ptf(35, 13), 1800*ms))
+}
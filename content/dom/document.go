@@ -0,0 +1,231 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"fmt"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// ElementID names an element for the lifetime of its Document. IDs are
+// handed out in increasing order and are never reused, even after the
+// element that held one has been deleted.
+type ElementID uint32
+
+// NO_ELEMENT is never assigned to an element. It names the document's root
+// group where a parent is expected.
+const NO_ELEMENT ElementID = 0
+
+// Document is the element store: a tree of elements under a root group
+// together with an index from ID to element. Children are kept in paint
+// order (the last child is drawn on top) and elements are held by reference
+// so that references kept elsewhere (e.g. by the hover and drag state in
+// Frame) stay valid as the document grows, shrinks or is reordered.
+type Document struct {
+	root   *GroupElement
+	byId   map[ElementID]Element
+	nextId ElementID
+}
+
+func NewDocument() *Document {
+	return &Document{
+		root:   NewGroupElement(),
+		byId:   make(map[ElementID]Element),
+		nextId: NO_ELEMENT + 1,
+	}
+}
+
+// Root returns the group holding the top-level elements. It has no ID.
+func (d *Document) Root() *GroupElement {
+	return d.root
+}
+
+// register assigns IDs to e and its descendants. Elements keep an ID they
+// already have unless it belongs to something else in the document, so a
+// deleted element that is put back is found under its old ID.
+func (d *Document) register(e Element) {
+	Walk(e, func(e Element) {
+		n := e.node()
+		if other, ok := d.byId[n.id]; n.id == NO_ELEMENT || ok && other != e {
+			n.id = d.nextId
+		}
+		if n.id >= d.nextId {
+			d.nextId = n.id + 1
+		}
+		d.byId[n.id] = e
+	})
+}
+
+func (d *Document) unregister(e Element) {
+	Walk(e, func(e Element) {
+		delete(d.byId, e.ID())
+	})
+}
+
+// group returns the group with the given id, the root for NO_ELEMENT.
+func (d *Document) group(id ElementID) (*GroupElement, error) {
+	if id == NO_ELEMENT {
+		return d.root, nil
+	}
+	g, ok := d.byId[id].(*GroupElement)
+	if !ok {
+		return nil, fmt.Errorf("dom: %d is not a group", id)
+	}
+	return g, nil
+}
+
+// Add appends e on top of the document and returns its newly assigned ID.
+// Descendants of e are also given IDs.
+func (d *Document) Add(e Element) ElementID {
+	d.root.Append(e)
+	d.register(e)
+	return e.ID()
+}
+
+// Insert makes e the i-th child of the group with the given id and returns
+// e's ID. Use NO_ELEMENT to insert into the root.
+func (d *Document) Insert(parent ElementID, i int, e Element) (ElementID, error) {
+	g, err := d.group(parent)
+	if err != nil {
+		return NO_ELEMENT, err
+	}
+	g.Insert(i, e)
+	d.register(e)
+	return e.ID(), nil
+}
+
+// Len returns the number of top-level elements in the document.
+func (d *Document) Len() int {
+	return d.root.Len()
+}
+
+// At returns the top-level element at paint order index i.
+func (d *Document) At(i int) Element {
+	return d.root.At(i)
+}
+
+// ElementsIn returns the top-level elements whose bounds lie within r in
+// paint order. r is in document coordinates.
+func (d *Document) ElementsIn(r graphics.Rectanglef) []Element {
+	var in []Element
+	for _, e := range d.root.children {
+		b := LocalToDocument(e).TransformRect(e.Bounds())
+		if r.Min.X <= b.Min.X && b.Max.X <= r.Max.X && r.Min.Y <= b.Min.Y && b.Max.Y <= r.Max.Y {
+			in = append(in, e)
+		}
+	}
+	return in
+}
+
+// Get returns the element with the given id or nil if there is none.
+func (d *Document) Get(id ElementID) Element {
+	return d.byId[id]
+}
+
+// IndexOf returns the paint order index of the element with the given id
+// amongst its siblings or -1 if the document doesn't contain it.
+func (d *Document) IndexOf(id ElementID) int {
+	e, ok := d.byId[id]
+	if !ok {
+		return -1
+	}
+	return e.Parent().IndexOf(e)
+}
+
+// Delete removes the element with the given id and its descendants. The
+// element itself is untouched so callers holding it may keep using it.
+// Returns the removed element or nil if there was no such element.
+func (d *Document) Delete(id ElementID) Element {
+	e, ok := d.byId[id]
+	if !ok {
+		return nil
+	}
+	e.Parent().Remove(e)
+	d.unregister(e)
+	return e
+}
+
+// MoveTo reorders the element with the given id to paint order index i
+// amongst its siblings, shifting the siblings in between. i is clamped to
+// the valid range. Returns false if there is no such element.
+func (d *Document) MoveTo(id ElementID, i int) bool {
+	e, ok := d.byId[id]
+	if !ok {
+		return false
+	}
+	p := e.Parent()
+	p.Remove(e)
+	p.Insert(i, e)
+	return true
+}
+
+// Group moves the elements with the given ids into a new group and returns
+// its ID. The elements must be siblings. The group takes the place of the
+// topmost of them and keeps them in their existing paint order.
+func (d *Document) Group(ids ...ElementID) (ElementID, error) {
+	if len(ids) == 0 {
+		return NO_ELEMENT, fmt.Errorf("dom: nothing to group")
+	}
+	var p *GroupElement
+	members := make(map[Element]bool)
+	for _, id := range ids {
+		e, ok := d.byId[id]
+		if !ok {
+			return NO_ELEMENT, fmt.Errorf("dom: no element %d", id)
+		}
+		if p != nil && e.Parent() != p {
+			return NO_ELEMENT, fmt.Errorf("dom: can only group siblings")
+		}
+		p = e.Parent()
+		members[e] = true
+	}
+
+	g := NewGroupElement()
+	top := 0
+	for i := 0; i < p.Len(); {
+		if c := p.At(i); members[c] {
+			top = i
+			g.Append(c)
+		} else {
+			i++
+		}
+	}
+	p.Insert(top, g)
+	d.register(g)
+	return g.ID(), nil
+}
+
+// Ungroup replaces the group with the given id by its children. The group's
+// transform is folded into the children but its opacity and clip are lost.
+func (d *Document) Ungroup(id ElementID) error {
+	g, err := d.group(id)
+	if err != nil {
+		return err
+	}
+	if g == d.root {
+		return fmt.Errorf("dom: can't ungroup the root")
+	}
+	p := g.Parent()
+	i := p.Remove(g)
+	for g.Len() > 0 {
+		c := g.At(0)
+		c.ApplyTransform(g.transform)
+		p.Insert(i, c)
+		i++
+	}
+	delete(d.byId, id)
+	return nil
+}
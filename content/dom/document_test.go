@@ -0,0 +1,116 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func newQuad(x, y float32) *QuadElement {
+	qe := new(QuadElement)
+	qe.Init(graphics.Pointf{x, y})
+	return qe
+}
+
+func order(d *Document) []int {
+	ids := make([]int, d.Len())
+	for i := range ids {
+		ids[i] = int(d.At(i).ID())
+	}
+	return ids
+}
+
+func assertOrder(t *testing.T, d *Document, expected ...int) {
+	actual := order(d)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected order %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expected order %v, got %v", expected, actual)
+			return
+		}
+	}
+}
+
+func Test_DocumentIds(t *testing.T) {
+	d := NewDocument()
+	a := newQuad(0, 0)
+	b := newQuad(10, 10)
+	testhelpers.AssertInt(t, int(NO_ELEMENT), int(a.ID()))
+
+	ia := d.Add(a)
+	ib := d.Add(b)
+	if ia == NO_ELEMENT || ia == ib {
+		t.Errorf("bad ids %d %d", ia, ib)
+	}
+	if d.Get(ia) != a || d.Get(ib) != b {
+		t.Errorf("Get doesn't return the added elements")
+	}
+
+	if d.Delete(ia) != a {
+		t.Errorf("Delete didn't return the deleted element")
+	}
+	if d.Get(ia) != nil || d.IndexOf(ia) != -1 || d.Delete(ia) != nil {
+		t.Errorf("deleted element still present")
+	}
+
+	// IDs are not recycled.
+	ic := d.Add(newQuad(20, 20))
+	if ic == ia || ic == ib {
+		t.Errorf("id %d reused", ic)
+	}
+	assertOrder(t, d, int(ib), int(ic))
+}
+
+func Test_DocumentMoveTo(t *testing.T) {
+	d := NewDocument()
+	for i := 0; i < 4; i++ {
+		d.Add(newQuad(0, 0))
+	}
+	assertOrder(t, d, 1, 2, 3, 4)
+
+	d.MoveTo(1, 3)
+	assertOrder(t, d, 2, 3, 4, 1)
+	d.MoveTo(4, 0)
+	assertOrder(t, d, 4, 2, 3, 1)
+	d.MoveTo(2, 100)
+	assertOrder(t, d, 4, 3, 1, 2)
+	d.MoveTo(2, -1)
+	assertOrder(t, d, 2, 4, 3, 1)
+
+	if d.MoveTo(99, 0) {
+		t.Errorf("moved a non-existent element")
+	}
+}
+
+func Test_ElementsIn(t *testing.T) {
+	d := NewDocument()
+	a := d.Add(newQuad(100, 100))
+	d.Add(newQuad(300, 100))
+	g := NewGroupElement()
+	g.Append(newQuad(0, 0))
+	g.SetTransform(graphics.Translate(graphics.Pointf{100, 300}))
+	ig := d.Add(g)
+
+	in := d.ElementsIn(graphics.Rect(0, 0, 200, 400))
+	if len(in) != 2 || in[0].ID() != a || in[1].ID() != ig {
+		t.Errorf("bad elements in rect: %v", in)
+	}
+	testhelpers.AssertInt(t, 0, len(d.ElementsIn(graphics.Rect(60, 60, 140, 140))))
+}
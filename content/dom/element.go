@@ -0,0 +1,143 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// Element is the interface implemented by everything that can be held in a
+// Document. Implementations embed Node.
+type Element interface {
+	// ID returns the identifier assigned by the Document holding the element
+	// or NO_ELEMENT if it has never been added to one.
+	ID() ElementID
+
+	// Kind names the element's type in serialized documents. It must be
+	// registered with RegisterKind.
+	Kind() string
+
+	// Draw appends the element to the display list.
+	Draw(dl *graphics.DisplayList)
+
+	// Bounds returns the smallest rectangle enclosing the element's geometry.
+	Bounds() graphics.Rectanglef
+
+	// HitTest returns the element under p and the index of the control point
+	// under p. It returns nil, -1 if p misses. pixel is the size of a screen
+	// pixel in the element's coordinates so that control points can be hit
+	// at a constant screen size.
+	HitTest(p graphics.Pointf, pixel float32) (Element, int)
+
+	// ApplyTransform maps the element's geometry through m.
+	ApplyTransform(m graphics.Matrix)
+
+	// Parent returns the group containing the element or nil if the element
+	// is not in a tree.
+	Parent() *GroupElement
+
+	// Hover and activation state of the control points.
+	HoverOn(v int)
+	HoverOff()
+	ActivateVertex(v int) graphics.Pointf
+	SetActiveVertex(p graphics.Pointf)
+	Deactivate()
+
+	// Event listeners. See Dispatch.
+	AddEventListener(typ string, capture bool, l Listener) ListenerHandle
+	RemoveEventListener(h ListenerHandle)
+
+	// Serialization of the element's own state (not its ID or listeners.)
+	json.Marshaler
+	json.Unmarshaler
+
+	node() *Node
+}
+
+// Node holds the state common to all Elements. Embed it to implement Element.
+type Node struct {
+	id        ElementID
+	parent    *GroupElement
+	listeners []listenerEntry
+}
+
+// ID returns the identifier assigned by the Document holding the element or
+// NO_ELEMENT if it has never been added to one.
+func (n *Node) ID() ElementID {
+	return n.id
+}
+
+func (n *Node) Parent() *GroupElement {
+	return n.parent
+}
+
+func (n *Node) node() *Node {
+	return n
+}
+
+var kinds = make(map[string]func() Element)
+
+// RegisterKind makes a new kind of Element known to NewElement. It is meant
+// to be called from init functions and panics if kind is registered twice.
+func RegisterKind(kind string, alloc func() Element) {
+	if _, ok := kinds[kind]; ok {
+		panic("dom: element kind registered twice: " + kind)
+	}
+	kinds[kind] = alloc
+}
+
+// NewElement returns a new zero Element of the given kind.
+func NewElement(kind string) (Element, error) {
+	alloc, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("dom: unknown element kind %q", kind)
+	}
+	return alloc(), nil
+}
+
+// taggedElement is the serialized form of an Element of any kind.
+type taggedElement struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// MarshalElement serializes e together with its kind so that
+// UnmarshalElement can recreate it without knowing its type.
+func MarshalElement(e Element) ([]byte, error) {
+	data, err := e.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(taggedElement{e.Kind(), data})
+}
+
+// UnmarshalElement recreates an Element serialized by MarshalElement.
+func UnmarshalElement(b []byte) (Element, error) {
+	var te taggedElement
+	if err := json.Unmarshal(b, &te); err != nil {
+		return nil, err
+	}
+	e, err := NewElement(te.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.UnmarshalJSON(te.Data); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
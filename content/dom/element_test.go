@@ -0,0 +1,105 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// markElement is a minimal second kind of Element: a point that can be hit
+// but has no control points.
+type markElement struct {
+	Node
+	At graphics.Pointf
+}
+
+func init() {
+	RegisterKind("mark", func() Element { return new(markElement) })
+}
+
+func (m *markElement) Kind() string                  { return "mark" }
+func (m *markElement) Draw(dl *graphics.DisplayList) { dl.DrawPoints([]graphics.Pointf{m.At}) }
+func (m *markElement) Bounds() graphics.Rectanglef   { return graphics.Rectanglef{m.At, m.At} }
+func (m *markElement) HitTest(p graphics.Pointf, pixel float32) (Element, int) {
+	if p.Eq(m.At) {
+		return m, -1
+	}
+	return nil, -1
+}
+func (m *markElement) ApplyTransform(t graphics.Matrix)     { m.At = t.Transform(m.At) }
+func (m *markElement) HoverOn(v int)                        {}
+func (m *markElement) HoverOff()                            {}
+func (m *markElement) ActivateVertex(v int) graphics.Pointf { return m.At }
+func (m *markElement) SetActiveVertex(p graphics.Pointf)    { m.At = p }
+func (m *markElement) Deactivate()                          {}
+func (m *markElement) MarshalJSON() ([]byte, error)         { return json.Marshal(m.At) }
+func (m *markElement) UnmarshalJSON(b []byte) error         { return json.Unmarshal(b, &m.At) }
+
+func Test_HeterogeneousDocument(t *testing.T) {
+	d := NewDocument()
+	d.Add(newQuad(50, 50))
+	d.Add(&markElement{At: graphics.Pointf{50 - QUAD_ELEMENT_DX, 50 - QUAD_ELEMENT_DY}})
+
+	if e, _ := d.At(1).HitTest(graphics.Pointf{5, 5}, 1); e != d.At(1) {
+		t.Errorf("mark element missed")
+	}
+	if e, v := d.At(0).HitTest(graphics.Pointf{5, 5}, 1); e != d.At(0) || v != 0 {
+		t.Errorf("quad element missed: %v %d", e, v)
+	}
+
+	dl := &graphics.DisplayList{}
+	for i := 0; i < d.Len(); i++ {
+		d.At(i).Draw(dl)
+	}
+	if dl.W < 95 || dl.H < 95 {
+		t.Errorf("display list has wrong extent %f %f", dl.W, dl.H)
+	}
+}
+
+func Test_MarshalElement(t *testing.T) {
+	qe := newQuad(100, 200)
+	qe.HoverOn(1)
+	qe.ActivateVertex(1)
+	qe.SetActiveVertex(graphics.Pointf{1, 2})
+
+	b, err := MarshalElement(qe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := UnmarshalElement(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nqe, ok := e.(*QuadElement)
+	if !ok {
+		t.Fatalf("unmarshalled the wrong kind of element: %T", e)
+	}
+	if nqe.vertices != qe.vertices || nqe.color != qe.color {
+		t.Errorf("round trip mismatch: %+v vs %+v", nqe, qe)
+	}
+	if nqe.hoverMode != VERTEX_NON || nqe.activeVertex != -1 {
+		t.Errorf("transient state was serialized: %+v", nqe)
+	}
+	if r := nqe.Bounds(); !r.Eq(graphics.Rect(1, 2, 145, 245)) {
+		t.Errorf("bad bounds %v", r)
+	}
+
+	if _, err := UnmarshalElement([]byte(`{"kind":"blob","data":{}}`)); err == nil {
+		t.Errorf("unmarshalled an unknown kind")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"time"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// Event types, named as in the DOM.
+const (
+	EVENT_BLUR      = "blur"
+	EVENT_FOCUS     = "focus"
+	EVENT_KEYDOWN   = "keydown"
+	EVENT_KEYUP     = "keyup"
+	EVENT_MOUSEDOWN = "mousedown"
+	EVENT_MOUSEMOVE = "mousemove"
+	EVENT_MOUSEUP   = "mouseup"
+	EVENT_TEXTINPUT = "textinput"
+	EVENT_WHEEL     = "wheel"
+)
+
+// The phases of an event's propagation, numbered as in the DOM.
+const (
+	PHASE_NONE = iota
+	PHASE_CAPTURING
+	PHASE_AT_TARGET
+	PHASE_BUBBLING
+)
+
+// Event is delivered to listeners by Dispatch. Listeners may modify it to
+// stop its propagation or to prevent its default action.
+type Event struct {
+	Type string
+
+	// The element the event is aimed at and the element whose listeners are
+	// currently running.
+	Target, CurrentTarget Element
+	Phase                 int
+
+	// The pointer position in document coordinates.
+	Point graphics.Pointf
+
+	// As for content.EventHandler.
+	Button, Buttons uint32
+	DX, DY, DZ      float32
+	Key             int
+	Modifiers       uint32
+	Repeat          bool
+	Text            rune
+
+	// The click count of mouse button events, as in the DOM.
+	Detail int
+
+	// When the event happened, measured from an arbitrary start.
+	Time time.Duration
+
+	defaultPrevented bool
+	stopped          bool
+}
+
+// PreventDefault asks that the default action for the event not happen.
+func (ev *Event) PreventDefault() {
+	ev.defaultPrevented = true
+}
+
+func (ev *Event) DefaultPrevented() bool {
+	return ev.defaultPrevented
+}
+
+// StopPropagation stops the event from reaching elements further along its
+// path once the listeners of the current element have run.
+func (ev *Event) StopPropagation() {
+	ev.stopped = true
+}
+
+// LocalPoint returns the pointer position in the coordinates of the current
+// target's geometry.
+func (ev *Event) LocalPoint() graphics.Pointf {
+	if ev.CurrentTarget == nil {
+		return ev.Point
+	}
+	m, _ := LocalToDocument(ev.CurrentTarget).Invert()
+	return m.Transform(ev.Point)
+}
+
+// A Listener handles events delivered to an element.
+type Listener func(ev *Event)
+
+// ListenerHandle identifies a registered listener for removal.
+type ListenerHandle int
+
+type listenerEntry struct {
+	handle  ListenerHandle
+	typ     string
+	capture bool
+	fn      Listener
+}
+
+var nextListenerHandle ListenerHandle
+
+// AddEventListener registers l for events of type typ delivered to this
+// element. Capturing listeners run as the event travels down the tree
+// towards its target, others when it arrives at the target or bubbles back
+// up.
+func (n *Node) AddEventListener(typ string, capture bool, l Listener) ListenerHandle {
+	nextListenerHandle++
+	n.listeners = append(n.listeners, listenerEntry{nextListenerHandle, typ, capture, l})
+	return nextListenerHandle
+}
+
+// RemoveEventListener unregisters the listener with handle h.
+func (n *Node) RemoveEventListener(h ListenerHandle) {
+	for i, le := range n.listeners {
+		if le.handle == h {
+			n.listeners = append(n.listeners[:i], n.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// invoke runs the listeners of e matching ev. Returns true if there were
+// any.
+func invoke(e Element, ev *Event, capture bool) bool {
+	ev.CurrentTarget = e
+	ran := false
+	// Copy so that listeners may add or remove listeners.
+	for _, le := range append([]listenerEntry(nil), e.node().listeners...) {
+		if le.typ == ev.Type && (ev.Phase == PHASE_AT_TARGET || le.capture == capture) {
+			le.fn(ev)
+			ran = true
+		}
+	}
+	return ran
+}
+
+// Dispatch delivers ev to target: first to capturing listeners from the
+// root of target's tree downwards, then to target's own listeners and
+// finally to bubbling listeners back up to the root. Returns true if at
+// least one listener ran. Use ev.DefaultPrevented to find out if the
+// default action should be suppressed.
+func Dispatch(target Element, ev *Event) bool {
+	path := []Element{}
+	for e := parentElement(target); e != nil; e = parentElement(e) {
+		path = append(path, e)
+	}
+	ev.Target = target
+	ran := false
+
+	ev.Phase = PHASE_CAPTURING
+	for i := len(path) - 1; i >= 0 && !ev.stopped; i-- {
+		ran = invoke(path[i], ev, true) || ran
+	}
+
+	if !ev.stopped {
+		ev.Phase = PHASE_AT_TARGET
+		ran = invoke(target, ev, false) || ran
+	}
+
+	ev.Phase = PHASE_BUBBLING
+	for i := 0; i < len(path) && !ev.stopped; i++ {
+		ran = invoke(path[i], ev, false) || ran
+	}
+
+	ev.Phase = PHASE_NONE
+	ev.CurrentTarget = nil
+	return ran
+}
@@ -0,0 +1,108 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+// tree builds root > g > qe and logs the listeners run into log.
+func tree(log *[]string) (*Document, *GroupElement, *QuadElement) {
+	d := NewDocument()
+	g := NewGroupElement()
+	qe := newQuad(50, 50)
+	g.Append(qe)
+	d.Add(g)
+
+	for name, e := range map[string]Element{"root": d.Root(), "g": g, "qe": qe} {
+		name := name
+		for _, capture := range []bool{true, false} {
+			capture := capture
+			e.AddEventListener(EVENT_MOUSEDOWN, capture, func(ev *Event) {
+				*log = append(*log, fmt.Sprintf("%s:%d:%t", name, ev.Phase, capture))
+			})
+		}
+	}
+	return d, g, qe
+}
+
+func Test_DispatchOrder(t *testing.T) {
+	var log []string
+	_, _, qe := tree(&log)
+
+	ev := &Event{Type: EVENT_MOUSEDOWN}
+	if !Dispatch(qe, ev) {
+		t.Errorf("Dispatch didn't find listeners")
+	}
+	testhelpers.AssertString(t,
+		"root:1:true g:1:true qe:2:true qe:2:false g:3:false root:3:false",
+		strings.Join(log, " "))
+	if ev.Target != qe || ev.CurrentTarget != nil || ev.Phase != PHASE_NONE {
+		t.Errorf("event not reset after dispatch: %+v", ev)
+	}
+
+	if Dispatch(qe, &Event{Type: EVENT_MOUSEUP}) {
+		t.Errorf("ran listeners for the wrong type")
+	}
+}
+
+func Test_DispatchStopAndPrevent(t *testing.T) {
+	var log []string
+	_, g, qe := tree(&log)
+	h := g.AddEventListener(EVENT_MOUSEDOWN, true, func(ev *Event) {
+		ev.StopPropagation()
+		ev.PreventDefault()
+	})
+
+	ev := &Event{Type: EVENT_MOUSEDOWN}
+	Dispatch(qe, ev)
+	// All of g's listeners run even though the first one stops propagation.
+	testhelpers.AssertString(t, "root:1:true g:1:true", strings.Join(log, " "))
+	if !ev.DefaultPrevented() {
+		t.Errorf("default not prevented")
+	}
+
+	log = nil
+	g.RemoveEventListener(h)
+	ev = &Event{Type: EVENT_MOUSEDOWN}
+	Dispatch(g, ev)
+	testhelpers.AssertString(t, "root:1:true g:2:true g:2:false root:3:false", strings.Join(log, " "))
+	if ev.DefaultPrevented() {
+		t.Errorf("default prevented by a removed listener")
+	}
+}
+
+func Test_EventLocalPoint(t *testing.T) {
+	d := NewDocument()
+	g := NewGroupElement()
+	g.SetTransform(graphics.Translate(graphics.Pointf{10, 0}))
+	qe := newQuad(50, 50)
+	g.Append(qe)
+	d.Add(g)
+
+	var local graphics.Pointf
+	qe.AddEventListener(EVENT_MOUSEMOVE, false, func(ev *Event) {
+		local = ev.LocalPoint()
+	})
+	Dispatch(qe, &Event{Type: EVENT_MOUSEMOVE, Point: graphics.Pointf{15, 5}})
+	if !local.Eq(graphics.Pointf{5, 5}) {
+		t.Errorf("bad local point %v", local)
+	}
+}
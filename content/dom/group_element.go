@@ -0,0 +1,362 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/google/gojiraw/graphics/drawop"
+)
+
+const GROUP_ELEMENT_KIND = "group"
+
+func init() {
+	RegisterKind(GROUP_ELEMENT_KIND, func() Element { return NewGroupElement() })
+}
+
+// GroupElement is a container of child elements. Children are positioned in
+// the group's local coordinates, which the group's transform maps into the
+// coordinates of its parent. Children are drawn in order, so the last child
+// is on top.
+type GroupElement struct {
+	Node
+	children  []Element
+	transform graphics.Matrix
+	opacity   float32
+
+	// The clip rectangle in local coordinates.
+	clip    graphics.Rectanglef
+	clipped bool
+}
+
+func NewGroupElement() *GroupElement {
+	return &GroupElement{transform: graphics.Identity(), opacity: 1}
+}
+
+func (g *GroupElement) Kind() string {
+	return GROUP_ELEMENT_KIND
+}
+
+// Len returns the number of children.
+func (g *GroupElement) Len() int {
+	return len(g.children)
+}
+
+// At returns the i-th child.
+func (g *GroupElement) At(i int) Element {
+	return g.children[i]
+}
+
+// IndexOf returns the index of child e or -1 if e isn't a child of g.
+func (g *GroupElement) IndexOf(e Element) int {
+	for i, c := range g.children {
+		if c == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert makes e the i-th child of g, removing it from any previous parent.
+// i is clamped to the valid range.
+func (g *GroupElement) Insert(i int, e Element) {
+	if p := e.Parent(); p != nil {
+		p.Remove(e)
+	}
+	if i < 0 {
+		i = 0
+	} else if i > len(g.children) {
+		i = len(g.children)
+	}
+	g.children = append(g.children, nil)
+	copy(g.children[i+1:], g.children[i:])
+	g.children[i] = e
+	e.node().parent = g
+}
+
+// Append makes e the topmost child of g.
+func (g *GroupElement) Append(e Element) {
+	g.Insert(len(g.children), e)
+}
+
+// Remove removes child e from g and returns the index it had or -1 if e
+// wasn't a child of g.
+func (g *GroupElement) Remove(e Element) int {
+	i := g.IndexOf(e)
+	if i < 0 {
+		return -1
+	}
+	copy(g.children[i:], g.children[i+1:])
+	g.children[len(g.children)-1] = nil
+	g.children = g.children[:len(g.children)-1]
+	e.node().parent = nil
+	return i
+}
+
+// Transform returns the transform from the group's local coordinates to
+// its parent's.
+func (g *GroupElement) Transform() graphics.Matrix {
+	return g.transform
+}
+
+func (g *GroupElement) SetTransform(m graphics.Matrix) {
+	g.transform = m
+}
+
+func (g *GroupElement) Opacity() float32 {
+	return g.opacity
+}
+
+func (g *GroupElement) SetOpacity(a float32) {
+	g.opacity = a
+}
+
+// Clip returns the clip rectangle in local coordinates. ok is false if the
+// group doesn't clip.
+func (g *GroupElement) Clip() (r graphics.Rectanglef, ok bool) {
+	return g.clip, g.clipped
+}
+
+func (g *GroupElement) SetClip(r graphics.Rectanglef) {
+	g.clip = r
+	g.clipped = true
+}
+
+func (g *GroupElement) ClearClip() {
+	g.clip = graphics.ZR
+	g.clipped = false
+}
+
+// Children may draw handles and anti-aliased edges this many pixels outside
+// their Bounds.
+const GROUP_LAYER_MARGIN = 4
+
+// Draw draws the children. A translucent group is faded as one, in a
+// layer, so its children don't show through each other.
+func (g *GroupElement) Draw(dl *graphics.DisplayList) {
+	if g.opacity != 1 {
+		m := GROUP_LAYER_MARGIN * dl.PixelSize()
+		r := g.Bounds()
+		r = graphics.Rect(r.Min.X-m, r.Min.Y-m, r.Max.X+m, r.Max.Y+m)
+		dl.BeginLayer(r, g.opacity, drawop.SoverD)
+		defer dl.EndLayer()
+	}
+	dl.Save()
+	dl.Transform(g.transform)
+	if g.clipped {
+		dl.ClipRect(g.clip)
+	}
+	for _, c := range g.children {
+		c.Draw(dl)
+	}
+	dl.Restore()
+}
+
+// Bounds returns the bounds of the children (limited by the clip) in the
+// coordinates of g's parent.
+func (g *GroupElement) Bounds() graphics.Rectanglef {
+	if len(g.children) == 0 {
+		return graphics.ZR
+	}
+	r := g.children[0].Bounds()
+	for _, c := range g.children[1:] {
+		r = r.Union(c.Bounds())
+	}
+	if g.clipped {
+		r = r.Intersect(g.clip)
+	}
+	return g.transform.TransformRect(r)
+}
+
+// HitTest maps p into the group's local coordinates and returns the topmost
+// hit descendant. The group itself is never the hit element.
+func (g *GroupElement) HitTest(p graphics.Pointf, pixel float32) (Element, int) {
+	inv, ok := g.transform.Invert()
+	if !ok {
+		return nil, -1
+	}
+	lp := inv.Transform(p)
+	if g.clipped && !lp.In(g.clip) {
+		return nil, -1
+	}
+	lpixel := pixel * graphics.PixelSize(g.transform)
+	for i := len(g.children) - 1; i >= 0; i-- {
+		if e, v := g.children[i].HitTest(lp, lpixel); e != nil {
+			return e, v
+		}
+	}
+	return nil, -1
+}
+
+// Groups have no control points of their own.
+func (g *GroupElement) HoverOn(v int)                        {}
+func (g *GroupElement) HoverOff()                            {}
+func (g *GroupElement) ActivateVertex(v int) graphics.Pointf { return graphics.ZP }
+func (g *GroupElement) SetActiveVertex(p graphics.Pointf)    {}
+func (g *GroupElement) Deactivate()                          {}
+
+// ApplyTransform moves the whole group by folding m into its transform.
+func (g *GroupElement) ApplyTransform(m graphics.Matrix) {
+	g.transform = m.Mul(g.transform)
+}
+
+// groupElementJSON is the serialized form of a GroupElement.
+type groupElementJSON struct {
+	Transform [6]float32        `json:"transform"`
+	Opacity   float32           `json:"opacity"`
+	Clip      *[4]float32       `json:"clip,omitempty"`
+	Children  []json.RawMessage `json:"children"`
+}
+
+func (g *GroupElement) MarshalJSON() ([]byte, error) {
+	m := g.transform
+	j := groupElementJSON{
+		Transform: [6]float32{m.A, m.B, m.C, m.D, m.E, m.F},
+		Opacity:   g.opacity,
+		Children:  make([]json.RawMessage, 0, len(g.children)),
+	}
+	if g.clipped {
+		j.Clip = &[4]float32{g.clip.Min.X, g.clip.Min.Y, g.clip.Max.X, g.clip.Max.Y}
+	}
+	for _, c := range g.children {
+		b, err := MarshalElement(c)
+		if err != nil {
+			return nil, err
+		}
+		j.Children = append(j.Children, b)
+	}
+	return json.Marshal(j)
+}
+
+func (g *GroupElement) UnmarshalJSON(b []byte) error {
+	var j groupElementJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	t := j.Transform
+	g.transform = graphics.Matrix{t[0], t[1], t[2], t[3], t[4], t[5]}
+	g.opacity = j.Opacity
+	if j.Clip != nil {
+		g.SetClip(graphics.Rect(j.Clip[0], j.Clip[1], j.Clip[2], j.Clip[3]))
+	} else {
+		g.ClearClip()
+	}
+	g.children = nil
+	for _, cb := range j.Children {
+		c, err := UnmarshalElement(cb)
+		if err != nil {
+			return err
+		}
+		g.Append(c)
+	}
+	return nil
+}
+
+// validate checks the group's own state. Its transform must be invertible
+// for hit testing.
+func (g *GroupElement) validate() error {
+	m := g.transform
+	if !finite(m.A, m.B, m.C, m.D, m.E, m.F) {
+		return errors.New("dom: group transform isn't finite")
+	}
+	if _, ok := m.Invert(); !ok {
+		return errors.New("dom: group transform isn't invertible")
+	}
+	if !finite(g.opacity) || g.opacity < 0 || g.opacity > 1 {
+		return errors.New("dom: group opacity isn't in [0, 1]")
+	}
+	c := g.clip
+	if g.clipped && (!finite(c.Min.X, c.Min.Y, c.Max.X, c.Max.Y) || c.Min.X > c.Max.X || c.Min.Y > c.Max.Y) {
+		return errors.New("dom: bad group clip")
+	}
+	return nil
+}
+
+// The binary encoding is the transform and opacity as little endian
+// float32s, a byte that is 1 if there is a clip, the clip if there is one
+// and then the children.
+func (g *GroupElement) appendBinary(b []byte) ([]byte, error) {
+	m := g.transform
+	b = appendFloat32s(b, m.A, m.B, m.C, m.D, m.E, m.F, g.opacity)
+	if g.clipped {
+		c := g.clip
+		b = appendFloat32s(append(b, 1), c.Min.X, c.Min.Y, c.Max.X, c.Max.Y)
+	} else {
+		b = append(b, 0)
+	}
+	return appendChildren(b, g)
+}
+
+func (g *GroupElement) readBinary(r *binaryReader) error {
+	m := &g.transform
+	r.float32s(&m.A, &m.B, &m.C, &m.D, &m.E, &m.F, &g.opacity)
+	g.ClearClip()
+	switch r.byte() {
+	case 0:
+	case 1:
+		var c graphics.Rectanglef
+		r.float32s(&c.Min.X, &c.Min.Y, &c.Max.X, &c.Max.Y)
+		g.SetClip(c)
+	default:
+		return errors.New("dom: bad group clip flag")
+	}
+	g.children = nil
+	for _, c := range r.children() {
+		g.Append(c)
+	}
+	return nil
+}
+
+// Walk calls fn for e and then, if e is a group, for each of its
+// descendants in paint order.
+func Walk(e Element, fn func(Element)) {
+	fn(e)
+	if g, ok := e.(*GroupElement); ok {
+		for _, c := range g.children {
+			Walk(c, fn)
+		}
+	}
+}
+
+// IsAncestor reports whether a is e or one of e's ancestors.
+func IsAncestor(a, e Element) bool {
+	for ; e != nil; e = parentElement(e) {
+		if e == a {
+			return true
+		}
+	}
+	return false
+}
+
+// parentElement avoids returning a typed nil *GroupElement as an Element.
+func parentElement(e Element) Element {
+	if p := e.Parent(); p != nil {
+		return p
+	}
+	return nil
+}
+
+// LocalToDocument returns the transform from the coordinates in which e's
+// geometry is given (i.e. the local coordinates of e's parent) to the
+// document's coordinates.
+func LocalToDocument(e Element) graphics.Matrix {
+	m := graphics.Identity()
+	for p := e.Parent(); p != nil; p = p.Parent() {
+		m = p.transform.Mul(m)
+	}
+	return m
+}
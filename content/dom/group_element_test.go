@@ -0,0 +1,184 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func Test_GroupHitTest(t *testing.T) {
+	d := NewDocument()
+	qe := newQuad(50, 50)
+	outer := NewGroupElement()
+	inner := NewGroupElement()
+	inner.Append(qe)
+	outer.Append(inner)
+	d.Add(outer)
+
+	outer.SetTransform(graphics.Translate(graphics.Pointf{100, 0}))
+	inner.SetTransform(graphics.Scale(2, 2))
+
+	// Vertex 0 is at (5, 5) locally, so at (110, 10) in the document.
+	if e, v := d.Root().HitTest(graphics.Pointf{110, 10}, 1); e != qe || v != 0 {
+		t.Errorf("missed vertex 0 through two groups: %v %d", e, v)
+	}
+	if e, _ := d.Root().HitTest(graphics.Pointf{5, 5}, 1); e != nil {
+		t.Errorf("hit an untransformed vertex: %v", e)
+	}
+
+	if r := outer.Bounds(); !r.Eq(graphics.Rect(110, 10, 290, 190)) {
+		t.Errorf("bad bounds %v", r)
+	}
+
+	inner.SetClip(graphics.Rect(10, 10, 100, 100))
+	if e, _ := d.Root().HitTest(graphics.Pointf{110, 10}, 1); e != nil {
+		t.Errorf("hit a clipped out vertex: %v", e)
+	}
+	if e, v := d.Root().HitTest(graphics.Pointf{290, 190}, 1); e != qe || v != 2 {
+		t.Errorf("missed vertex 2 inside the clip: %v %d", e, v)
+	}
+	if r := outer.Bounds(); !r.Eq(graphics.Rect(120, 20, 290, 190)) {
+		t.Errorf("bad clipped bounds %v", r)
+	}
+
+	m := LocalToDocument(qe)
+	if p := m.Transform(graphics.Pointf{5, 5}); !p.Eq(graphics.Pointf{110, 10}) {
+		t.Errorf("bad local to document transform %v", m)
+	}
+	if !IsAncestor(outer, qe) || IsAncestor(qe, outer) {
+		t.Errorf("IsAncestor is confused")
+	}
+}
+
+func Test_GroupUngroup(t *testing.T) {
+	d := NewDocument()
+	a := d.Add(newQuad(0, 0))
+	b := d.Add(newQuad(10, 10))
+	c := d.Add(newQuad(20, 20))
+	e := d.Add(newQuad(30, 30))
+
+	g, err := d.Group(c, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOrder(t, d, int(b), int(g), int(e))
+	ge := d.Get(g).(*GroupElement)
+	testhelpers.AssertInt(t, 2, ge.Len())
+	if ge.At(0).ID() != a || ge.At(1).ID() != c {
+		t.Errorf("group changed the paint order")
+	}
+	if d.IndexOf(c) != 1 {
+		t.Errorf("IndexOf a grouped element should be relative to the group")
+	}
+
+	if _, err := d.Group(b, c); err == nil {
+		t.Errorf("grouped elements that aren't siblings")
+	}
+
+	ge.SetTransform(graphics.Translate(graphics.Pointf{1, 2}))
+	if err := d.Ungroup(g); err != nil {
+		t.Fatal(err)
+	}
+	assertOrder(t, d, int(b), int(a), int(c), int(e))
+	if d.Get(g) != nil {
+		t.Errorf("ungrouped group still in the document")
+	}
+	if r := d.Get(a).Bounds(); !r.Eq(graphics.Rect(-44, -43, 46, 47)) {
+		t.Errorf("group transform not folded into children: %v", r)
+	}
+}
+
+func Test_DeleteSubtree(t *testing.T) {
+	d := NewDocument()
+	a := d.Add(newQuad(0, 0))
+	b := d.Add(newQuad(10, 10))
+	g, _ := d.Group(a, b)
+
+	ge := d.Delete(g)
+	if d.Get(a) != nil || d.Get(b) != nil || d.Len() != 0 {
+		t.Errorf("deleting a group left its children behind")
+	}
+
+	// Putting it back keeps the IDs.
+	d.Add(ge)
+	if d.Get(g) != ge || d.Get(a) == nil || d.Get(b) == nil {
+		t.Errorf("re-added group lost its IDs")
+	}
+	if f := d.Add(newQuad(0, 0)); f <= g {
+		t.Errorf("id %d reused", f)
+	}
+}
+
+func Test_MarshalGroup(t *testing.T) {
+	g := NewGroupElement()
+	g.Append(newQuad(1, 2))
+	inner := NewGroupElement()
+	inner.Append(newQuad(3, 4))
+	inner.SetClip(graphics.Rect(0, 0, 5, 5))
+	inner.SetOpacity(0.5)
+	g.Append(inner)
+	g.SetTransform(graphics.Scale(2, 3))
+
+	b, err := MarshalElement(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := UnmarshalElement(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ng := e.(*GroupElement)
+	if ng.Transform() != g.Transform() || ng.Len() != 2 {
+		t.Errorf("bad round trip: %+v", ng)
+	}
+	ni := ng.At(1).(*GroupElement)
+	if ni.Parent() != ng || ni.Opacity() != 0.5 {
+		t.Errorf("bad nested group: %+v", ni)
+	}
+	if r, ok := ni.Clip(); !ok || !r.Eq(inner.clip) {
+		t.Errorf("lost the clip")
+	}
+	if !ng.Bounds().Eq(g.Bounds()) {
+		t.Errorf("bounds differ %v %v", ng.Bounds(), g.Bounds())
+	}
+}
+
+func Test_GroupOpacity(t *testing.T) {
+	g := NewGroupElement()
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	for _, x := range []float32{50, 80} {
+		qe := newQuad(x, 50)
+		qe.SetColor(red)
+		g.Append(qe)
+	}
+	g.SetOpacity(0.5)
+	dl := new(graphics.DisplayList)
+	g.Draw(dl)
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	dl.Rasterize(img)
+
+	// The overlap is no darker than either quad alone.
+	half := color.RGBA{0x80, 0, 0, 0x80}
+	for _, x := range []int{20, 60, 110} {
+		if c := img.RGBAAt(x, 50); c != half {
+			t.Errorf("pixel %d,50: expected %v, got %v", x, half, c)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package dom
 
 import (
+	"encoding/json"
+	"errors"
 	"image/color"
 	"log"
 
@@ -28,10 +30,22 @@ const (
 	NUM_VERTEX_STATES
 )
 
+// QUAD_ELEMENT_DX, QUAD_ELEMENT_DY give the half size of a new quad in
+// document units. The remaining sizes are in screen pixels so that handles
+// look and feel the same at any zoom.
 const (
 	QUAD_ELEMENT_DX = 45.
 	QUAD_ELEMENT_DY = 45.
+
+	// Half the size of the vertex hit box and of the hover handle.
 	QUAD_ELEMENT_DH = 4.
+
+	// The size of the handle of a vertex that isn't hovered.
+	QUAD_ELEMENT_HANDLE = 2.
+
+	// Quads smaller than this on screen show no handles and can't have
+	// their vertices picked up.
+	QUAD_ELEMENT_MIN_HANDLES = 4 * QUAD_ELEMENT_DH
 )
 
 var modeToColor [NUM_VERTEX_STATES]color.RGBA
@@ -46,9 +60,16 @@ func (qe *QuadElement) vertexColor(mode int) color.RGBA {
 	return modeToColor[mode]
 }
 
-// Element is a placeholder for an element. Will want, not a tree, but a true
-// database format for efficent queries (including spatial).
+const QUAD_ELEMENT_KIND = "quad"
+
+func init() {
+	RegisterKind(QUAD_ELEMENT_KIND, func() Element { return new(QuadElement) })
+}
+
+// QuadElement is a quadrilateral with draggable vertices. Will want, not a
+// tree, but a true database format for efficent queries (including spatial).
 type QuadElement struct {
+	Node
 	vertices     [4]graphics.Pointf
 	color        color.RGBA
 	hoverMode    int
@@ -65,6 +86,30 @@ func (qe *QuadElement) Init(pt graphics.Pointf) {
 	qe.activeVertex = -1
 }
 
+// NumVertices returns the number of vertices: 4.
+func (qe *QuadElement) NumVertices() int {
+	return len(qe.vertices)
+}
+
+// Vertex returns the i-th vertex.
+func (qe *QuadElement) Vertex(i int) graphics.Pointf {
+	return qe.vertices[i]
+}
+
+// SetVertex moves the i-th vertex to p.
+func (qe *QuadElement) SetVertex(i int, p graphics.Pointf) {
+	qe.vertices[i] = p
+}
+
+// Color returns the fill color.
+func (qe *QuadElement) Color() color.RGBA {
+	return qe.color
+}
+
+func (qe *QuadElement) SetColor(c color.RGBA) {
+	qe.color = c
+}
+
 func (qe *QuadElement) ActivateVertex(i int) graphics.Pointf {
 	qe.hoverMode = VERTEX_PRESS
 	qe.activeVertex = i
@@ -79,8 +124,27 @@ func (qe *QuadElement) SetActiveVertex(v graphics.Pointf) {
 	qe.vertices[qe.activeVertex] = v
 }
 
+// showsHandles reports whether qe is big enough on screen for its handles
+// to be useful when a pixel is pixel units in qe's coordinates. An element
+// being interacted with always shows its handles.
+func (qe *QuadElement) showsHandles(pixel float32) bool {
+	if qe.hoverMode != VERTEX_NON {
+		return true
+	}
+	r := qe.Bounds()
+	return graphics.MaxF(r.Dx(), r.Dy()) >= QUAD_ELEMENT_MIN_HANDLES*pixel
+}
+
 func (qe *QuadElement) drawHandle(dl *graphics.DisplayList) {
-	dl.SetPointSize(2.)
+	pixel := dl.PixelSize()
+	if !qe.showsHandles(pixel) {
+		return
+	}
+	// Handles are squares on screen and look best crisp.
+	dl.Save()
+	defer dl.Restore()
+	dl.SetAntialias(false)
+	dl.SetPointSize(QUAD_ELEMENT_HANDLE * pixel)
 	dl.SetColor(qe.vertexColor(VERTEX_NON))
 
 	var ps [4]graphics.Pointf
@@ -95,7 +159,7 @@ func (qe *QuadElement) drawHandle(dl *graphics.DisplayList) {
 	dl.DrawPoints(ps[:])
 	if qe.hoverMode != VERTEX_NON {
 		log.Printf("drawing hover vertex")
-		dl.SetPointSize(2 * QUAD_ELEMENT_DH)
+		dl.SetPointSize(2 * QUAD_ELEMENT_DH * pixel)
 		if qe.hoverMode == VERTEX_HOVER {
 			dl.SetColor(qe.vertexColor(VERTEX_HOVER))
 		} else {
@@ -105,15 +169,39 @@ func (qe *QuadElement) drawHandle(dl *graphics.DisplayList) {
 	}
 }
 
-// TODO(vollick): split this out into an interface.
+func (qe *QuadElement) Kind() string {
+	return QUAD_ELEMENT_KIND
+}
+
 func (qe *QuadElement) Draw(dl *graphics.DisplayList) {
 	dl.SetColor(qe.color)
-	dl.DrawQuads([][4]graphics.Pointf{qe.vertices})
+	// Dragging a vertex can make the quad concave or cross itself.
+	dl.FillPolygon(qe.vertices[:], graphics.FILL_NONZERO)
 	qe.drawHandle(dl)
 }
 
-func (qe *QuadElement) FindVertex(p graphics.Pointf) int {
-	o := graphics.Pointf{QUAD_ELEMENT_DH, QUAD_ELEMENT_DH}
+func (qe *QuadElement) Bounds() graphics.Rectanglef {
+	r := graphics.Rectanglef{qe.vertices[0], qe.vertices[0]}
+	for _, v := range qe.vertices[1:] {
+		r = r.Union(graphics.Rectanglef{v, v})
+	}
+	return r
+}
+
+func (qe *QuadElement) HitTest(p graphics.Pointf, pixel float32) (Element, int) {
+	if v := qe.FindVertex(p, pixel); v != -1 {
+		return qe, v
+	}
+	return nil, -1
+}
+
+// FindVertex returns the index of the vertex whose handle is under p or -1.
+// pixel is the size of a screen pixel in qe's coordinates.
+func (qe *QuadElement) FindVertex(p graphics.Pointf, pixel float32) int {
+	if !qe.showsHandles(pixel) {
+		return -1
+	}
+	o := graphics.Pointf{QUAD_ELEMENT_DH * pixel, QUAD_ELEMENT_DH * pixel}
 	for i, v := range qe.vertices {
 		r := graphics.Rectanglef{v.Sub(o), v.Add(o)}
 		if p.In(r) {
@@ -123,6 +211,12 @@ func (qe *QuadElement) FindVertex(p graphics.Pointf) int {
 	return -1
 }
 
+func (qe *QuadElement) ApplyTransform(m graphics.Matrix) {
+	for i, v := range qe.vertices {
+		qe.vertices[i] = m.Transform(v)
+	}
+}
+
 func (qe *QuadElement) HoverOn(v int) {
 	log.Printf("HoverOn %d", v)
 	qe.hoverMode = VERTEX_HOVER
@@ -133,3 +227,63 @@ func (qe *QuadElement) HoverOff() {
 	log.Printf("HoverOff")
 	qe.hoverMode = VERTEX_NON
 }
+
+// quadElementJSON is the serialized form of a QuadElement. Hover state is
+// transient and isn't saved.
+type quadElementJSON struct {
+	Vertices [4][2]float32 `json:"vertices"`
+	Color    [4]uint8      `json:"color"`
+}
+
+func (qe *QuadElement) MarshalJSON() ([]byte, error) {
+	var j quadElementJSON
+	for i, v := range qe.vertices {
+		j.Vertices[i] = [2]float32{v.X, v.Y}
+	}
+	j.Color = [4]uint8{qe.color.R, qe.color.G, qe.color.B, qe.color.A}
+	return json.Marshal(j)
+}
+
+func (qe *QuadElement) UnmarshalJSON(b []byte) error {
+	var j quadElementJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	for i, v := range j.Vertices {
+		qe.vertices[i] = graphics.Pointf{v[0], v[1]}
+	}
+	qe.color = color.RGBA{j.Color[0], j.Color[1], j.Color[2], j.Color[3]}
+	qe.hoverMode = VERTEX_NON
+	qe.activeVertex = -1
+	return nil
+}
+
+func (qe *QuadElement) validate() error {
+	for _, v := range qe.vertices {
+		if !finite(v.X, v.Y) {
+			return errors.New("dom: quad vertex isn't finite")
+		}
+	}
+	return nil
+}
+
+// The binary encoding is the vertices as little endian float32s followed
+// by the color.
+func (qe *QuadElement) appendBinary(b []byte) ([]byte, error) {
+	for _, v := range qe.vertices {
+		b = appendFloat32s(b, v.X, v.Y)
+	}
+	return append(b, qe.color.R, qe.color.G, qe.color.B, qe.color.A), nil
+}
+
+func (qe *QuadElement) readBinary(r *binaryReader) error {
+	for i := range qe.vertices {
+		r.float32s(&qe.vertices[i].X, &qe.vertices[i].Y)
+	}
+	if c := r.next(4); c != nil {
+		qe.color = color.RGBA{c[0], c[1], c[2], c[3]}
+	}
+	qe.hoverMode = VERTEX_NON
+	qe.activeVertex = -1
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func Test_QuadHandlesKeepScreenSize(t *testing.T) {
+	qe := newQuad(50, 50)
+
+	// Vertex 0 is at (5, 5).
+	testhelpers.AssertInt(t, 0, qe.FindVertex(graphics.Pointf{8, 8}, 1))
+	testhelpers.AssertInt(t, -1, qe.FindVertex(graphics.Pointf{10, 10}, 1))
+
+	// Zoomed in by 4, the handle covers a quarter of the document.
+	testhelpers.AssertInt(t, -1, qe.FindVertex(graphics.Pointf{8, 8}, 0.25))
+	testhelpers.AssertInt(t, 0, qe.FindVertex(graphics.Pointf{5.5, 5.5}, 0.25))
+
+	// Zoomed out by 2, it covers twice as much.
+	testhelpers.AssertInt(t, 0, qe.FindVertex(graphics.Pointf{10, 10}, 2))
+
+	dl := &graphics.DisplayList{}
+	dl.Transform(graphics.Scale(4, 4))
+	qe.Draw(dl)
+	// The handle at (95, 95) extends a screen pixel past the quad.
+	if dl.W != 381 || dl.H != 381 {
+		t.Errorf("handles scaled with the content: %v %v", dl.W, dl.H)
+	}
+}
+
+func Test_QuadHandlesLevelOfDetail(t *testing.T) {
+	qe := newQuad(50, 50)
+
+	// At a tenth of its size the quad is 9 pixels across: too small for
+	// handles.
+	testhelpers.AssertInt(t, -1, qe.FindVertex(graphics.Pointf{5, 5}, 10))
+	dl := &graphics.DisplayList{}
+	dl.Transform(graphics.Scale(.1, .1))
+	qe.Draw(dl)
+	if dl.W != 9.5 || dl.H != 9.5 {
+		t.Errorf("drew handles on a tiny quad: %v %v", dl.W, dl.H)
+	}
+
+	// The vertex being hovered keeps its handles so it can be dragged.
+	qe.HoverOn(0)
+	testhelpers.AssertInt(t, 0, qe.FindVertex(graphics.Pointf{5, 5}, 10))
+	qe.HoverOff()
+	testhelpers.AssertInt(t, -1, qe.FindVertex(graphics.Pointf{5, 5}, 10))
+}
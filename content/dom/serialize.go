@@ -0,0 +1,420 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// Documents are saved in one of two formats: versioned JSON for people and
+// tools and a compact binary format for speed. Load tells them apart.
+type Format int
+
+const (
+	FORMAT_JSON Format = iota
+	FORMAT_BINARY
+)
+
+// DOCUMENT_VERSION is the version of the JSON format written by Save.
+//
+// Version 1 is a bare array of the top-level elements as written by
+// MarshalElement. Version 2 wraps them in an object with a version number.
+const DOCUMENT_VERSION = 2
+
+// documentJSON is version 2 and later of the JSON format.
+type documentJSON struct {
+	Version  int               `json:"version"`
+	Elements []json.RawMessage `json:"elements"`
+}
+
+// jsonMigrations[v] converts a document from version v to version v+1.
+var jsonMigrations = map[int]func(b []byte) ([]byte, error){
+	1: func(b []byte) ([]byte, error) {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(b, &elements); err != nil {
+			return nil, err
+		}
+		return json.Marshal(documentJSON{Version: 2, Elements: elements})
+	},
+}
+
+// The binary format is BINARY_MAGIC, the version as a uvarint and then the
+// root group's children: their count followed by each one as written by
+// appendElement.
+const (
+	BINARY_MAGIC   = "GJRW"
+	BINARY_VERSION = 1
+)
+
+// Element records in the binary format hold the element's own binary
+// encoding if it has one and its JSON otherwise.
+const (
+	binaryOwn = iota
+	binaryJSON
+)
+
+// MAX_DOCUMENT_DEPTH limits the nesting of groups in loaded documents.
+const MAX_DOCUMENT_DEPTH = 512
+
+// validator is implemented by elements that can check their state after
+// being loaded.
+type validator interface {
+	validate() error
+}
+
+// binaryElement is implemented by elements with their own binary encoding.
+type binaryElement interface {
+	appendBinary(b []byte) ([]byte, error)
+	readBinary(r *binaryReader) error
+}
+
+// Save writes the elements of d, but not their IDs, to w.
+func (d *Document) Save(w io.Writer, format Format) error {
+	var b []byte
+	var err error
+	switch format {
+	case FORMAT_JSON:
+		b, err = d.MarshalJSON()
+	case FORMAT_BINARY:
+		b, err = d.MarshalBinary()
+	default:
+		err = fmt.Errorf("dom: unknown format %d", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Load reads a Document in either format, and any version of the JSON
+// format, from r. The elements are given new IDs.
+func Load(r io.Reader) (*Document, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := NewDocument()
+	if bytes.HasPrefix(b, []byte(BINARY_MAGIC)) {
+		err = d.UnmarshalBinary(b)
+	} else {
+		err = d.UnmarshalJSON(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// MarshalJSON returns the current version of the JSON format.
+func (d *Document) MarshalJSON() ([]byte, error) {
+	j := documentJSON{
+		Version:  DOCUMENT_VERSION,
+		Elements: make([]json.RawMessage, 0, d.Len()),
+	}
+	for i := 0; i < d.Len(); i++ {
+		b, err := MarshalElement(d.At(i))
+		if err != nil {
+			return nil, err
+		}
+		j.Elements = append(j.Elements, b)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON replaces the contents of d with the document in b, which
+// may be any version of the JSON format.
+func (d *Document) UnmarshalJSON(b []byte) error {
+	version, err := jsonVersion(b)
+	if err != nil {
+		return err
+	}
+	if version > DOCUMENT_VERSION {
+		return fmt.Errorf("dom: document version %d is newer than %d", version, DOCUMENT_VERSION)
+	}
+	for ; version < DOCUMENT_VERSION; version++ {
+		if b, err = jsonMigrations[version](b); err != nil {
+			return fmt.Errorf("dom: migrating from version %d: %v", version, err)
+		}
+	}
+
+	var j documentJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	elements := make([]Element, 0, len(j.Elements))
+	for _, eb := range j.Elements {
+		e, err := UnmarshalElement(eb)
+		if err != nil {
+			return err
+		}
+		elements = append(elements, e)
+	}
+	return d.replace(elements)
+}
+
+// jsonVersion returns the version of the JSON document in b.
+func jsonVersion(b []byte) (int, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		return 1, nil
+	}
+	var v struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return 0, fmt.Errorf("dom: not a document: %v", err)
+	}
+	if v.Version == nil || *v.Version < 2 {
+		return 0, errors.New("dom: document has no valid version")
+	}
+	return *v.Version, nil
+}
+
+// replace makes elements, once validated, the contents of d.
+func (d *Document) replace(elements []Element) error {
+	for _, e := range elements {
+		if err := validate(e, 0); err != nil {
+			return err
+		}
+	}
+	for d.Len() > 0 {
+		d.Delete(d.At(0).ID())
+	}
+	for _, e := range elements {
+		d.Add(e)
+	}
+	return nil
+}
+
+// validate checks e and its descendants, which must be no more than
+// MAX_DOCUMENT_DEPTH deep.
+func validate(e Element, depth int) error {
+	if depth > MAX_DOCUMENT_DEPTH {
+		return errors.New("dom: groups nested too deeply")
+	}
+	if v, ok := e.(validator); ok {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	if g, ok := e.(*GroupElement); ok {
+		for _, c := range g.children {
+			if err := validate(c, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// finite reports whether all of fs are neither infinite nor NaN.
+func finite(fs ...float32) bool {
+	for _, f := range fs {
+		if math.IsInf(float64(f), 0) || math.IsNaN(float64(f)) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalBinary returns the binary format.
+func (d *Document) MarshalBinary() ([]byte, error) {
+	b := []byte(BINARY_MAGIC)
+	b = appendUvarint(b, BINARY_VERSION)
+	return appendChildren(b, d.root)
+}
+
+// UnmarshalBinary replaces the contents of d with the document in b.
+func (d *Document) UnmarshalBinary(b []byte) error {
+	if !bytes.HasPrefix(b, []byte(BINARY_MAGIC)) {
+		return errors.New("dom: not a binary document")
+	}
+	r := &binaryReader{b: b[len(BINARY_MAGIC):]}
+	if v := r.uvarint(); r.err == nil && v != BINARY_VERSION {
+		return fmt.Errorf("dom: unknown binary document version %d", v)
+	}
+	elements := r.children()
+	if r.err == nil && len(r.b) != 0 {
+		r.err = errors.New("dom: trailing data after the document")
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return d.replace(elements)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendFloat32s(b []byte, fs ...float32) []byte {
+	for _, f := range fs {
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(f))
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+// appendChildren appends the count of g's children and then each child.
+func appendChildren(b []byte, g *GroupElement) ([]byte, error) {
+	b = appendUvarint(b, uint64(len(g.children)))
+	var err error
+	for _, c := range g.children {
+		if b, err = appendElement(b, c); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendElement appends the kind of e as a length prefixed string, a
+// binaryOwn or binaryJSON byte and then e's own encoding or its length
+// prefixed JSON.
+func appendElement(b []byte, e Element) ([]byte, error) {
+	kind := e.Kind()
+	b = appendUvarint(b, uint64(len(kind)))
+	b = append(b, kind...)
+	if be, ok := e.(binaryElement); ok {
+		return be.appendBinary(append(b, binaryOwn))
+	}
+	j, err := e.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, binaryJSON)
+	b = appendUvarint(b, uint64(len(j)))
+	return append(b, j...), nil
+}
+
+// binaryReader reads the binary format. The first error sticks and makes
+// subsequent reads return zero values.
+type binaryReader struct {
+	b     []byte
+	err   error
+	depth int
+}
+
+var errTruncated = errors.New("dom: binary document truncated")
+
+func (r *binaryReader) next(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.b)) {
+		r.err = errTruncated
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *binaryReader) byte() byte {
+	if p := r.next(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binaryReader) float32s(fs ...*float32) {
+	for _, f := range fs {
+		if p := r.next(4); p != nil {
+			*f = math.Float32frombits(binary.LittleEndian.Uint32(p))
+		}
+	}
+}
+
+// children reads a count and then that many elements.
+func (r *binaryReader) children() []Element {
+	n := r.uvarint()
+	// Every element takes at least three bytes.
+	if n > uint64(len(r.b)) {
+		r.err = errTruncated
+	}
+	if r.err != nil {
+		return nil
+	}
+	if r.depth++; r.depth > MAX_DOCUMENT_DEPTH {
+		r.err = errors.New("dom: groups nested too deeply")
+		return nil
+	}
+	defer func() { r.depth-- }()
+
+	elements := make([]Element, 0, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		if e := r.element(); e != nil {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+// element reads an element written by appendElement.
+func (r *binaryReader) element() Element {
+	kind := string(r.next(r.uvarint()))
+	enc := r.byte()
+	if r.err != nil {
+		return nil
+	}
+	e, err := NewElement(kind)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	switch enc {
+	case binaryOwn:
+		be, ok := e.(binaryElement)
+		if !ok {
+			r.err = fmt.Errorf("dom: %q has no binary encoding", kind)
+			return nil
+		}
+		if err := be.readBinary(r); err != nil && r.err == nil {
+			r.err = err
+		}
+	case binaryJSON:
+		if j := r.next(r.uvarint()); r.err == nil {
+			r.err = e.UnmarshalJSON(j)
+		}
+	default:
+		r.err = fmt.Errorf("dom: bad element encoding %d", enc)
+	}
+	if r.err != nil {
+		return nil
+	}
+	return e
+}
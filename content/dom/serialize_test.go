@@ -0,0 +1,143 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+// sampleDocument returns a document with every kind of element and a
+// nested, clipped group.
+func sampleDocument() *Document {
+	d := NewDocument()
+	d.Add(newQuad(10, 20))
+	g := NewGroupElement()
+	g.SetTransform(graphics.Translate(graphics.Pointf{5, 6}))
+	inner := NewGroupElement()
+	inner.SetOpacity(0.25)
+	inner.SetClip(graphics.Rect(0, 0, 50, 60))
+	inner.Append(newQuad(30, 40))
+	g.Append(inner)
+	g.Append(&markElement{At: graphics.Pointf{7, 8}})
+	d.Add(g)
+	return d
+}
+
+// assertSameDocument checks that a and b serialize identically.
+func assertSameDocument(t *testing.T, a, b *Document) {
+	ab, _ := a.MarshalJSON()
+	bb, _ := b.MarshalJSON()
+	testhelpers.AssertString(t, string(ab), string(bb))
+}
+
+func Test_SaveLoad(t *testing.T) {
+	d := sampleDocument()
+	for _, format := range []Format{FORMAT_JSON, FORMAT_BINARY} {
+		var b bytes.Buffer
+		if err := d.Save(&b, format); err != nil {
+			t.Fatal(err)
+		}
+		n, err := Load(&b)
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		assertSameDocument(t, d, n)
+		testhelpers.AssertInt(t, 2, n.Len())
+		if n.Get(n.At(1).ID()) != n.At(1) {
+			t.Errorf("loaded elements have no IDs")
+		}
+	}
+
+	// The binary format is the smaller.
+	j, _ := d.MarshalJSON()
+	b, _ := d.MarshalBinary()
+	if len(b) >= len(j)/2 {
+		t.Errorf("binary %d bytes, JSON %d bytes", len(b), len(j))
+	}
+}
+
+func Test_LoadVersion1(t *testing.T) {
+	d := sampleDocument()
+	var elements []string
+	for i := 0; i < d.Len(); i++ {
+		b, _ := MarshalElement(d.At(i))
+		elements = append(elements, string(b))
+	}
+	n, err := Load(strings.NewReader(" [" + strings.Join(elements, ",") + "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameDocument(t, d, n)
+}
+
+func Test_LoadRejectsBadDocuments(t *testing.T) {
+	quad := `{"kind":"quad","data":{"vertices":[[0,0],[1,0],[1,1],[0,1]],"color":[0,0,0,255]}}`
+	for _, s := range []string{
+		``,
+		`{}`,
+		`{"version":99,"elements":[]}`,
+		`{"version":2,"elements":[{"kind":"nope","data":{}}]}`,
+		`{"version":2,"elements":[{"kind":"group","data":{"transform":[1,0,0,1,0,0],"opacity":2,"children":[]}}]}`,
+		`{"version":2,"elements":[{"kind":"group","data":{"transform":[0,0,0,0,0,0],"opacity":1,"children":[]}}]}`,
+		`[` + quad + `,`,
+		BINARY_MAGIC,
+	} {
+		if _, err := Load(strings.NewReader(s)); err == nil {
+			t.Errorf("loaded %q", s)
+		}
+	}
+	if _, err := Load(strings.NewReader(`[` + quad + `]`)); err != nil {
+		t.Error(err)
+	}
+
+	// Every truncation of a binary document is caught.
+	b, _ := sampleDocument().MarshalBinary()
+	for i := len(BINARY_MAGIC); i < len(b); i++ {
+		if _, err := Load(bytes.NewReader(b[:i])); err == nil {
+			t.Errorf("loaded %d of %d bytes", i, len(b))
+		}
+	}
+
+	// A failed load leaves the document alone.
+	d := sampleDocument()
+	if err := d.UnmarshalJSON([]byte(`{"version":2,"elements":[{"kind":"nope"}]}`)); err == nil {
+		t.Error("loaded a bad document")
+	}
+	testhelpers.AssertInt(t, 2, d.Len())
+}
+
+func Test_LoadDeepNesting(t *testing.T) {
+	g := NewGroupElement()
+	for i := 0; i < MAX_DOCUMENT_DEPTH+2; i++ {
+		p := NewGroupElement()
+		p.Append(g)
+		g = p
+	}
+	d := NewDocument()
+	d.Add(g)
+	b, _ := d.MarshalBinary()
+	if _, err := Load(bytes.NewReader(b)); err == nil {
+		t.Error("loaded a binary document nested too deeply")
+	}
+	j, _ := d.MarshalJSON()
+	if _, err := Load(bytes.NewReader(j)); err == nil {
+		t.Error("loaded a JSON document nested too deeply")
+	}
+}
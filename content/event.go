@@ -0,0 +1,227 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// EventType says what an Event describes.
+type EventType int
+
+const (
+	EVENT_NONE  EventType = iota
+	EVENT_BLUR            // The window lost the keyboard focus.
+	EVENT_FOCUS           // The window gained the keyboard focus.
+	EVENT_KEYDOWN
+	EVENT_KEYUP
+	EVENT_MOUSEDOWN
+	EVENT_MOUSEENTER // The pointer entered the window.
+	EVENT_MOUSELEAVE // The pointer left the window.
+	EVENT_MOUSEMOVE
+	EVENT_MOUSEUP
+	EVENT_RESIZE
+	EVENT_TEXTINPUT
+	EVENT_WHEEL
+)
+
+// Event is a timestamped input or window event. Only the fields relevant
+// to the Type are set. The platform layer produces Events in Window
+// coordinates; the Window maps them into Frame coordinates before they
+// reach an EventHandler.
+type Event struct {
+	Type EventType
+
+	// When the event happened, measured from an arbitrary start.
+	Time time.Duration
+
+	// The pointer position for pointer events.
+	Point graphics.Pointf
+
+	// As for MouseEvent.
+	Button, Buttons uint32
+	ClickCount      int
+
+	// An or of MOD_ values for pointer and key events.
+	Modifiers uint32
+
+	// Wheel deltas in pixels, positive to scroll down and right.
+	DX, DY, DZ float32
+
+	// The key for key events. Repeat is set for auto-repeated presses.
+	Key    Key
+	Repeat bool
+
+	// The text entered for EVENT_TEXTINPUT.
+	Text rune
+
+	// The new size of the window for EVENT_RESIZE.
+	Width, Height int
+}
+
+// Mouse returns the MouseEvent for pointer event ev.
+func (ev *Event) Mouse() MouseEvent {
+	return MouseEvent{
+		Point:      ev.Point,
+		Button:     ev.Button,
+		Buttons:    ev.Buttons,
+		Modifiers:  ev.Modifiers,
+		ClickCount: ev.ClickCount,
+		Time:       ev.Time,
+	}
+}
+
+// Deliver calls the method of h for ev and returns its result. Events that
+// EventHandler has no method for return EVD_NON.
+func Deliver(h EventHandler, ev Event) uint32 {
+	switch ev.Type {
+	case EVENT_MOUSEDOWN:
+		return h.Mousedown(ev.Mouse())
+	case EVENT_MOUSEUP:
+		return h.Mouseup(ev.Mouse())
+	case EVENT_MOUSEMOVE:
+		return h.Mousemove(ev.Mouse())
+	case EVENT_WHEEL:
+		return h.Wheel(ev.Mouse(), ev.DX, ev.DY, ev.DZ)
+	case EVENT_KEYDOWN:
+		return h.Keydown(ev.Key, ev.Modifiers, ev.Repeat)
+	case EVENT_KEYUP:
+		return h.Keyup(ev.Key, ev.Modifiers)
+	case EVENT_TEXTINPUT:
+		return h.Textinput(ev.Text)
+	case EVENT_MOUSEENTER:
+		return h.Mouseenter(ev.Mouse())
+	case EVENT_MOUSELEAVE:
+		return h.Mouseleave(ev.Mouse())
+	case EVENT_FOCUS, EVENT_BLUR:
+		return h.Windowfocus(ev.Type == EVENT_FOCUS)
+	}
+	return EVD_NON
+}
+
+// EventQueue carries Events from the platform layer to the goroutine that
+// handles them. Posting never blocks. Mouse moves posted while the handler
+// is busy are coalesced: a move replaces a preceding queued move that has
+// the same buttons and modifiers. Receive the Events either from the
+// Events channel or with Drain but not both.
+type EventQueue struct {
+	mu      sync.Mutex
+	pending []Event
+
+	wake   chan struct{}
+	events chan Event
+	done   chan struct{}
+	start  sync.Once
+	once   sync.Once
+}
+
+func NewEventQueue() *EventQueue {
+	return &EventQueue{
+		wake:   make(chan struct{}, 1),
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+}
+
+// Events returns the channel on which the queued Events arrive in order.
+// It is closed after Close.
+func (q *EventQueue) Events() <-chan Event {
+	q.start.Do(func() { go q.pump() })
+	return q.events
+}
+
+// Drain removes and returns the queued Events without waiting for more.
+func (q *EventQueue) Drain() []Event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	evs := q.pending
+	q.pending = nil
+	return evs
+}
+
+// Post queues ev.
+func (q *EventQueue) Post(ev Event) {
+	q.mu.Lock()
+	if n := len(q.pending); n > 0 && coalesces(q.pending[n-1], ev) {
+		q.pending[n-1] = ev
+	} else {
+		q.pending = append(q.pending, ev)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close discards the queued Events and closes the Events channel.
+func (q *EventQueue) Close() {
+	q.once.Do(func() { close(q.done) })
+	q.start.Do(func() { close(q.events) })
+}
+
+// coalesces reports whether later can replace earlier.
+func coalesces(earlier, later Event) bool {
+	return earlier.Type == EVENT_MOUSEMOVE && later.Type == EVENT_MOUSEMOVE &&
+		earlier.Buttons == later.Buttons && earlier.Modifiers == later.Modifiers
+}
+
+// pop removes the first pending Event. ok is false if there is none.
+func (q *EventQueue) pop() (ev Event, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return Event{}, false
+	}
+	ev = q.pending[0]
+	q.pending = q.pending[1:]
+	return ev, true
+}
+
+func (q *EventQueue) pump() {
+	defer close(q.events)
+	for {
+		ev, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		// Keep coalescing into the Event waiting to be received.
+		for sent := false; !sent; {
+			select {
+			case q.events <- ev:
+				sent = true
+			case <-q.wake:
+				q.mu.Lock()
+				if len(q.pending) > 0 && coalesces(ev, q.pending[0]) {
+					ev = q.pending[0]
+					q.pending = q.pending[1:]
+				}
+				q.mu.Unlock()
+			case <-q.done:
+				return
+			}
+		}
+	}
+}
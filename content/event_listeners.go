@@ -15,8 +15,11 @@
 package content
 
 import (
-	"image"
 	"log"
+	"time"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
 )
 
 // | These together to record which button is up or down.
@@ -38,20 +41,69 @@ const (
 	EVD_DEF            // A handler exists and it wants the default action.
 )
 
+// Presses of the same button no further apart than DOUBLE_CLICK_INTERVAL
+// and DOUBLE_CLICK_SLOP pixels count as a multiple click unless the Window
+// is configured otherwise.
+const (
+	DOUBLE_CLICK_INTERVAL = 500 * time.Millisecond
+	DOUBLE_CLICK_SLOP     = 4
+)
+
+// MouseEvent describes the state of the mouse for an EventHandler.
+type MouseEvent struct {
+	// The pointer position in Frame coordinates. It isn't rounded to whole
+	// pixels.
+	Point graphics.Pointf
+
+	// The button that went down or up (0 is the left button) and the
+	// MOUSE_BUTTON_ mask of the buttons down after the event.
+	Button, Buttons uint32
+
+	// An or of MOD_ values.
+	Modifiers uint32
+
+	// 1 for a single click, 2 for a double click and so on. Set for both
+	// the press and the release. 0 for other events.
+	ClickCount int
+
+	// When the event happened, measured from an arbitrary start.
+	Time time.Duration
+}
+
 // Anything Frame-like entity capable of receiving events
 // needs to implement this interface.
 type EventHandler interface {
 	// Corresponds to JS registered with onmousedown
-	Mousedown(pt image.Point, button, buttons uint32) uint32
+	Mousedown(ev MouseEvent) uint32
 
 	// Corresponds to JS registered with onmouseup
-	Mouseup(pt image.Point, button, buttons uint32) uint32
+	Mouseup(ev MouseEvent) uint32
 
 	// Corresponds to JS registered with onmousemove
-	Mousemove(pt image.Point, buttons uint32) uint32
+	Mousemove(ev MouseEvent) uint32
 
 	// Corresponds to JS registered with onmousewheel
-	Wheel(pt image.Point, buttons uint32, dx, dy, dz float32) uint32
+	Wheel(ev MouseEvent, dx, dy, dz float32) uint32
+
+	// Corresponds to JS registered with onkeydown. mods is an or of MOD_
+	// values and repeat is set for auto-repeated presses.
+	Keydown(key Key, mods uint32, repeat bool) uint32
+
+	// Corresponds to JS registered with onkeyup
+	Keyup(key Key, mods uint32) uint32
+
+	// Corresponds to JS registered with ontextinput: r is the text produced
+	// by the key presses after layout and input method processing.
+	Textinput(r rune) uint32
+
+	// Corresponds to JS registered with onmouseenter and onmouseleave on
+	// the document: the pointer entered or left the window.
+	Mouseenter(ev MouseEvent) uint32
+	Mouseleave(ev MouseEvent) uint32
+
+	// Corresponds to JS registered with window.onfocus and window.onblur:
+	// the window gained or lost the keyboard focus.
+	Windowfocus(focused bool) uint32
 }
 
 // These are "event listeners": functionality that really
@@ -64,36 +116,228 @@ type EventHandler interface {
 // for these handlers would receive the event bundle and call into v8
 // On the browser side, the messaging proxy would handle this.
 //
-func (f *Frame) Mousedown(pt image.Point, button, buttons uint32) uint32 {
+// Each handler dispatches a DOM event through the document tree before
+// performing the Frame's own default action, which a listener may suppress
+// with PreventDefault. The return value tells the Window whether to perform
+// its default action.
+func (f *Frame) Mousedown(me MouseEvent) uint32 {
 	log.Printf("OnMouseDown")
 
-	e, v := f.FindElementAtPoint(pt)
-	if e != nil && v > -1 {
-		f.StartMouseDownMode(pt, e, v)
+	e, v := f.FindElementAtPoint(me.Point)
+	r := f.dispatch(e, f.mouseEvent(dom.EVENT_MOUSEDOWN, me))
+	if r == EVD_PREVDEF {
+		return r
 	}
-	return EVD_PREVDEF
+	f.Focus(e)
+	if me.Button != 0 {
+		return r
+	}
+
+	// Pressing a vertex selects its element unless the vertex is already
+	// selected. Shift-pressing toggles the vertex alone. Pressing elsewhere
+	// starts a rubber band selection.
+	shift := me.Modifiers&MOD_SHIFT != 0
+	switch {
+	case e != nil && v > -1 && shift:
+		f.selection.ToggleVertex(e.ID(), v)
+		if f.selection.ContainsVertex(e.ID(), v) {
+			f.StartMouseDownMode(me.Point, e, v)
+		}
+	case e != nil && v > -1:
+		if !f.selection.ContainsVertex(e.ID(), v) && !f.selection.Contains(e.ID()) {
+			f.selection.Clear()
+			f.selection.Add(e.ID())
+		}
+		f.StartMouseDownMode(me.Point, e, v)
+	default:
+		f.startMarquee(me.Point)
+	}
+	return r
 }
 
-func (f *Frame) Mouseup(pt image.Point, button, buttons uint32) uint32 {
-	if button == 0 && f.mouseDown {
+func (f *Frame) Mouseup(me MouseEvent) uint32 {
+	target := f.overElement
+	if !f.mouseDown {
+		target, _ = f.FindElementAtPoint(me.Point)
+	}
+	r := f.dispatch(target, f.mouseEvent(dom.EVENT_MOUSEUP, me))
+
+	// A drag always ends so that it can't get stuck. Only a single click
+	// adds an element so that double-clicking doesn't add two.
+	shift := me.Modifiers&MOD_SHIFT != 0
+	switch {
+	case me.Button != 0:
+	case f.mouseDown:
 		f.EndMouseDownMode()
-	} else if button == 0 {
-		f.AddElement(pt)
+	case f.marquee && f.endMarquee(me.Point, shift):
+		// The press started a rubber band selection rather than a click.
+	case me.ClickCount <= 1 && r != EVD_PREVDEF:
+		if !shift {
+			f.selection.Clear()
+		}
+		f.AddElement(me.Point)
 	}
-	return EVD_PREVDEF
+	return r
+}
+
+func (f *Frame) Mousemove(me MouseEvent) uint32 {
+	// While dragging, the dragged element gets the events wherever the
+	// pointer is.
+	dragging := me.Buttons&MOUSE_BUTTON_LEFT == MOUSE_BUTTON_LEFT
+	target, v := f.overElement, -1
+	if !dragging {
+		target, v = f.FindElementAtPoint(me.Point)
+	}
+	r := f.dispatch(target, f.mouseEvent(dom.EVENT_MOUSEMOVE, me))
+	if r == EVD_PREVDEF {
+		return r
+	}
+
+	switch {
+	case dragging && f.marquee:
+		f.marqueeTo = me.Point
+	case dragging && f.mouseDown:
+		f.snapOff = me.Modifiers&SNAP_OFF_MODIFIER != 0
+		f.InMouseDownMode(me.Point)
+	case !dragging:
+		f.MouseOver(target, v)
+	}
+	return r
 }
 
-func (f *Frame) Mousemove(pt image.Point, buttons uint32) uint32 {
-	if buttons&MOUSE_BUTTON_LEFT == MOUSE_BUTTON_LEFT {
-		f.InMouseDownMode(pt)
-	} else {
-		e, v := f.FindElementAtPoint(pt)
-		f.MouseOver(e, v)
+func (f *Frame) Wheel(me MouseEvent, dx, dy, dz float32) uint32 {
+	// The container for the frame scrolls unless a listener prevents it.
+	e, _ := f.FindElementAtPoint(me.Point)
+	ev := f.mouseEvent(dom.EVENT_WHEEL, me)
+	ev.DX, ev.DY, ev.DZ = dx, dy, dz
+	return f.dispatch(e, ev)
+}
+
+func (f *Frame) mouseEvent(typ string, me MouseEvent) *dom.Event {
+	return &dom.Event{
+		Type:      typ,
+		Point:     me.Point,
+		Button:    me.Button,
+		Buttons:   me.Buttons,
+		Modifiers: me.Modifiers,
+		Detail:    me.ClickCount,
+		Time:      me.Time,
+	}
+}
+
+// dispatch routes ev through the document tree to target (or to the
+// document's root if target is nil) and summarizes the outcome for the
+// caller of the EventHandler.
+func (f *Frame) dispatch(target dom.Element, ev *dom.Event) uint32 {
+	if target == nil {
+		target = f.document.Root()
+	}
+	if !dom.Dispatch(target, ev) {
+		return EVD_NON
+	}
+	if ev.DefaultPrevented() {
+		return EVD_PREVDEF
 	}
+	return EVD_DEF
+}
+
+// Key events go to the focused element or to the document if nothing has
+// focus.
+func (f *Frame) Keydown(key Key, mods uint32, repeat bool) uint32 {
+	ev := &dom.Event{Type: dom.EVENT_KEYDOWN, Key: int(key), Modifiers: mods, Repeat: repeat}
+	r := f.dispatch(f.focus, ev)
+	if r == EVD_PREVDEF {
+		return r
+	}
+
+	nudge := float32(1)
+	if mods&MOD_SHIFT != 0 {
+		nudge = 10
+	}
+	switch key {
+	case KEY_TAB:
+		f.FocusNext(mods&MOD_SHIFT != 0)
+	case KEY_ESCAPE:
+		f.Focus(nil)
+		f.selection.Clear()
+	case KEY_DELETE, KEY_BACKSPACE:
+		if !f.DeleteSelection() && f.focus != nil {
+			f.DeleteElement(f.focus.ID())
+		}
+	case KEY_LEFT:
+		f.nudgeFocus(graphics.Pointf{-nudge, 0}, repeat)
+	case KEY_RIGHT:
+		f.nudgeFocus(graphics.Pointf{nudge, 0}, repeat)
+	case KEY_UP:
+		f.nudgeFocus(graphics.Pointf{0, -nudge}, repeat)
+	case KEY_DOWN:
+		f.nudgeFocus(graphics.Pointf{0, nudge}, repeat)
+	case KEY_Z:
+		// Ctrl-Z and Ctrl-Shift-Z or, on the Mac, Cmd-Z and Cmd-Shift-Z.
+		if mods&(MOD_CONTROL|MOD_SUPER) == 0 {
+			return r
+		}
+		if mods&MOD_SHIFT != 0 {
+			f.Redo()
+		} else {
+			f.Undo()
+		}
+	case KEY_Y:
+		if mods&MOD_CONTROL == 0 {
+			return r
+		}
+		f.Redo()
+	case KEY_A:
+		if mods&(MOD_CONTROL|MOD_SUPER) == 0 {
+			return r
+		}
+		f.SelectAll()
+	default:
+		return r
+	}
+	// The Frame used the key.
 	return EVD_PREVDEF
 }
 
-func (f *Frame) Wheel(pt image.Point, buttons uint32, dx, dy, dz float32) uint32 {
-	// The container for the frame scrolls.
+func (f *Frame) Keyup(key Key, mods uint32) uint32 {
+	return f.dispatch(f.focus, &dom.Event{Type: dom.EVENT_KEYUP, Key: int(key), Modifiers: mods})
+}
+
+func (f *Frame) Textinput(r rune) uint32 {
+	return f.dispatch(f.focus, &dom.Event{Type: dom.EVENT_TEXTINPUT, Text: r})
+}
+
+// Mouseenter does nothing: the next move finds the hovered element.
+func (f *Frame) Mouseenter(me MouseEvent) uint32 {
+	return EVD_NON
+}
+
+// Mouseleave clears the hover unless a drag, which keeps its element
+// wherever the pointer goes, is in progress.
+func (f *Frame) Mouseleave(me MouseEvent) uint32 {
+	if !f.mouseDown {
+		f.MouseOver(nil, -1)
+	}
+	return EVD_NON
+}
+
+// Windowfocus does nothing: the focused element keeps the focus while the
+// window is in the background.
+func (f *Frame) Windowfocus(focused bool) uint32 {
 	return EVD_NON
 }
+
+// nudgeFocus moves the selection or, if nothing is selected, the focused
+// element by d. Auto-repeated nudges are undone together with the press
+// that started them.
+func (f *Frame) nudgeFocus(d graphics.Pointf, repeat bool) {
+	var c Command
+	if f.selection.Len() > 0 {
+		c = f.moveSelection(d)
+	} else if f.focus != nil {
+		c = &moveElement{id: f.focus.ID(), d: d}
+	}
+	if c != nil && c.Do(f) == nil {
+		f.history.push(c, repeat)
+	}
+}
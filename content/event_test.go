@@ -0,0 +1,102 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func move(x float32, buttons uint32) Event {
+	return Event{Type: EVENT_MOUSEMOVE, Point: graphics.Ptf(x, 0), Buttons: buttons}
+}
+
+func Test_EventQueueCoalescesMoves(t *testing.T) {
+	q := NewEventQueue()
+	q.Post(move(1, 0))
+	q.Post(move(2, 0))
+	q.Post(move(3, MOUSE_BUTTON_LEFT))
+	q.Post(Event{Type: EVENT_KEYDOWN, Key: KEY_A})
+	q.Post(move(4, MOUSE_BUTTON_LEFT))
+	q.Post(move(5, MOUSE_BUTTON_LEFT))
+
+	expected := []Event{move(2, 0), move(3, MOUSE_BUTTON_LEFT), {Type: EVENT_KEYDOWN, Key: KEY_A}, move(5, MOUSE_BUTTON_LEFT)}
+	for i, e := range expected {
+		select {
+		case ev := <-q.Events():
+			if ev != e {
+				t.Errorf("event %d: expected %+v, got %+v", i, e, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d never arrived", i)
+		}
+	}
+
+	q.Close()
+	if _, ok := <-q.Events(); ok {
+		t.Errorf("Events not closed")
+	}
+	// Posting after Close is harmless.
+	q.Post(move(6, 0))
+}
+
+func Test_Deliver(t *testing.T) {
+	f := NewFrame()
+	testhelpers.AssertInt(t, 0, int(Deliver(f, Event{Type: EVENT_RESIZE, Width: 10, Height: 10})))
+
+	pt := graphics.Ptf(100, 100)
+	Deliver(f, Event{Type: EVENT_MOUSEDOWN, Point: pt, Buttons: MOUSE_BUTTON_LEFT, ClickCount: 1})
+	Deliver(f, Event{Type: EVENT_MOUSEUP, Point: pt, ClickCount: 1})
+	testhelpers.AssertInt(t, 1, f.document.Len())
+
+	// Leaving the window clears the hover.
+	corner := graphics.Ptf(100-dom.QUAD_ELEMENT_DX, 100-dom.QUAD_ELEMENT_DY)
+	Deliver(f, Event{Type: EVENT_MOUSEMOVE, Point: corner})
+	if f.overElement == nil {
+		t.Fatal("no element hovered")
+	}
+	Deliver(f, Event{Type: EVENT_MOUSELEAVE, Point: corner})
+	if f.overElement != nil {
+		t.Errorf("hover outlived the pointer leaving: %+v", f.overElement)
+	}
+
+	f.Focus(f.document.At(0))
+	testhelpers.AssertInt(t, EVD_PREVDEF, int(Deliver(f, Event{Type: EVENT_KEYDOWN, Key: KEY_DELETE})))
+	testhelpers.AssertInt(t, 0, f.document.Len())
+}
+
+func Test_EventQueueDrain(t *testing.T) {
+	q := NewEventQueue()
+	if evs := q.Drain(); len(evs) != 0 {
+		t.Errorf("new queue not empty: %v", evs)
+	}
+	q.Post(move(1, 0))
+	q.Post(move(2, 0))
+	q.Post(Event{Type: EVENT_TEXTINPUT, Text: 'x'})
+	evs := q.Drain()
+	if len(evs) != 2 || evs[0] != move(2, 0) || evs[1].Text != 'x' {
+		t.Errorf("bad drain %+v", evs)
+	}
+	testhelpers.AssertInt(t, 0, len(q.Drain()))
+
+	q.Close()
+	if _, ok := <-q.Events(); ok {
+		t.Errorf("Events not closed")
+	}
+}
@@ -15,12 +15,12 @@
 package content
 
 import (
-	"image"
+	"image/color"
+	"io"
 	"log"
 
 	"github.com/google/gojiraw/content/dom"
 	"github.com/google/gojiraw/graphics"
-	"github.com/go-gl/gl"
 )
 
 // Frame is the Gojira equivalent of a RenderFrame in Chrome?
@@ -35,15 +35,11 @@ type Frame struct {
 	// (Note(vollick): Pages are the things that own frame trees. I think
 	// that's analogous to windows. Let's move the page code into window.)
 
-	// Translation
-	// TODO(vollick): Probably want a transform here to capture scaling.
-	x, y, z float32
-
-	// Viewport
-	w, h float32
-
 	// The most recently mouse-overed element or nil.
-	overElement *dom.QuadElement
+	overElement dom.Element
+
+	// The element receiving key events or nil.
+	focus dom.Element
 
 	// The mouse is down.
 	mouseDown bool
@@ -52,116 +48,429 @@ type Frame struct {
 	// TODO(vollick): It's fishy that Frame knows anything about "handles."
 	offset graphics.Pointf
 
+	// Maps document coordinates into those of the element being dragged.
+	toLocal graphics.Matrix
+
+	// Where the dragged vertex started.
+	dragFrom   graphics.Pointf
+	dragVertex int
+
+	// The other selected vertices that move with the dragged one and where
+	// the dragged vertex started in document coordinates.
+	dragOthers []dragged
+	dragOrigin graphics.Pointf
+
+	// Dragging a vertex of an element selected with others moves the whole
+	// selection: the edit made so far and how far it moved in document
+	// coordinates.
+	dragAll   bool
+	dragMove  Command
+	dragMoved graphics.Pointf
+
+	// What dragged vertices snap to, whether snapping is suppressed for the
+	// current move and the guides explaining the current snap.
+	snapping Snapping
+	snapOff  bool
+	guides   []guide
+
+	// What is selected.
+	selection Selection
+
+	// A rubber band selection is in progress from marqueeFrom to marqueeTo
+	// in document coordinates.
+	marquee                bool
+	marqueeFrom, marqueeTo graphics.Pointf
+
+	// The edits that can be undone.
+	history History
+
 	// The root of the document.
-	document []dom.QuadElement
-}
-
-// AddElement extends the document slice and fills in the new element with a
-// quad.
-func (f *Frame) AddElement(p image.Point) {
-	ne := len(f.document)
-	nd := f.document[0 : ne+1]
-	pf := graphics.Ptfi(p)
-	// Note: the following code initializes the new point into the allocated
-	// memory in the document list. It does *not* resize the list; if we have
-	// more than 1000 quads, we'll die.
-	// TODO(vollick): make this dynamic.
-	(&nd[ne]).Init(pf)
-	f.document = nd
-}
-
-// Find the control point, if any, under Point p. Return nil, 0 if there is no
-// control point for an element under p. The returned int is the index
-// of the vertex.
-// TODO(rjkroege): The model could be a tree eventually. :-)
-func (f *Frame) FindElementAtPoint(p image.Point) (*dom.QuadElement, int) {
-	pf := graphics.Ptfi(p)
-	log.Printf("FindElementAtPoint %v", p)
-	for i := len(f.document) - 1; i >= 0; i-- {
-		if v := f.document[i].FindVertex(pf); v != -1 {
-			return &f.document[i], v
+	document *dom.Document
+
+	// The size of a screen pixel in document coordinates as of the most
+	// recent DisplayList.
+	pixel float32
+}
+
+// AddElement adds a new quad element centred on p to the top of the document
+// and returns its ID.
+func (f *Frame) AddElement(p graphics.Pointf) dom.ElementID {
+	qe := new(dom.QuadElement)
+	qe.Init(p)
+	f.do(&addElement{parent: dom.NO_ELEMENT, index: f.document.Len(), e: qe})
+	return qe.ID()
+}
+
+// DeleteElement removes the element with the given id from the document,
+// dropping any hover or drag state that refers to it. Returns false if there
+// is no such element.
+func (f *Frame) DeleteElement(id dom.ElementID) bool {
+	return f.do(&deleteElement{id: id}) == nil
+}
+
+// removeElement deletes the element with the given id without recording
+// an edit.
+func (f *Frame) removeElement(id dom.ElementID) bool {
+	e := f.document.Delete(id)
+	if e == nil {
+		return false
+	}
+	over := f.overElement != nil && dom.IsAncestor(e, f.overElement)
+	// The edit of a selection drag could name the deleted element.
+	if f.mouseDown && (over || f.dragAll) {
+		f.abandonDrag()
+		over = true
+	}
+	if over {
+		f.overElement = nil
+	}
+	// Vertices of deleted elements no longer move with the drag.
+	others := f.dragOthers[:0]
+	for _, o := range f.dragOthers {
+		if f.document.Get(o.id) != nil {
+			others = append(others, o)
 		}
 	}
-	return nil, -1
+	f.dragOthers = others
+	if f.focus != nil && dom.IsAncestor(e, f.focus) {
+		f.focus = nil
+	}
+	f.selection.prune(f.document)
+	return true
 }
 
-// Adjusts visual style for elements that are under the
-// mouse pointer.
-func (f *Frame) MouseOver(qe *dom.QuadElement, v int) {
-	log.Printf("MouseOver: %+v, %d", qe, v)
-	if f.overElement != nil && f.overElement != qe {
-		f.overElement.HoverOff()
+// Focus gives e the keyboard focus or, if e is nil, takes the focus away
+// from whichever element has it.
+func (f *Frame) Focus(e dom.Element) {
+	if e == f.focus {
+		return
+	}
+	if old := f.focus; old != nil {
+		f.focus = nil
+		f.dispatch(old, &dom.Event{Type: dom.EVENT_BLUR})
 	}
-	f.overElement = qe
-	if qe != nil {
-		qe.HoverOn(v)
+	f.focus = e
+	if e != nil {
+		f.dispatch(e, &dom.Event{Type: dom.EVENT_FOCUS})
 	}
 }
 
-// Pan sets a translation on the Frame to permit the Frame to move around within
-// its viewport. Translates the viewport w.r.t. the Frame origin by p.
-func (f *Frame) Pan(dx, dy float32) {
-	f.x = graphics.MinF(f.x+dx, f.w)
-	f.y += graphics.MinF(f.y+dy, f.h)
-	log.Printf("translation: %d %d", f.x, f.y)
+// Focused returns the element with the keyboard focus or nil.
+func (f *Frame) Focused() dom.Element {
+	return f.focus
 }
 
-// Resize tells the frame what its size should be.
-func (f *Frame) Resize(w, h float32) {
-	f.w = graphics.MaxF(w, f.w)
-	f.h = graphics.MaxF(h, f.h)
-	log.Printf("current size: %d %d", f.w, f.h)
+// FocusNext moves the focus to the next (or previous) element in paint
+// order, wrapping around at the end. Groups don't take the focus.
+func (f *Frame) FocusNext(backwards bool) {
+	var order []dom.Element
+	current := -1
+	dom.Walk(f.document.Root(), func(e dom.Element) {
+		if _, ok := e.(*dom.GroupElement); !ok {
+			if e == f.focus {
+				current = len(order)
+			}
+			order = append(order, e)
+		}
+	})
+	if len(order) == 0 {
+		return
+	}
+
+	next := current + 1
+	if backwards {
+		next = current - 1
+		if current < 0 {
+			next = len(order) - 1
+		}
+	}
+	f.Focus(order[(next+len(order))%len(order)])
 }
 
-func NewFrame() *Frame {
-	// TODO(vollick): allow more than 1000 things.
-	d := make([]dom.QuadElement, 0, 1000)
-	return &Frame{document: d}
+// GroupElements gathers the sibling elements with the given ids into a new
+// group and returns its ID.
+func (f *Frame) GroupElements(ids ...dom.ElementID) (dom.ElementID, error) {
+	c := &groupElements{ids: ids}
+	if err := f.do(c); err != nil {
+		return dom.NO_ELEMENT, err
+	}
+	return c.g.ID(), nil
 }
 
-// TODO(rjk): Tell the Frame to clip its drawing to a given viewport.
-// x, y is the position of the Frame's origin in the containing port.
-// w, h is the width and height of the port in the port's coordinates.
-// Returns the enclosing boundary of the Frame.
-// TODO(rjkroege): boundaries should admit objects outside [0, w), [0. h)?
-// TODO(rjkroege): Provide and wire in types for stuff, boxes, etc.
-func (frame *Frame) Draw(x, y, vw, vh float32, program *gl.Program) (fw, fh float32) {
-	// Build the display list.
-	dl := &graphics.DisplayList{}
-	for _, e := range frame.document {
-		e.Draw(dl)
+// UngroupElement replaces the group with the given id by its children.
+func (f *Frame) UngroupElement(id dom.ElementID) error {
+	return f.do(&ungroupElement{id: id})
+}
+
+// MoveElement translates the element with the given id by d, given in
+// document coordinates. Returns false if there is no such element.
+func (f *Frame) MoveElement(id dom.ElementID, d graphics.Pointf) bool {
+	return f.do(&moveElement{id: id, d: d}) == nil
+}
+
+// RestyleElement sets the color of the element with the given id. Returns
+// false if there is no such element or it has no color.
+func (f *Frame) RestyleElement(id dom.ElementID, c color.RGBA) bool {
+	e, ok := f.document.Get(id).(styler)
+	if !ok {
+		return false
+	}
+	return f.do(&restyleElement{id: id, from: e.Color(), to: c}) == nil
+}
+
+// do makes the edit c and records it for undoing.
+func (f *Frame) do(c Command) error {
+	return f.history.Do(f, c)
+}
+
+// History returns the Frame's undo and redo stacks.
+func (f *Frame) History() *History {
+	return &f.history
+}
+
+// Undo reverts the most recent edit. It returns false if there is nothing
+// to undo or a drag is in progress.
+func (f *Frame) Undo() bool {
+	if f.mouseDown {
+		return false
+	}
+	ok, err := f.history.Undo(f)
+	if err != nil {
+		log.Print("undo: ", err)
+	}
+	return ok
+}
+
+// Redo makes the most recently undone edit again. It returns false if there
+// is nothing to redo or a drag is in progress.
+func (f *Frame) Redo() bool {
+	if f.mouseDown {
+		return false
+	}
+	ok, err := f.history.Redo(f)
+	if err != nil {
+		log.Print("redo: ", err)
+	}
+	return ok
+}
+
+// Document returns the Frame's element store.
+func (f *Frame) Document() *dom.Document {
+	return f.document
+}
+
+// Load replaces the Frame's document with one read from r by dom.Load. The
+// History is forgotten. The document is unchanged if r can't be read.
+func (f *Frame) Load(r io.Reader) error {
+	d, err := dom.Load(r)
+	if err != nil {
+		return err
+	}
+	f.document = d
+	f.overElement = nil
+	f.focus = nil
+	f.mouseDown = false
+	f.marquee = false
+	f.selection.Clear()
+	f.history.Clear()
+	return nil
+}
+
+// Save writes the Frame's document to w in the given format.
+func (f *Frame) Save(w io.Writer, format dom.Format) error {
+	return f.document.Save(w, format)
+}
+
+// Find the control point, if any, under Point p. Return nil, -1 if there is
+// no control point for an element under p. The returned int is the index
+// of the vertex. Elements nested in groups are hit in their own coordinates.
+func (f *Frame) FindElementAtPoint(p graphics.Pointf) (dom.Element, int) {
+	log.Printf("FindElementAtPoint %v", p)
+	return f.document.Root().HitTest(p, f.pixel)
+}
+
+// Adjusts visual style for elements that are under the
+// mouse pointer.
+func (f *Frame) MouseOver(e dom.Element, v int) {
+	log.Printf("MouseOver: %+v, %d", e, v)
+	if f.overElement != nil && f.overElement != e {
+		f.overElement.HoverOff()
+	}
+	f.overElement = e
+	if e != nil {
+		e.HoverOn(v)
 	}
+}
 
-	gl.ClearColor(1, 1, 1, 0)
-	graphics.CheckForGLErrors()
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-	graphics.CheckForGLErrors()
+func NewFrame() *Frame {
+	return &Frame{document: dom.NewDocument(), pixel: 1}
+}
 
-	gl.Enable(gl.BLEND)
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
-	graphics.CheckForGLErrors()
+// DisplayList records the Frame's content transformed by m. Handles and
+// the focus ring keep their size on screen and hit testing follows the
+// scale of m from then on.
+func (f *Frame) DisplayList(m graphics.Matrix) *graphics.DisplayList {
+	f.pixel = graphics.PixelSize(m)
+	dl := &graphics.DisplayList{}
+	dl.Transform(m)
+	f.document.Root().Draw(dl)
+	f.drawSelection(dl)
+	f.drawMarquee(dl)
+	f.drawGuides(dl)
+	f.drawFocusRing(dl)
+	return dl
+}
 
-	dl.Draw(program, vw, vh)
+// Extent returns the extent of the content in the Frame's own coordinates
+// so that a viewport can limit scrolling.
+// TODO(rjkroege): boundaries should admit objects outside [0, w), [0. h)?
+// TODO(rjkroege): Provide and wire in types for stuff, boxes, etc.
+func (f *Frame) Extent() (fw, fh float32) {
+	r := f.document.Root().Bounds()
+	return r.Max.X, r.Max.Y
+}
 
-	return dl.W, dl.H
+// dragged is a vertex moved along with the one being dragged.
+type dragged struct {
+	e       vertexSetter
+	id      dom.ElementID
+	v       int
+	from    graphics.Pointf
+	toLocal graphics.Matrix
 }
 
-func (f *Frame) StartMouseDownMode(pt image.Point, qe *dom.QuadElement, v int) {
-	f.overElement = qe
+// StartMouseDownMode starts dragging vertex v of e from pt. If the vertex
+// is selected, the other selected vertices move with it. If e is selected
+// with other elements or vertices, the whole selection moves instead.
+func (f *Frame) StartMouseDownMode(pt graphics.Pointf, e dom.Element, v int) {
+	f.overElement = e
 	f.mouseDown = true
-	pf := graphics.Ptfi(pt)
-	f.offset = pf.Sub(qe.ActivateVertex(v))
+	f.toLocal, _ = dom.LocalToDocument(e).Invert()
+	pf := f.toLocal.Transform(pt)
+	f.dragFrom = e.ActivateVertex(v)
+	f.dragVertex = v
+	f.offset = pf.Sub(f.dragFrom)
+
+	f.dragOrigin = dom.LocalToDocument(e).Transform(f.dragFrom)
+	f.dragOthers = nil
+	f.dragAll, f.dragMove, f.dragMoved = false, nil, graphics.Pointf{}
+	if f.selection.Contains(e.ID()) && f.selection.Len() > 1 {
+		f.dragAll = true
+		return
+	}
+	if !f.selection.ContainsVertex(e.ID(), v) {
+		return
+	}
+	for _, sv := range f.selection.Vertices() {
+		o, ok := f.document.Get(sv.ID).(vertexSetter)
+		if !ok || sv.ID == e.ID() && sv.V == v {
+			continue
+		}
+		toLocal, _ := dom.LocalToDocument(f.document.Get(sv.ID)).Invert()
+		f.dragOthers = append(f.dragOthers, dragged{o, sv.ID, sv.V, o.Vertex(sv.V), toLocal})
+	}
 }
 
-func (f *Frame) InMouseDownMode(pt image.Point) {
+// InMouseDownMode moves the dragged vertex to follow pt, snapping it as
+// SetSnapping says.
+func (f *Frame) InMouseDownMode(pt graphics.Pointf) {
+	e := f.overElement
+	if e == nil {
+		return
+	}
+	if f.dragAll {
+		f.dragSelection(pt)
+		return
+	}
 	// Is this idiomatic?
-	pf := graphics.Ptfi(pt)
-	if qe := f.overElement; qe != nil {
-		qe.SetActiveVertex(pf.Add(f.offset))
+	pf := f.toLocal.Transform(pt).Add(f.offset)
+	toDoc := dom.LocalToDocument(e)
+	p := toDoc.Transform(pf)
+
+	f.guides = nil
+	if f.snapping != (Snapping{}) && !f.snapOff {
+		exclude := map[SelectedVertex]bool{{e.ID(), f.dragVertex}: true}
+		for _, o := range f.dragOthers {
+			exclude[SelectedVertex{o.id, o.v}] = true
+		}
+		p, f.guides = f.snap(p, exclude)
+		pf = f.toLocal.Transform(p)
+	}
+	e.SetActiveVertex(pf)
+
+	d := p.Sub(f.dragOrigin)
+	for _, o := range f.dragOthers {
+		o.e.SetVertex(o.v, o.from.Add(o.toLocal.TransformVector(d)))
 	}
 }
 
+// abandonDrag stops the drag of or including an element being deleted
+// without recording it. The mouse stays down so that releasing it isn't a click.
+func (f *Frame) abandonDrag() {
+	f.overElement.Deactivate()
+	f.dragOthers = nil
+	f.dragAll, f.dragMove = false, nil
+	f.guides = nil
+}
+
+// EndMouseDownMode finishes a drag, recording it as a single edit. Nothing
+// is recorded if the dragged element was deleted.
 func (f *Frame) EndMouseDownMode() {
 	f.mouseDown = false
-	f.overElement.Deactivate()
+	e := f.overElement
+	if e == nil {
+		return
+	}
+	e.Deactivate()
+	if f.dragAll {
+		if f.dragMove != nil {
+			f.history.push(f.dragMove, false)
+		}
+		f.dragAll, f.dragMove = false, nil
+		f.guides = nil
+		return
+	}
+	var cs commands
+	if vs, ok := e.(vertexSetter); ok {
+		if to := vs.Vertex(f.dragVertex); !to.Eq(f.dragFrom) {
+			cs = append(cs, &moveVertex{id: e.ID(), v: f.dragVertex, from: f.dragFrom, to: to})
+		}
+	}
+	for _, o := range f.dragOthers {
+		if to := o.e.Vertex(o.v); !to.Eq(o.from) {
+			cs = append(cs, &moveVertex{id: o.id, v: o.v, from: o.from, to: to})
+		}
+	}
+	f.dragOthers = nil
+	f.guides = nil
+	if c := cs.single(); c != nil {
+		f.history.push(c, false)
+	}
+}
+
+var focusRingColor = color.RGBA{0x40, 0x80, 0xff, 0xc0}
+
+// drawFocusRing outlines the bounds of the focused element.
+func (f *Frame) drawFocusRing(dl *graphics.DisplayList) {
+	if f.focus == nil {
+		return
+	}
+	r := dom.LocalToDocument(f.focus).TransformRect(f.focus.Bounds())
+	dl.SetColor(focusRingColor)
+	drawOutline(dl, r, f.pixel)
+}
+
+// drawOutline draws a band w wide around the outside of r.
+func drawOutline(dl *graphics.DisplayList, r graphics.Rectanglef, w float32) {
+	dl.DrawQuads([][4]graphics.Pointf{
+		rectQuad(r.Min.X-w, r.Min.Y-w, r.Max.X+w, r.Min.Y),
+		rectQuad(r.Max.X, r.Min.Y, r.Max.X+w, r.Max.Y+w),
+		rectQuad(r.Min.X-w, r.Max.Y, r.Max.X, r.Max.Y+w),
+		rectQuad(r.Min.X-w, r.Min.Y, r.Min.X, r.Max.Y),
+	})
+}
+
+// rectQuad returns the quad for the rectangle x0, y0, x1, y1.
+func rectQuad(x0, y0, x1, y1 float32) [4]graphics.Pointf {
+	return [4]graphics.Pointf{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
 }
@@ -15,36 +15,284 @@
 package content
 
 import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+
 	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
 	"github.com/rjkroege/wikitools/testhelpers"
-	"image"
 	"testing"
 )
 
 func Test_FindElementAtPoint(t *testing.T) {
 	f := NewFrame()
-	testhelpers.AssertInt(t, 0, len(f.document))
+	testhelpers.AssertInt(t, 0, f.document.Len())
 
-	p := image.Pt(10, 10)
+	p := graphics.Ptf(10, 10)
 
 	e, _ := f.FindElementAtPoint(p)
 	if e != nil {
 		t.Errorf("empty display list can't have element in it")
 	}
 
-	f.AddElement(image.Pt(30, 10))
-	f.AddElement(image.Pt(10, 30))
+	f.AddElement(graphics.Ptf(30, 10))
+	f.AddElement(graphics.Ptf(10, 30))
 
 	e, v := f.FindElementAtPoint(p)
 	if e != nil {
 		t.Errorf("despite a point outside the elements, we hit anyway: %+v", e)
 	}
 
-	e, v = f.FindElementAtPoint(image.Pt(30-dom.QUAD_ELEMENT_DX, 10+dom.QUAD_ELEMENT_DY))
-	if e == nil || *e != f.document[0] {
-		t.Errorf("point 30,10 in %+v but found element is %+v", f.document[0], e)
+	e, v = f.FindElementAtPoint(graphics.Ptf(30-dom.QUAD_ELEMENT_DX, 10+dom.QUAD_ELEMENT_DY))
+	if e == nil || e != f.document.At(0) {
+		t.Errorf("point 30,10 in %+v but found element is %+v", f.document.At(0), e)
 	}
 	if v != 3 {
 		t.Errorf("desired vertex 3 but didn't get it %d", v)
 	}
 }
+
+func Test_AddManyElements(t *testing.T) {
+	f := NewFrame()
+	for i := 0; i < 1500; i++ {
+		f.AddElement(graphics.Ptf(float32(i), float32(i)))
+	}
+	testhelpers.AssertInt(t, 1500, f.document.Len())
+
+	e, v := f.FindElementAtPoint(graphics.Ptf(1499-dom.QUAD_ELEMENT_DX, 1499-dom.QUAD_ELEMENT_DY))
+	if e != f.document.At(1499) || v != 0 {
+		t.Errorf("expected vertex 0 of the topmost element, got %+v, %d", e, v)
+	}
+}
+
+func Test_DeleteElement(t *testing.T) {
+	f := NewFrame()
+	f.AddElement(graphics.Ptf(100, 100))
+	id := f.AddElement(graphics.Ptf(300, 300))
+
+	e, v := f.FindElementAtPoint(graphics.Ptf(300-dom.QUAD_ELEMENT_DX, 300-dom.QUAD_ELEMENT_DY))
+	f.StartMouseDownMode(graphics.Ptf(300-dom.QUAD_ELEMENT_DX, 300-dom.QUAD_ELEMENT_DY), e, v)
+
+	// Growing the document must not disturb the element being dragged.
+	for i := 0; i < 1000; i++ {
+		f.AddElement(graphics.Ptf(1000, 1000))
+	}
+	if f.overElement != f.document.Get(id) {
+		t.Errorf("drag element moved: %+v", f.overElement)
+	}
+
+	if !f.DeleteElement(id) {
+		t.Errorf("failed to delete element %d", id)
+	}
+	if f.overElement != nil {
+		t.Errorf("deleting the dragged element left drag state behind")
+	}
+	if f.DeleteElement(id) {
+		t.Errorf("deleted element %d twice", id)
+	}
+	testhelpers.AssertInt(t, 1001, f.document.Len())
+
+	// Releasing the mouse ends the drag rather than adding an element.
+	f.Mouseup(MouseEvent{Point: graphics.Ptf(600, 600), ClickCount: 1})
+	if f.mouseDown {
+		t.Errorf("releasing the mouse didn't end the drag")
+	}
+	testhelpers.AssertInt(t, 1001, f.document.Len())
+}
+
+func Test_DragNestedVertex(t *testing.T) {
+	f := NewFrame()
+	id := f.AddElement(graphics.Ptf(100, 100))
+	g, err := f.GroupElements(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.document.Get(g).(*dom.GroupElement).SetTransform(graphics.Scale(2, 2))
+	f.MoveElement(g, graphics.Pointf{100, 0})
+
+	// Vertex 2 is at (145, 145) locally.
+	e, v := f.FindElementAtPoint(graphics.Ptf(390, 290))
+	if e != f.document.Get(id) || v != 2 {
+		t.Fatalf("missed nested vertex: %v %d", e, v)
+	}
+
+	f.StartMouseDownMode(graphics.Ptf(391, 290), e, v)
+	f.InMouseDownMode(graphics.Ptf(411, 300))
+	f.EndMouseDownMode()
+
+	// Moved by (10, 5) in local coordinates.
+	if e, v := f.FindElementAtPoint(graphics.Ptf(410, 300)); e != f.document.Get(id) || v != 2 {
+		t.Errorf("vertex not dragged in local coordinates: %v %d", e, v)
+	}
+}
+
+func Test_PreventDefault(t *testing.T) {
+	f := NewFrame()
+	id := f.AddElement(graphics.Ptf(100, 100))
+	pt := graphics.Ptf(100-dom.QUAD_ELEMENT_DX, 100-dom.QUAD_ELEMENT_DY)
+
+	testhelpers.AssertInt(t, EVD_NON, int(f.Mousedown(MouseEvent{Point: pt, Buttons: MOUSE_BUTTON_LEFT})))
+	testhelpers.AssertInt(t, EVD_NON, int(f.Mouseup(MouseEvent{Point: pt})))
+	if f.mouseDown {
+		t.Errorf("drag didn't end")
+	}
+
+	// A listener on the document suppresses the drag and the click that
+	// would add an element.
+	f.document.Root().AddEventListener(dom.EVENT_MOUSEDOWN, false, func(ev *dom.Event) {
+		if ev.Target.ID() == id {
+			ev.PreventDefault()
+		}
+	})
+	f.document.Root().AddEventListener(dom.EVENT_MOUSEUP, true, func(ev *dom.Event) {
+		ev.PreventDefault()
+	})
+	testhelpers.AssertInt(t, EVD_PREVDEF, int(f.Mousedown(MouseEvent{Point: pt, Buttons: MOUSE_BUTTON_LEFT})))
+	if f.mouseDown {
+		t.Errorf("drag started despite PreventDefault")
+	}
+	testhelpers.AssertInt(t, EVD_PREVDEF, int(f.Mouseup(MouseEvent{Point: graphics.Ptf(500, 500)})))
+	testhelpers.AssertInt(t, 1, f.document.Len())
+
+	// Listeners that don't prevent the default.
+	testhelpers.AssertInt(t, EVD_DEF, int(f.Mousedown(MouseEvent{Point: graphics.Ptf(500, 500), Buttons: MOUSE_BUTTON_LEFT})))
+}
+
+func Test_KeyboardFocus(t *testing.T) {
+	f := NewFrame()
+	a := f.AddElement(graphics.Ptf(100, 100))
+	b := f.AddElement(graphics.Ptf(300, 100))
+
+	var keys []int
+	f.document.Get(b).AddEventListener(dom.EVENT_KEYDOWN, false, func(ev *dom.Event) {
+		keys = append(keys, ev.Key)
+	})
+
+	// Clicking on a vertex focuses its element.
+	f.Mousedown(MouseEvent{Point: graphics.Ptf(300-dom.QUAD_ELEMENT_DX, 100-dom.QUAD_ELEMENT_DY), Buttons: MOUSE_BUTTON_LEFT})
+	f.Mouseup(MouseEvent{Point: graphics.Ptf(300-dom.QUAD_ELEMENT_DX, 100-dom.QUAD_ELEMENT_DY)})
+	if f.Focused() != f.document.Get(b) {
+		t.Fatalf("click didn't focus: %v", f.Focused())
+	}
+	f.Keydown(KEY_Q, MOD_NONE, false)
+	if len(keys) != 1 || keys[0] != int(KEY_Q) {
+		t.Errorf("focused element didn't get the key: %v", keys)
+	}
+
+	// Arrows nudge the focused element.
+	f.Keydown(KEY_RIGHT, MOD_SHIFT, false)
+	if r := f.document.Get(b).Bounds(); r.Min.X != 265 {
+		t.Errorf("element not nudged: %v", r)
+	}
+
+	f.FocusNext(false)
+	if f.Focused() != f.document.Get(a) {
+		t.Errorf("focus didn't wrap around: %v", f.Focused())
+	}
+	f.Keydown(KEY_TAB, MOD_SHIFT, false)
+	if f.Focused() != f.document.Get(b) {
+		t.Errorf("shift-tab didn't go backwards: %v", f.Focused())
+	}
+
+	testhelpers.AssertInt(t, EVD_PREVDEF, int(f.Keydown(KEY_DELETE, MOD_NONE, false)))
+	if f.Focused() != nil || f.document.Get(b) != nil {
+		t.Errorf("delete didn't remove the focused element")
+	}
+	f.Keydown(KEY_ESCAPE, MOD_NONE, false)
+	testhelpers.AssertInt(t, EVD_NON, int(f.Textinput('x')))
+}
+
+func Test_ZoomedHitTest(t *testing.T) {
+	f := NewFrame()
+	f.AddElement(graphics.Ptf(100, 100))
+
+	// Vertex 0 is at (55, 55). 3 document units away is too far once zoomed.
+	p := graphics.Ptf(58, 58)
+	if e, v := f.FindElementAtPoint(p); e == nil || v != 0 {
+		t.Errorf("missed vertex at zoom 1: %v %d", e, v)
+	}
+	f.DisplayList(graphics.Scale(4, 4))
+	if e, _ := f.FindElementAtPoint(p); e != nil {
+		t.Errorf("handle grew with the zoom: %v", e)
+	}
+	if e, v := f.FindElementAtPoint(graphics.Ptf(55, 55)); e == nil || v != 0 {
+		t.Errorf("missed vertex at zoom 4: %v %d", e, v)
+	}
+}
+
+func Test_DoubleClick(t *testing.T) {
+	f := NewFrame()
+	var detail []int
+	var mods []uint32
+	f.document.Root().AddEventListener(dom.EVENT_MOUSEUP, false, func(ev *dom.Event) {
+		detail = append(detail, ev.Detail)
+		mods = append(mods, ev.Modifiers)
+	})
+
+	// The first click of a double click adds an element but the second
+	// doesn't add another.
+	pt := graphics.Ptf(500.5, 500.25)
+	f.Mousedown(MouseEvent{Point: pt, Buttons: MOUSE_BUTTON_LEFT, ClickCount: 1})
+	f.Mouseup(MouseEvent{Point: pt, ClickCount: 1})
+	f.Mousedown(MouseEvent{Point: pt, Buttons: MOUSE_BUTTON_LEFT, ClickCount: 2, Modifiers: MOD_SHIFT})
+	f.Mouseup(MouseEvent{Point: pt, ClickCount: 2, Modifiers: MOD_SHIFT})
+	testhelpers.AssertInt(t, 1, f.document.Len())
+	if len(detail) != 2 || detail[1] != 2 || mods[1] != MOD_SHIFT {
+		t.Errorf("click count or modifiers lost: %v %v", detail, mods)
+	}
+
+	// The element is centred on the sub-pixel point.
+	if r := f.document.At(0).Bounds(); r.Min.X != 500.5-dom.QUAD_ELEMENT_DX || r.Min.Y != 500.25-dom.QUAD_ELEMENT_DY {
+		t.Errorf("click point rounded: %v", r)
+	}
+}
+
+func Test_FrameSaveLoad(t *testing.T) {
+	f := NewFrame()
+	f.AddElement(graphics.Ptf(100, 100))
+	f.AddElement(graphics.Ptf(300, 100))
+	var b bytes.Buffer
+	if err := f.Save(&b, dom.FORMAT_BINARY); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewFrame()
+	g.AddElement(graphics.Ptf(0, 0))
+	if err := g.Load(&b); err != nil {
+		t.Fatal(err)
+	}
+	testhelpers.AssertInt(t, 2, g.Document().Len())
+	testhelpers.AssertInt(t, 0, g.History().Len())
+	if e, v := g.FindElementAtPoint(graphics.Ptf(345, 145)); e != g.Document().At(1) || v != 2 {
+		t.Errorf("loaded document not hit: %v %d", e, v)
+	}
+
+	if err := g.Load(strings.NewReader("junk")); err == nil {
+		t.Error("loaded junk")
+	}
+	testhelpers.AssertInt(t, 2, g.Document().Len())
+}
+
+func Test_ConcaveQuadFill(t *testing.T) {
+	f := NewFrame()
+	f.AddElement(graphics.Ptf(100, 100))
+
+	// Drag vertex 1 past the diagonal from vertex 0 to vertex 2 to make a
+	// notch.
+	e, v := f.FindElementAtPoint(graphics.Ptf(145, 55))
+	f.StartMouseDownMode(graphics.Ptf(145, 55), e, v)
+	f.InMouseDownMode(graphics.Ptf(90, 110))
+	f.EndMouseDownMode()
+	f.MouseOver(nil, -1)
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	f.DisplayList(graphics.Identity()).Rasterize(img)
+	if c := img.RGBAAt(96, 103); c != (color.RGBA{}) {
+		t.Errorf("notch filled: %v", c)
+	}
+	if c := img.RGBAAt(70, 130); c != (color.RGBA{0, 0, 0, 25}) {
+		t.Errorf("quad not filled: %v", c)
+	}
+}
@@ -0,0 +1,517 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+)
+
+// Command is an undoable edit of a Frame's document. Commands name
+// elements by ID so that they stay valid as other commands are undone and
+// redone around them.
+type Command interface {
+	// Do makes the edit. It is called again to redo the edit after Undo.
+	Do(f *Frame) error
+
+	// Undo reverts the edit made by Do.
+	Undo(f *Frame) error
+
+	// Size estimates the memory held by the Command in bytes.
+	Size() int
+}
+
+// merger is implemented by Commands that can absorb a following Command,
+// already done, into themselves.
+type merger interface {
+	merge(c Command) bool
+}
+
+// The default limit on the memory held by a History and the estimated
+// bookkeeping cost of a Command.
+const (
+	HISTORY_LIMIT = 8 << 20
+	COMMAND_SIZE  = 64
+)
+
+var errNoElement = errors.New("content: the element of an edit is gone")
+
+// History is the undo and redo stacks of a Frame. The oldest edits are
+// forgotten when the Commands held exceed the limit.
+type History struct {
+	undo, redo []Command
+	size       int
+
+	// The limit in bytes. 0 means HISTORY_LIMIT.
+	limit int
+}
+
+// SetLimit sets the most memory the History may hold in bytes. The most
+// recent edit is kept whatever its size. 0 restores HISTORY_LIMIT.
+func (h *History) SetLimit(bytes int) {
+	h.limit = bytes
+	h.trim()
+}
+
+// CanUndo reports whether there is an edit to undo.
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+// CanRedo reports whether there is an undone edit to redo.
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
+
+// Len returns the number of edits that can be undone.
+func (h *History) Len() int {
+	return len(h.undo)
+}
+
+// Clear forgets all edits.
+func (h *History) Clear() {
+	h.undo, h.redo, h.size = nil, nil, 0
+}
+
+// Do makes the edit c and records it for undoing. Undone edits can't be
+// redone after a new edit.
+func (h *History) Do(f *Frame, c Command) error {
+	if err := c.Do(f); err != nil {
+		return err
+	}
+	h.push(c, false)
+	return nil
+}
+
+// push records the edit c, which has been made. If coalesce is set, c may
+// be merged into the most recent edit.
+func (h *History) push(c Command, coalesce bool) {
+	for _, r := range h.redo {
+		h.size -= r.Size()
+	}
+	h.redo = nil
+
+	if n := len(h.undo); coalesce && n > 0 {
+		if m, ok := h.undo[n-1].(merger); ok {
+			before := h.undo[n-1].Size()
+			if m.merge(c) {
+				h.size += h.undo[n-1].Size() - before
+				return
+			}
+		}
+	}
+	h.undo = append(h.undo, c)
+	h.size += c.Size()
+	h.trim()
+}
+
+func (h *History) trim() {
+	limit := h.limit
+	if limit == 0 {
+		limit = HISTORY_LIMIT
+	}
+	drop := 0
+	for h.size > limit && drop < len(h.undo)-1 {
+		h.size -= h.undo[drop].Size()
+		drop++
+	}
+	h.undo = h.undo[drop:]
+}
+
+// Undo reverts the most recent edit. It returns false if there is none. If
+// the edit can't be reverted, the History is cleared and the error returned.
+func (h *History) Undo(f *Frame) (bool, error) {
+	n := len(h.undo)
+	if n == 0 {
+		return false, nil
+	}
+	c := h.undo[n-1]
+	h.undo = h.undo[:n-1]
+	if err := c.Undo(f); err != nil {
+		h.Clear()
+		return false, err
+	}
+	h.redo = append(h.redo, c)
+	return true, nil
+}
+
+// Redo makes the most recently undone edit again. It returns false if there
+// is none. If the edit can't be made, the History is cleared and the error
+// returned.
+func (h *History) Redo(f *Frame) (bool, error) {
+	n := len(h.redo)
+	if n == 0 {
+		return false, nil
+	}
+	c := h.redo[n-1]
+	h.redo = h.redo[:n-1]
+	if err := c.Do(f); err != nil {
+		h.Clear()
+		return false, err
+	}
+	h.undo = append(h.undo, c)
+	return true, nil
+}
+
+// elementSize estimates the memory held by e from its serialized size.
+func elementSize(e dom.Element) int {
+	b, err := dom.MarshalElement(e)
+	if err != nil {
+		return COMMAND_SIZE
+	}
+	return COMMAND_SIZE + len(b)
+}
+
+// commands is an edit made of several Commands. They are done in order and
+// undone in reverse order.
+type commands []Command
+
+func (cs commands) Do(f *Frame) error {
+	for i, c := range cs {
+		if err := c.Do(f); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				cs[j].Undo(f)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (cs commands) Undo(f *Frame) error {
+	for i := len(cs) - 1; i >= 0; i-- {
+		if err := cs[i].Undo(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cs commands) Size() int {
+	n := 0
+	for _, c := range cs {
+		n += c.Size()
+	}
+	return n
+}
+
+// merge merges edits of the same things made by the same kinds of
+// Commands.
+func (cs commands) merge(o Command) bool {
+	os, ok := o.(commands)
+	if !ok || len(os) != len(cs) {
+		return false
+	}
+	for i := range cs {
+		if !sameTarget(cs[i], os[i]) {
+			return false
+		}
+	}
+	for i := range cs {
+		cs[i].(merger).merge(os[i])
+	}
+	return true
+}
+
+// sameTarget reports whether a and b are mergeable Commands editing the
+// same thing.
+func sameTarget(a, b Command) bool {
+	switch a := a.(type) {
+	case *moveElement:
+		b, ok := b.(*moveElement)
+		return ok && a.id == b.id
+	case *moveVertex:
+		b, ok := b.(*moveVertex)
+		return ok && a.id == b.id && a.v == b.v
+	}
+	return false
+}
+
+// single returns cs as one Command or nil if cs is empty.
+func (cs commands) single() Command {
+	switch len(cs) {
+	case 0:
+		return nil
+	case 1:
+		return cs[0]
+	}
+	return cs
+}
+
+// addElement inserts an element into a group.
+type addElement struct {
+	parent dom.ElementID
+	index  int
+	e      dom.Element
+	size   int
+}
+
+func (c *addElement) Do(f *Frame) error {
+	_, err := f.document.Insert(c.parent, c.index, c.e)
+	return err
+}
+
+func (c *addElement) Undo(f *Frame) error {
+	if !f.removeElement(c.e.ID()) {
+		return errNoElement
+	}
+	return nil
+}
+
+func (c *addElement) Size() int {
+	if c.size == 0 {
+		c.size = elementSize(c.e)
+	}
+	return c.size
+}
+
+// deleteElement removes an element and puts it back where it was on undo.
+type deleteElement struct {
+	id     dom.ElementID
+	parent dom.ElementID
+	index  int
+	e      dom.Element
+	size   int
+}
+
+func (c *deleteElement) Do(f *Frame) error {
+	e := f.document.Get(c.id)
+	if e == nil {
+		return errNoElement
+	}
+	c.e = e
+	c.parent = e.Parent().ID()
+	c.index = e.Parent().IndexOf(e)
+	f.removeElement(c.id)
+	return nil
+}
+
+func (c *deleteElement) Undo(f *Frame) error {
+	_, err := f.document.Insert(c.parent, c.index, c.e)
+	return err
+}
+
+func (c *deleteElement) Size() int {
+	if c.size == 0 && c.e != nil {
+		c.size = elementSize(c.e)
+	}
+	return c.size
+}
+
+// groupElements gathers sibling elements into a new group. Redoing it uses
+// the same group so that later edits of the group stay valid.
+type groupElements struct {
+	ids []dom.ElementID
+	g   *dom.GroupElement
+
+	// Where the group and, in paint order, its members were.
+	parent  dom.ElementID
+	index   int
+	members []dom.ElementID
+	places  []int
+}
+
+func (c *groupElements) Do(f *Frame) error {
+	if c.g != nil {
+		for _, id := range c.members {
+			e := f.document.Get(id)
+			if e == nil {
+				return errNoElement
+			}
+			c.g.Append(e)
+		}
+		_, err := f.document.Insert(c.parent, c.index, c.g)
+		return err
+	}
+
+	places := make(map[dom.ElementID]int)
+	for _, id := range c.ids {
+		places[id] = f.document.IndexOf(id)
+	}
+	id, err := f.document.Group(c.ids...)
+	if err != nil {
+		return err
+	}
+	c.g = f.document.Get(id).(*dom.GroupElement)
+	c.parent = c.g.Parent().ID()
+	c.index = f.document.IndexOf(id)
+	for i := 0; i < c.g.Len(); i++ {
+		m := c.g.At(i).ID()
+		c.members = append(c.members, m)
+		c.places = append(c.places, places[m])
+	}
+	return nil
+}
+
+// Undo puts the members back where they were, in paint order so that each
+// lands at its old index.
+func (c *groupElements) Undo(f *Frame) error {
+	if err := f.document.Ungroup(c.g.ID()); err != nil {
+		return err
+	}
+	for i, id := range c.members {
+		f.document.MoveTo(id, c.places[i])
+	}
+	return nil
+}
+
+func (c *groupElements) Size() int {
+	return COMMAND_SIZE + 8*len(c.ids)
+}
+
+// ungroupElement replaces a group by its children. Undoing it puts the
+// children back in the same group.
+type ungroupElement struct {
+	id dom.ElementID
+	g  *dom.GroupElement
+
+	// Where the group and, in paint order, its children were.
+	parent   dom.ElementID
+	index    int
+	children []dom.ElementID
+}
+
+func (c *ungroupElement) Do(f *Frame) error {
+	g, ok := f.document.Get(c.id).(*dom.GroupElement)
+	if !ok {
+		return errNoElement
+	}
+	if _, ok := g.Transform().Invert(); !ok {
+		return fmt.Errorf("content: group %d can't be ungrouped", c.id)
+	}
+	c.g = g
+	c.parent = g.Parent().ID()
+	c.index = g.Parent().IndexOf(g)
+	c.children = c.children[:0]
+	for i := 0; i < g.Len(); i++ {
+		c.children = append(c.children, g.At(i).ID())
+	}
+	return f.document.Ungroup(c.id)
+}
+
+func (c *ungroupElement) Undo(f *Frame) error {
+	toLocal, _ := c.g.Transform().Invert()
+	for _, id := range c.children {
+		e := f.document.Get(id)
+		if e == nil {
+			return errNoElement
+		}
+		e.ApplyTransform(toLocal)
+		c.g.Append(e)
+	}
+	_, err := f.document.Insert(c.parent, c.index, c.g)
+	return err
+}
+
+func (c *ungroupElement) Size() int {
+	return COMMAND_SIZE + 8*len(c.children)
+}
+
+// vertexSetter is implemented by elements with vertices that can be moved
+// one by one.
+type vertexSetter interface {
+	Vertex(i int) graphics.Pointf
+	SetVertex(i int, p graphics.Pointf)
+}
+
+// moveVertex moves a vertex of an element, in the element's coordinates.
+type moveVertex struct {
+	id       dom.ElementID
+	v        int
+	from, to graphics.Pointf
+}
+
+func (c *moveVertex) set(f *Frame, p graphics.Pointf) error {
+	e, ok := f.document.Get(c.id).(vertexSetter)
+	if !ok {
+		return errNoElement
+	}
+	e.SetVertex(c.v, p)
+	return nil
+}
+
+func (c *moveVertex) Do(f *Frame) error   { return c.set(f, c.to) }
+func (c *moveVertex) Undo(f *Frame) error { return c.set(f, c.from) }
+func (c *moveVertex) Size() int           { return COMMAND_SIZE }
+
+// merge makes successive moves of the same vertex one edit.
+func (c *moveVertex) merge(o Command) bool {
+	if !sameTarget(c, o) {
+		return false
+	}
+	c.to = o.(*moveVertex).to
+	return true
+}
+
+// moveElement translates an element by d in document coordinates.
+type moveElement struct {
+	id dom.ElementID
+	d  graphics.Pointf
+}
+
+func (c *moveElement) move(f *Frame, d graphics.Pointf) error {
+	e := f.document.Get(c.id)
+	if e == nil {
+		return errNoElement
+	}
+	toLocal, ok := dom.LocalToDocument(e).Invert()
+	if !ok {
+		return fmt.Errorf("content: element %d can't be moved", c.id)
+	}
+	e.ApplyTransform(graphics.Translate(toLocal.TransformVector(d)))
+	return nil
+}
+
+func (c *moveElement) Do(f *Frame) error   { return c.move(f, c.d) }
+func (c *moveElement) Undo(f *Frame) error { return c.move(f, c.d.Mul(-1)) }
+func (c *moveElement) Size() int           { return COMMAND_SIZE }
+
+// merge makes successive moves of the same element one edit.
+func (c *moveElement) merge(o Command) bool {
+	if !sameTarget(c, o) {
+		return false
+	}
+	c.d = c.d.Add(o.(*moveElement).d)
+	return true
+}
+
+// styler is implemented by elements with a fill color.
+type styler interface {
+	Color() color.RGBA
+	SetColor(c color.RGBA)
+}
+
+// restyleElement changes the color of an element.
+type restyleElement struct {
+	id       dom.ElementID
+	from, to color.RGBA
+}
+
+func (c *restyleElement) set(f *Frame, col color.RGBA) error {
+	e, ok := f.document.Get(c.id).(styler)
+	if !ok {
+		return errNoElement
+	}
+	e.SetColor(col)
+	return nil
+}
+
+func (c *restyleElement) Do(f *Frame) error   { return c.set(f, c.to) }
+func (c *restyleElement) Undo(f *Frame) error { return c.set(f, c.from) }
+func (c *restyleElement) Size() int           { return COMMAND_SIZE }
@@ -0,0 +1,212 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func vertex(t *testing.T, f *Frame, id dom.ElementID, i int) graphics.Pointf {
+	qe, ok := f.document.Get(id).(*dom.QuadElement)
+	if !ok {
+		t.Fatalf("no quad %d", id)
+	}
+	return qe.Vertex(i)
+}
+
+func Test_UndoRedoEdits(t *testing.T) {
+	f := NewFrame()
+	a := f.AddElement(graphics.Ptf(100, 100))
+	b := f.AddElement(graphics.Ptf(300, 100))
+	f.MoveElement(a, graphics.Ptf(10, 20))
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	f.RestyleElement(b, red)
+	f.DeleteElement(a)
+	testhelpers.AssertInt(t, 5, f.History().Len())
+	testhelpers.AssertInt(t, 1, f.document.Len())
+
+	// Undo the delete: a is back under its ID and in its place.
+	if !f.Undo() {
+		t.Fatal("nothing to undo")
+	}
+	testhelpers.AssertInt(t, 0, f.document.IndexOf(a))
+	if v := vertex(t, f, a, 0); !v.Eq(graphics.Ptf(65, 75)) {
+		t.Errorf("a at %v", v)
+	}
+
+	f.Undo()
+	if c := f.document.Get(b).(*dom.QuadElement).Color(); c == red {
+		t.Errorf("restyle not undone")
+	}
+	f.Undo()
+	if v := vertex(t, f, a, 0); !v.Eq(graphics.Ptf(55, 55)) {
+		t.Errorf("move not undone: %v", v)
+	}
+	f.Undo()
+	f.Undo()
+	testhelpers.AssertInt(t, 0, f.document.Len())
+	if f.Undo() {
+		t.Error("undid more than was done")
+	}
+
+	// Redo all of it.
+	for f.Redo() {
+	}
+	testhelpers.AssertInt(t, 1, f.document.Len())
+	testhelpers.AssertInt(t, int(b), int(f.document.At(0).ID()))
+	if c := f.document.Get(b).(*dom.QuadElement).Color(); c != red {
+		t.Errorf("restyle not redone")
+	}
+
+	// A new edit forgets what was undone.
+	f.Undo()
+	f.AddElement(graphics.Ptf(500, 500))
+	if f.History().CanRedo() {
+		t.Error("can redo after a new edit")
+	}
+}
+
+func Test_UndoDragAndShortcuts(t *testing.T) {
+	f := NewFrame()
+	new(Script).ClickAt(graphics.Ptf(100, 100), 0).Deliver(f)
+	id := f.document.At(0).ID()
+	new(Script).
+		PressAt(graphics.Ptf(145, 145), 0).
+		DragBy(graphics.Ptf(10, 5), 5).
+		Release(0).
+		Deliver(f)
+	// The whole drag is one edit.
+	testhelpers.AssertInt(t, 2, f.History().Len())
+	if v := vertex(t, f, id, 2); !v.Eq(graphics.Ptf(155, 150)) {
+		t.Fatalf("vertex 2 at %v", v)
+	}
+
+	new(Script).Hold(MOD_CONTROL).Press(KEY_Z).Deliver(f)
+	if v := vertex(t, f, id, 2); !v.Eq(graphics.Ptf(145, 145)) {
+		t.Errorf("drag not undone: %v", v)
+	}
+	new(Script).Hold(MOD_CONTROL | MOD_SHIFT).Press(KEY_Z).Deliver(f)
+	if v := vertex(t, f, id, 2); !v.Eq(graphics.Ptf(155, 150)) {
+		t.Errorf("drag not redone: %v", v)
+	}
+	new(Script).Hold(MOD_CONTROL).Press(KEY_Z).Press(KEY_Z).Deliver(f)
+	testhelpers.AssertInt(t, 0, f.document.Len())
+	new(Script).Hold(MOD_CONTROL).Press(KEY_Y).Deliver(f)
+	testhelpers.AssertInt(t, 1, f.document.Len())
+
+	// Z on its own does nothing.
+	new(Script).Press(KEY_Z).Deliver(f)
+	testhelpers.AssertInt(t, 1, f.document.Len())
+}
+
+func Test_NudgesCoalesce(t *testing.T) {
+	f := NewFrame()
+	id := f.AddElement(graphics.Ptf(100, 100))
+	f.Focus(f.document.Get(id))
+	f.Keydown(KEY_RIGHT, 0, false)
+	for i := 0; i < 5; i++ {
+		f.Keydown(KEY_RIGHT, 0, true)
+	}
+	f.Keydown(KEY_DOWN, 0, false)
+	testhelpers.AssertInt(t, 3, f.History().Len())
+	f.Undo()
+	f.Undo()
+	if v := vertex(t, f, id, 0); !v.Eq(graphics.Ptf(55, 55)) {
+		t.Errorf("nudges not undone: %v", v)
+	}
+}
+
+func Test_HistoryLimit(t *testing.T) {
+	f := NewFrame()
+	for i := 0; i < 10; i++ {
+		f.AddElement(graphics.Ptf(float32(i), 0))
+	}
+	size := f.History().size
+	f.History().SetLimit(size / 2)
+	n := f.History().Len()
+	if n >= 10 || n < 4 {
+		t.Errorf("limit kept %d edits", n)
+	}
+	for f.Undo() {
+	}
+	testhelpers.AssertInt(t, 10-n, f.document.Len())
+
+	// The most recent edit is kept however big.
+	f.History().SetLimit(1)
+	f.AddElement(graphics.Ptf(0, 0))
+	testhelpers.AssertInt(t, 1, f.History().Len())
+}
+
+func Test_UndoRedoGrouping(t *testing.T) {
+	f := NewFrame()
+	a := f.AddElement(graphics.Ptf(100, 100))
+	b := f.AddElement(graphics.Ptf(300, 100))
+	c := f.AddElement(graphics.Ptf(500, 100))
+	g, err := f.GroupElements(a, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.MoveElement(g, graphics.Ptf(10, 0))
+	testhelpers.AssertInt(t, 5, f.History().Len())
+	testhelpers.AssertInt(t, 2, f.document.Len())
+
+	// Undoing the group puts a and c back either side of b.
+	f.Undo()
+	f.Undo()
+	testhelpers.AssertInt(t, 3, f.document.Len())
+	for i, id := range []dom.ElementID{a, b, c} {
+		testhelpers.AssertInt(t, i, f.document.IndexOf(id))
+	}
+
+	// Redoing uses the same group, so the move after it redoes too.
+	f.Redo()
+	f.Redo()
+	testhelpers.AssertInt(t, int(g), int(f.document.At(1).ID()))
+	at := func() graphics.Pointf {
+		return dom.LocalToDocument(f.document.Get(c)).Transform(vertex(t, f, c, 0))
+	}
+	if v := at(); !v.Eq(graphics.Ptf(465, 55)) {
+		t.Errorf("group move not redone: %v", v)
+	}
+
+	// Ungrouping folds in the group's transform and undoing it restores
+	// the group.
+	if err := f.UngroupElement(g); err != nil {
+		t.Fatal(err)
+	}
+	testhelpers.AssertInt(t, 3, f.document.Len())
+	if v := vertex(t, f, c, 0); !v.Eq(graphics.Ptf(465, 55)) {
+		t.Errorf("ungrouped c at %v", v)
+	}
+	f.Undo()
+	testhelpers.AssertInt(t, 2, f.document.Len())
+	if p := f.document.Get(c).Parent(); p == nil || p.ID() != g {
+		t.Errorf("c not back in the group")
+	}
+	if v := vertex(t, f, c, 0); !v.Eq(graphics.Ptf(455, 55)) {
+		t.Errorf("regrouped c at %v", v)
+	}
+	testhelpers.AssertInt(t, 5, f.History().Len())
+
+	// Everything before the grouping still undoes.
+	for f.Undo() {
+	}
+	testhelpers.AssertInt(t, 0, f.document.Len())
+}
@@ -0,0 +1,108 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+// Key identifies a physical key independently of the keyboard layout's
+// text. The values are those used by glfw (and hence by USB HID derived
+// tables) so that the platform layer can convert with a cast.
+type Key int
+
+const (
+	KEY_UNKNOWN Key = -1
+	KEY_SPACE   Key = 32
+)
+
+const (
+	KEY_0 Key = '0' + iota
+	KEY_1
+	KEY_2
+	KEY_3
+	KEY_4
+	KEY_5
+	KEY_6
+	KEY_7
+	KEY_8
+	KEY_9
+)
+
+const (
+	KEY_A Key = 'A' + iota
+	KEY_B
+	KEY_C
+	KEY_D
+	KEY_E
+	KEY_F
+	KEY_G
+	KEY_H
+	KEY_I
+	KEY_J
+	KEY_K
+	KEY_L
+	KEY_M
+	KEY_N
+	KEY_O
+	KEY_P
+	KEY_Q
+	KEY_R
+	KEY_S
+	KEY_T
+	KEY_U
+	KEY_V
+	KEY_W
+	KEY_X
+	KEY_Y
+	KEY_Z
+)
+
+const (
+	KEY_ESCAPE Key = 256 + iota
+	KEY_ENTER
+	KEY_TAB
+	KEY_BACKSPACE
+	KEY_INSERT
+	KEY_DELETE
+	KEY_RIGHT
+	KEY_LEFT
+	KEY_DOWN
+	KEY_UP
+	KEY_PAGE_UP
+	KEY_PAGE_DOWN
+	KEY_HOME
+	KEY_END
+)
+
+const (
+	KEY_LEFT_SHIFT Key = 340 + iota
+	KEY_LEFT_CONTROL
+	KEY_LEFT_ALT
+	KEY_LEFT_SUPER
+	KEY_RIGHT_SHIFT
+	KEY_RIGHT_CONTROL
+	KEY_RIGHT_ALT
+	KEY_RIGHT_SUPER
+)
+
+// | These together to record which modifier keys are down. The values match
+// glfw's.
+const (
+	MOD_NONE = 0
+)
+
+const (
+	MOD_SHIFT = 1 << iota
+	MOD_CONTROL
+	MOD_ALT
+	MOD_SUPER
+)
@@ -0,0 +1,188 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+// SCRIPT_STEP is the time between the events of a Script unless Wait says
+// otherwise.
+const SCRIPT_STEP = 10 * time.Millisecond
+
+// Script records a sequence of input Events as a user would produce them,
+// for example:
+//
+//	new(Script).PressAt(p, 0).DragAlong(q, r).Release(0).Type("hi")
+//
+// The Events are timestamped SCRIPT_STEP apart and carry the button mask,
+// modifiers and click counts that the platform and the Window would give
+// them. Play them through a Window or Deliver them to an EventHandler.
+type Script struct {
+	events []Event
+
+	now     time.Duration
+	at      graphics.Pointf
+	buttons uint32
+	mods    uint32
+
+	// Counts clicks like the Window does.
+	clicks ClickCounter
+}
+
+// Events returns the recorded Events.
+func (s *Script) Events() []Event {
+	return s.events
+}
+
+// Deliver delivers the recorded Events to h in order.
+func (s *Script) Deliver(h EventHandler) {
+	for _, ev := range s.events {
+		Deliver(h, ev)
+	}
+}
+
+// Wait makes the next Event happen d later than the previous one.
+func (s *Script) Wait(d time.Duration) *Script {
+	s.now += d - SCRIPT_STEP
+	return s
+}
+
+func (s *Script) add(ev Event) {
+	s.now += SCRIPT_STEP
+	ev.Time = s.now
+	ev.Point = s.at
+	ev.Buttons = s.buttons
+	ev.Modifiers = s.mods
+	s.events = append(s.events, ev)
+}
+
+// MoveTo moves the pointer to p.
+func (s *Script) MoveTo(p graphics.Pointf) *Script {
+	s.at = p
+	s.add(Event{Type: EVENT_MOUSEMOVE})
+	return s
+}
+
+// PressAt moves the pointer to p and presses button (0 is the left button).
+func (s *Script) PressAt(p graphics.Pointf, button uint32) *Script {
+	if p != s.at {
+		s.MoveTo(p)
+	}
+	s.buttons |= 1 << button
+	// At the time add gives the Event.
+	clicks := s.clicks.Press(button, p, s.now+SCRIPT_STEP)
+	s.add(Event{Type: EVENT_MOUSEDOWN, Button: button, ClickCount: clicks})
+	return s
+}
+
+// DragAlong moves the pointer through each of path in turn.
+func (s *Script) DragAlong(path ...graphics.Pointf) *Script {
+	for _, p := range path {
+		s.MoveTo(p)
+	}
+	return s
+}
+
+// DragBy moves the pointer by d in n equal steps.
+func (s *Script) DragBy(d graphics.Pointf, n int) *Script {
+	start := s.at
+	for i := 1; i <= n; i++ {
+		s.MoveTo(start.Add(d.Mul(float32(i) / float32(n))))
+	}
+	return s
+}
+
+// Release releases button where the pointer is.
+func (s *Script) Release(button uint32) *Script {
+	s.buttons &^= 1 << button
+	s.add(Event{Type: EVENT_MOUSEUP, Button: button, ClickCount: s.clicks.Release(button)})
+	return s
+}
+
+// ClickAt presses and releases button at p.
+func (s *Script) ClickAt(p graphics.Pointf, button uint32) *Script {
+	return s.PressAt(p, button).Release(button)
+}
+
+// Wheel scrolls by dx, dy pixels, positive to scroll down and right.
+func (s *Script) Wheel(dx, dy float32) *Script {
+	s.add(Event{Type: EVENT_WHEEL, DX: dx, DY: dy})
+	return s
+}
+
+// Hold sets the modifier keys that are down to mods, pressing and
+// releasing the corresponding keys.
+func (s *Script) Hold(mods uint32) *Script {
+	for _, m := range [...]struct {
+		mod uint32
+		key Key
+	}{{MOD_SHIFT, KEY_LEFT_SHIFT}, {MOD_CONTROL, KEY_LEFT_CONTROL}, {MOD_ALT, KEY_LEFT_ALT}, {MOD_SUPER, KEY_LEFT_SUPER}} {
+		if mods&m.mod != 0 && s.mods&m.mod == 0 {
+			s.mods |= m.mod
+			s.add(Event{Type: EVENT_KEYDOWN, Key: m.key})
+		} else if mods&m.mod == 0 && s.mods&m.mod != 0 {
+			s.mods &^= m.mod
+			s.add(Event{Type: EVENT_KEYUP, Key: m.key})
+		}
+	}
+	return s
+}
+
+// Press presses and releases key.
+func (s *Script) Press(key Key) *Script {
+	s.add(Event{Type: EVENT_KEYDOWN, Key: key})
+	s.add(Event{Type: EVENT_KEYUP, Key: key})
+	return s
+}
+
+// Type types text. Upper case letters are typed with shift.
+func (s *Script) Type(text string) *Script {
+	held := s.mods
+	for _, r := range text {
+		mods := held
+		if unicode.IsUpper(r) {
+			mods |= MOD_SHIFT
+		}
+		s.Hold(mods)
+		key := keyForRune(r)
+		s.add(Event{Type: EVENT_KEYDOWN, Key: key})
+		s.add(Event{Type: EVENT_TEXTINPUT, Text: r})
+		s.add(Event{Type: EVENT_KEYUP, Key: key})
+	}
+	return s.Hold(held)
+}
+
+// keyForRune returns the key on a US keyboard that types r.
+func keyForRune(r rune) Key {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return KEY_A + Key(r-'a')
+	case r >= 'A' && r <= 'Z':
+		return KEY_A + Key(r-'A')
+	case r >= '0' && r <= '9':
+		return KEY_0 + Key(r-'0')
+	case r == ' ':
+		return KEY_SPACE
+	case r == '\n':
+		return KEY_ENTER
+	case r == '\t':
+		return KEY_TAB
+	}
+	return KEY_UNKNOWN
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func Test_ScriptDragVertex(t *testing.T) {
+	f := NewFrame()
+	new(Script).ClickAt(graphics.Ptf(100, 100), 0).Deliver(f)
+	testhelpers.AssertInt(t, 1, f.document.Len())
+
+	// Drag vertex 2 of element 0 by (10, 5).
+	qe := f.document.At(0).(*dom.QuadElement)
+	new(Script).
+		PressAt(qe.Vertex(2), 0).
+		DragBy(graphics.Ptf(10, 5), 4).
+		Release(0).
+		Deliver(f)
+
+	testhelpers.AssertInt(t, 1, f.document.Len())
+	if v := qe.Vertex(2); !v.Eq(graphics.Ptf(155, 150)) {
+		t.Errorf("vertex 2 at %v", v)
+	}
+	if v := qe.Vertex(0); !v.Eq(graphics.Ptf(55, 55)) {
+		t.Errorf("vertex 0 moved to %v", v)
+	}
+}
+
+func Test_ScriptClickCounts(t *testing.T) {
+	f := NewFrame()
+	var detail []int
+	f.document.Root().AddEventListener(dom.EVENT_MOUSEDOWN, false, func(ev *dom.Event) {
+		detail = append(detail, ev.Detail)
+	})
+
+	p := graphics.Ptf(300, 300)
+	new(Script).
+		ClickAt(p, 0).ClickAt(p.Add(graphics.Ptf(1, 1)), 0).
+		Wait(time.Second).ClickAt(p, 0).
+		ClickAt(graphics.Ptf(0, 0), 0).
+		Deliver(f)
+	if len(detail) != 4 || detail[0] != 1 || detail[1] != 2 || detail[2] != 1 || detail[3] != 1 {
+		t.Errorf("bad click counts %v", detail)
+	}
+}
+
+func Test_ScriptTypeAndWheel(t *testing.T) {
+	f := NewFrame()
+	var text []rune
+	var shifted []bool
+	var wheel []float32
+	root := f.document.Root()
+	root.AddEventListener(dom.EVENT_TEXTINPUT, false, func(ev *dom.Event) {
+		text = append(text, ev.Text)
+	})
+	root.AddEventListener(dom.EVENT_KEYDOWN, false, func(ev *dom.Event) {
+		if Key(ev.Key) != KEY_LEFT_SHIFT {
+			shifted = append(shifted, ev.Modifiers&MOD_SHIFT != 0)
+		}
+	})
+	root.AddEventListener(dom.EVENT_WHEEL, false, func(ev *dom.Event) {
+		wheel = append(wheel, ev.DY)
+	})
+
+	new(Script).Type("Hi").Wheel(0, 48).Deliver(f)
+	if string(text) != "Hi" || len(shifted) != 2 || !shifted[0] || shifted[1] {
+		t.Errorf("bad typing %q %v", string(text), shifted)
+	}
+	if len(wheel) != 1 || wheel[0] != 48 {
+		t.Errorf("bad wheel %v", wheel)
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+)
+
+// SelectedVertex names vertex V of the element with ID.
+type SelectedVertex struct {
+	ID dom.ElementID
+	V  int
+}
+
+// Selection is a set of elements and of individual vertices of elements.
+// Elements and vertices are selected independently: selecting a vertex
+// doesn't select its element.
+type Selection struct {
+	elements map[dom.ElementID]bool
+	vertices map[SelectedVertex]bool
+}
+
+// Len returns the number of selected elements and vertices.
+func (s *Selection) Len() int {
+	return len(s.elements) + len(s.vertices)
+}
+
+// Contains reports whether the element with the given id is selected.
+func (s *Selection) Contains(id dom.ElementID) bool {
+	return s.elements[id]
+}
+
+// ContainsVertex reports whether vertex v of the element with the given id
+// is selected.
+func (s *Selection) ContainsVertex(id dom.ElementID, v int) bool {
+	return s.vertices[SelectedVertex{id, v}]
+}
+
+// Add selects the elements with the given ids.
+func (s *Selection) Add(ids ...dom.ElementID) {
+	if s.elements == nil {
+		s.elements = make(map[dom.ElementID]bool)
+	}
+	for _, id := range ids {
+		s.elements[id] = true
+	}
+}
+
+// Remove deselects the element with the given id and its vertices.
+func (s *Selection) Remove(id dom.ElementID) {
+	delete(s.elements, id)
+	for sv := range s.vertices {
+		if sv.ID == id {
+			delete(s.vertices, sv)
+		}
+	}
+}
+
+// Toggle selects the element with the given id if it isn't selected and
+// deselects it if it is.
+func (s *Selection) Toggle(id dom.ElementID) {
+	if s.elements[id] {
+		delete(s.elements, id)
+	} else {
+		s.Add(id)
+	}
+}
+
+// AddVertex selects vertex v of the element with the given id.
+func (s *Selection) AddVertex(id dom.ElementID, v int) {
+	if s.vertices == nil {
+		s.vertices = make(map[SelectedVertex]bool)
+	}
+	s.vertices[SelectedVertex{id, v}] = true
+}
+
+// ToggleVertex selects vertex v of the element with the given id if it
+// isn't selected and deselects it if it is.
+func (s *Selection) ToggleVertex(id dom.ElementID, v int) {
+	sv := SelectedVertex{id, v}
+	if s.vertices[sv] {
+		delete(s.vertices, sv)
+	} else {
+		s.AddVertex(id, v)
+	}
+}
+
+// Clear deselects everything.
+func (s *Selection) Clear() {
+	s.elements = nil
+	s.vertices = nil
+}
+
+// Elements returns the IDs of the selected elements in increasing order.
+func (s *Selection) Elements() []dom.ElementID {
+	ids := make([]dom.ElementID, 0, len(s.elements))
+	for id := range s.elements {
+		ids = append(ids, id)
+	}
+	sort.Sort(elementIDs(ids))
+	return ids
+}
+
+// Vertices returns the selected vertices ordered by element ID and then
+// vertex.
+func (s *Selection) Vertices() []SelectedVertex {
+	vs := make([]SelectedVertex, 0, len(s.vertices))
+	for sv := range s.vertices {
+		vs = append(vs, sv)
+	}
+	sort.Sort(selectedVertices(vs))
+	return vs
+}
+
+// prune deselects whatever is no longer in d.
+func (s *Selection) prune(d *dom.Document) {
+	for id := range s.elements {
+		if d.Get(id) == nil {
+			delete(s.elements, id)
+		}
+	}
+	for sv := range s.vertices {
+		if d.Get(sv.ID) == nil {
+			delete(s.vertices, sv)
+		}
+	}
+}
+
+// Selection returns what is selected in the Frame.
+func (f *Frame) Selection() *Selection {
+	return &f.selection
+}
+
+// SelectAll selects every top-level element.
+func (f *Frame) SelectAll() {
+	for i := 0; i < f.document.Len(); i++ {
+		f.selection.Add(f.document.At(i).ID())
+	}
+}
+
+// underSelection reports whether e or one of its ancestors is selected.
+func (f *Frame) underSelection(e dom.Element) bool {
+	for e != dom.Element(f.document.Root()) {
+		if f.selection.Contains(e.ID()) {
+			return true
+		}
+		p := e.Parent()
+		if p == nil {
+			return false
+		}
+		e = p
+	}
+	return false
+}
+
+// moveSelection returns the edit that translates the selected elements and
+// vertices by d in document coordinates.
+func (f *Frame) moveSelection(d graphics.Pointf) Command {
+	var cs commands
+	for _, id := range f.selection.Elements() {
+		if !f.underSelection(f.document.Get(id).Parent()) {
+			cs = append(cs, &moveElement{id: id, d: d})
+		}
+	}
+	for _, sv := range f.selection.Vertices() {
+		e := f.document.Get(sv.ID)
+		vs, ok := e.(vertexSetter)
+		if !ok || f.underSelection(e) {
+			continue
+		}
+		toLocal, ok := dom.LocalToDocument(e).Invert()
+		if !ok {
+			continue
+		}
+		from := vs.Vertex(sv.V)
+		cs = append(cs, &moveVertex{id: sv.ID, v: sv.V, from: from, to: from.Add(toLocal.TransformVector(d))})
+	}
+	return cs.single()
+}
+
+// dragSelection moves the selection so that the pressed vertex follows pt,
+// snapping it as SetSnapping says. The moves made add up to one edit.
+func (f *Frame) dragSelection(pt graphics.Pointf) {
+	// In the coordinates the pressed element had when the drag started.
+	toDoc, _ := f.toLocal.Invert()
+	p := toDoc.Transform(f.toLocal.Transform(pt).Add(f.offset))
+	f.guides = nil
+	if f.snapping != (Snapping{}) && !f.snapOff {
+		p, f.guides = f.snap(p, f.selectedVertices())
+	}
+	d := p.Sub(f.dragOrigin)
+	if d.Eq(f.dragMoved) {
+		return
+	}
+	c := f.moveSelection(d.Sub(f.dragMoved))
+	if c == nil || c.Do(f) != nil {
+		return
+	}
+	f.dragMoved = d
+	if f.dragMove == nil {
+		f.dragMove = c
+	} else if m, ok := f.dragMove.(merger); !ok || !m.merge(c) {
+		f.dragMove = commands{f.dragMove, c}
+	}
+}
+
+// selectedVertices returns the vertices that move with the selection.
+func (f *Frame) selectedVertices() map[SelectedVertex]bool {
+	vs := make(map[SelectedVertex]bool)
+	dom.Walk(f.document.Root(), func(e dom.Element) {
+		vl, ok := e.(vertexLister)
+		if !ok || !f.underSelection(e) {
+			return
+		}
+		for i := 0; i < vl.NumVertices(); i++ {
+			vs[SelectedVertex{e.ID(), i}] = true
+		}
+	})
+	for _, sv := range f.selection.Vertices() {
+		vs[sv] = true
+	}
+	return vs
+}
+
+// MoveSelection translates the selected elements and vertices by d in
+// document coordinates as one edit. Returns false if nothing is selected.
+func (f *Frame) MoveSelection(d graphics.Pointf) bool {
+	c := f.moveSelection(d)
+	return c != nil && f.do(c) == nil
+}
+
+// DeleteSelection deletes the selected elements as one edit. Selected
+// vertices can't be deleted on their own. Returns false if no element is
+// selected.
+func (f *Frame) DeleteSelection() bool {
+	var cs commands
+	for _, id := range f.selection.Elements() {
+		if !f.underSelection(f.document.Get(id).Parent()) {
+			cs = append(cs, &deleteElement{id: id})
+		}
+	}
+	c := cs.single()
+	return c != nil && f.do(c) == nil
+}
+
+// RestyleSelection sets the color of the selected elements and of the
+// elements with selected vertices as one edit. Returns false if none of
+// them has a color.
+func (f *Frame) RestyleSelection(col color.RGBA) bool {
+	var cs commands
+	seen := make(map[dom.ElementID]bool)
+	restyle := func(id dom.ElementID) {
+		if e, ok := f.document.Get(id).(styler); ok && !seen[id] {
+			seen[id] = true
+			cs = append(cs, &restyleElement{id: id, from: e.Color(), to: col})
+		}
+	}
+	for _, id := range f.selection.Elements() {
+		restyle(id)
+	}
+	for _, sv := range f.selection.Vertices() {
+		restyle(sv.ID)
+	}
+	c := cs.single()
+	return c != nil && f.do(c) == nil
+}
+
+// startMarquee starts a rubber band selection at p.
+func (f *Frame) startMarquee(p graphics.Pointf) {
+	f.marquee = true
+	f.marqueeFrom, f.marqueeTo = p, p
+}
+
+func (f *Frame) marqueeRect() graphics.Rectanglef {
+	return graphics.Rectanglef{f.marqueeFrom, f.marqueeTo}.Canon()
+}
+
+// endMarquee ends the rubber band selection at p. If the pointer moved far
+// enough to make it a drag rather than a click, the elements inside the
+// band are selected, in addition to what was selected if add is set, and
+// endMarquee returns true.
+func (f *Frame) endMarquee(p graphics.Pointf, add bool) bool {
+	f.marquee = false
+	f.marqueeTo = p
+	r := f.marqueeRect()
+	if graphics.MaxF(r.Dx(), r.Dy()) <= DOUBLE_CLICK_SLOP*f.pixel {
+		return false
+	}
+	if !add {
+		f.selection.Clear()
+	}
+	for _, e := range f.document.ElementsIn(r) {
+		f.selection.Add(e.ID())
+	}
+	return true
+}
+
+var (
+	selectionColor = color.RGBA{0xff, 0x80, 0x00, 0xc0}
+	marqueeColor   = color.RGBA{0x40, 0x80, 0xff, 0x30}
+)
+
+// drawSelection outlines the selected elements and marks the selected
+// vertices.
+func (f *Frame) drawSelection(dl *graphics.DisplayList) {
+	if f.selection.Len() == 0 {
+		return
+	}
+	dl.SetColor(selectionColor)
+	px := f.pixel
+	for _, id := range f.selection.Elements() {
+		e := f.document.Get(id)
+		r := dom.LocalToDocument(e).TransformRect(e.Bounds())
+		// Outside the focus ring.
+		drawOutline(dl, graphics.Rectanglef{r.Min.Sub(graphics.Pointf{px, px}), r.Max.Add(graphics.Pointf{px, px})}, 2*px)
+	}
+	var ps []graphics.Pointf
+	for _, sv := range f.selection.Vertices() {
+		e := f.document.Get(sv.ID)
+		if vs, ok := e.(vertexSetter); ok {
+			ps = append(ps, dom.LocalToDocument(e).Transform(vs.Vertex(sv.V)))
+		}
+	}
+	if len(ps) > 0 {
+		// Handles, like those of the elements, are drawn crisp.
+		dl.Save()
+		dl.SetAntialias(false)
+		dl.SetPointSize(2 * (dom.QUAD_ELEMENT_DH + 1) * px)
+		dl.DrawPoints(ps)
+		dl.Restore()
+	}
+}
+
+// drawMarquee draws the rubber band of a selection in progress.
+func (f *Frame) drawMarquee(dl *graphics.DisplayList) {
+	if !f.marquee {
+		return
+	}
+	r := f.marqueeRect()
+	dl.SetColor(marqueeColor)
+	dl.DrawQuads([][4]graphics.Pointf{rectQuad(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y)})
+	dl.SetColor(focusRingColor)
+	drawOutline(dl, r, f.pixel)
+}
+
+type elementIDs []dom.ElementID
+
+func (a elementIDs) Len() int           { return len(a) }
+func (a elementIDs) Less(i, j int) bool { return a[i] < a[j] }
+func (a elementIDs) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+type selectedVertices []SelectedVertex
+
+func (a selectedVertices) Len() int      { return len(a) }
+func (a selectedVertices) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a selectedVertices) Less(i, j int) bool {
+	return a[i].ID < a[j].ID || a[i].ID == a[j].ID && a[i].V < a[j].V
+}
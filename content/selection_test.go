@@ -0,0 +1,220 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+// threeQuads returns a Frame with quads centred on (100, 100), (300, 100)
+// and (100, 300).
+func threeQuads() (*Frame, []dom.ElementID) {
+	f := NewFrame()
+	ids := []dom.ElementID{
+		f.AddElement(graphics.Ptf(100, 100)),
+		f.AddElement(graphics.Ptf(300, 100)),
+		f.AddElement(graphics.Ptf(100, 300)),
+	}
+	return f, ids
+}
+
+func assertSelected(t *testing.T, f *Frame, ids ...dom.ElementID) {
+	got := f.Selection().Elements()
+	if len(got) != len(ids) {
+		t.Fatalf("expected %v selected, got %v", ids, got)
+	}
+	for i := range ids {
+		if got[i] != ids[i] {
+			t.Fatalf("expected %v selected, got %v", ids, got)
+		}
+	}
+}
+
+func Test_MarqueeSelection(t *testing.T) {
+	f, ids := threeQuads()
+	new(Script).
+		PressAt(graphics.Ptf(20, 20), 0).
+		DragAlong(graphics.Ptf(200, 100), graphics.Ptf(360, 160)).
+		Release(0).
+		Deliver(f)
+	assertSelected(t, f, ids[0], ids[1])
+	testhelpers.AssertInt(t, 3, f.document.Len())
+
+	// Shift adds to the selection.
+	new(Script).
+		Hold(MOD_SHIFT).
+		PressAt(graphics.Ptf(20, 250), 0).
+		DragAlong(graphics.Ptf(160, 360)).
+		Release(0).
+		Deliver(f)
+	assertSelected(t, f, ids...)
+
+	// A click in empty space clears the selection and adds an element.
+	new(Script).ClickAt(graphics.Ptf(500, 500), 0).Deliver(f)
+	assertSelected(t, f)
+	testhelpers.AssertInt(t, 4, f.document.Len())
+}
+
+func Test_SelectionEdits(t *testing.T) {
+	f, ids := threeQuads()
+	new(Script).Hold(MOD_CONTROL).Press(KEY_A).Deliver(f)
+	assertSelected(t, f, ids...)
+
+	new(Script).Press(KEY_RIGHT).Press(KEY_DOWN).Deliver(f)
+	for _, id := range ids {
+		if r := f.document.Get(id).Bounds(); r.Dx() != 90 || int(r.Min.X)%100 != 56 || int(r.Min.Y)%100 != 56 {
+			t.Errorf("%d not nudged: %v", id, r)
+		}
+	}
+	testhelpers.AssertInt(t, 5, f.History().Len())
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	f.RestyleSelection(red)
+	for _, id := range ids {
+		if c := f.document.Get(id).(*dom.QuadElement).Color(); c != red {
+			t.Errorf("%d not restyled", id)
+		}
+	}
+
+	new(Script).Press(KEY_DELETE).Deliver(f)
+	testhelpers.AssertInt(t, 0, f.document.Len())
+	assertSelected(t, f)
+
+	// Each edit of the whole selection undoes in one step.
+	f.Undo()
+	testhelpers.AssertInt(t, 3, f.document.Len())
+	for i, id := range ids {
+		testhelpers.AssertInt(t, i, f.document.IndexOf(id))
+	}
+	f.Undo()
+	f.Undo()
+	f.Undo()
+	if r := f.document.Get(ids[2]).Bounds(); r.Min.X != 55 || r.Min.Y != 255 {
+		t.Errorf("nudges not undone: %v", r)
+	}
+}
+
+func Test_SelectVertices(t *testing.T) {
+	f, ids := threeQuads()
+	// Shift-click vertex 1 of the first quad and vertex 0 of the second.
+	new(Script).
+		Hold(MOD_SHIFT).
+		ClickAt(graphics.Ptf(145, 55), 0).
+		ClickAt(graphics.Ptf(255, 55), 0).
+		Hold(0).
+		Deliver(f)
+	if !f.Selection().ContainsVertex(ids[0], 1) || !f.Selection().ContainsVertex(ids[1], 0) {
+		t.Fatalf("vertices not selected: %v", f.Selection().Vertices())
+	}
+	testhelpers.AssertInt(t, 2, f.Selection().Len())
+
+	// Dragging one selected vertex drags the other.
+	new(Script).
+		PressAt(graphics.Ptf(145, 55), 0).
+		DragBy(graphics.Ptf(10, -5), 3).
+		Release(0).
+		Deliver(f)
+	if v := vertex(t, f, ids[0], 1); !v.Eq(graphics.Ptf(155, 50)) {
+		t.Errorf("dragged vertex at %v", v)
+	}
+	if v := vertex(t, f, ids[1], 0); !v.Eq(graphics.Ptf(265, 50)) {
+		t.Errorf("other vertex at %v", v)
+	}
+	testhelpers.AssertInt(t, 2, f.Selection().Len())
+	f.Undo()
+	if v := vertex(t, f, ids[1], 0); !v.Eq(graphics.Ptf(255, 55)) {
+		t.Errorf("drag not undone: %v", v)
+	}
+
+	// Pressing an unselected vertex selects its element instead.
+	new(Script).ClickAt(graphics.Ptf(55, 345), 0).Deliver(f)
+	assertSelected(t, f, ids[2])
+	testhelpers.AssertInt(t, 1, f.Selection().Len())
+
+	f.DeleteElement(ids[2])
+	testhelpers.AssertInt(t, 0, f.Selection().Len())
+}
+
+func Test_DragSelection(t *testing.T) {
+	f, ids := threeQuads()
+	f.Selection().Add(ids[0], ids[1])
+
+	// Pressing a vertex of a selected element drags the whole selection.
+	new(Script).
+		PressAt(graphics.Ptf(145, 55), 0).
+		DragBy(graphics.Ptf(10, -5), 3).
+		Release(0).
+		Deliver(f)
+	for _, c := range []struct {
+		id   dom.ElementID
+		v    int
+		want graphics.Pointf
+	}{
+		{ids[0], 0, graphics.Ptf(65, 50)},
+		{ids[0], 1, graphics.Ptf(155, 50)},
+		{ids[1], 0, graphics.Ptf(265, 50)},
+		{ids[2], 0, graphics.Ptf(55, 255)},
+	} {
+		if v := vertex(t, f, c.id, c.v); !v.Eq(c.want) {
+			t.Errorf("vertex %d of %d at %v, expected %v", c.v, c.id, v, c.want)
+		}
+	}
+	assertSelected(t, f, ids[0], ids[1])
+
+	// The drag undoes in one step.
+	f.Undo()
+	if v := vertex(t, f, ids[0], 1); !v.Eq(graphics.Ptf(145, 55)) {
+		t.Errorf("drag not undone: %v", v)
+	}
+	if v := vertex(t, f, ids[1], 0); !v.Eq(graphics.Ptf(255, 55)) {
+		t.Errorf("drag not undone: %v", v)
+	}
+	f.Undo()
+	if v := vertex(t, f, ids[1], 0); !v.Eq(graphics.Ptf(255, 55)) {
+		t.Errorf("undid past the drag: %v", v)
+	}
+}
+
+func Test_SelectionHighlight(t *testing.T) {
+	f, ids := threeQuads()
+	render := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+		f.DisplayList(graphics.Identity()).Rasterize(img)
+		return img
+	}
+	// Just outside the right edge of the first quad.
+	if c := render().RGBAAt(147, 100); c.A != 0 {
+		t.Errorf("unselected quad outlined: %v", c)
+	}
+	f.Selection().Add(ids[0])
+	f.Selection().AddVertex(ids[1], 2)
+	img := render()
+	if c := img.RGBAAt(147, 100); c.A == 0 {
+		t.Errorf("selected quad not outlined")
+	}
+	// Beside the handle of vertex 2 of the second quad.
+	if c := img.RGBAAt(349, 145); c.A == 0 {
+		t.Errorf("selected vertex not marked")
+	}
+	if c := img.RGBAAt(347, 100); c.A != 0 {
+		t.Errorf("quad with a selected vertex outlined: %v", c)
+	}
+}
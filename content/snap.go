@@ -0,0 +1,214 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+)
+
+// Snapping says what a dragged vertex snaps to. The zero Snapping turns
+// snapping off.
+type Snapping struct {
+	// The spacing of the grid in document units or 0 for no grid.
+	Grid float32
+
+	// Snap to the vertices and to the edges of other elements.
+	Vertices, Edges bool
+
+	// Snap horizontally and vertically into line with the vertices of other
+	// elements.
+	Align bool
+}
+
+// Things within SNAP_DISTANCE screen pixels snap together unless
+// SNAP_OFF_MODIFIER is held while dragging.
+const (
+	SNAP_DISTANCE     = 6
+	SNAP_OFF_MODIFIER = MOD_ALT
+)
+
+// SetSnapping sets what dragged vertices snap to.
+func (f *Frame) SetSnapping(s Snapping) {
+	f.snapping = s
+}
+
+// Snapping returns what dragged vertices snap to.
+func (f *Frame) Snapping() Snapping {
+	return f.snapping
+}
+
+// vertexLister is implemented by elements with vertices. The vertices in
+// order outline the element.
+type vertexLister interface {
+	NumVertices() int
+	Vertex(i int) graphics.Pointf
+}
+
+// guide is a line shown while dragging to explain a snap. It is a point
+// if from and to are the same. In document coordinates.
+type guide struct {
+	from, to graphics.Pointf
+}
+
+// snapTargets returns the vertices and edges in document coordinates that
+// dragged vertices can snap to: those of every element except the
+// vertices in exclude and the edges that end at them.
+func (f *Frame) snapTargets(exclude map[SelectedVertex]bool) (points []graphics.Pointf, edges [][2]graphics.Pointf) {
+	dom.Walk(f.document.Root(), func(e dom.Element) {
+		vl, ok := e.(vertexLister)
+		if !ok {
+			return
+		}
+		m := dom.LocalToDocument(e)
+		n := vl.NumVertices()
+		for i := 0; i < n; i++ {
+			j := (i + 1) % n
+			a, b := exclude[SelectedVertex{e.ID(), i}], exclude[SelectedVertex{e.ID(), j}]
+			if !a {
+				points = append(points, m.Transform(vl.Vertex(i)))
+			}
+			if !a && !b {
+				edges = append(edges, [2]graphics.Pointf{m.Transform(vl.Vertex(i)), m.Transform(vl.Vertex(j))})
+			}
+		}
+	})
+	return points, edges
+}
+
+// snap returns where the point p, in document coordinates, snaps to and
+// the guides that show why. Vertices win over edges, which win over
+// alignment, which wins over the grid.
+func (f *Frame) snap(p graphics.Pointf, exclude map[SelectedVertex]bool) (graphics.Pointf, []guide) {
+	s := f.snapping
+	tol := SNAP_DISTANCE * f.pixel
+	points, edges := f.snapTargets(exclude)
+
+	if s.Vertices {
+		best, found := tol, false
+		var q graphics.Pointf
+		for _, v := range points {
+			if d := length(v.Sub(p)); d <= best {
+				best, q, found = d, v, true
+			}
+		}
+		if found {
+			return q, []guide{{q, q}}
+		}
+	}
+
+	if s.Edges {
+		best, found := tol, false
+		var q graphics.Pointf
+		var edge [2]graphics.Pointf
+		for _, e := range edges {
+			c := closestOnSegment(p, e[0], e[1])
+			if d := length(c.Sub(p)); d <= best {
+				best, q, edge, found = d, c, e, true
+			}
+		}
+		if found {
+			return q, []guide{{edge[0], edge[1]}}
+		}
+	}
+
+	q := p
+	var guides []guide
+	alignedX, alignedY := false, false
+	var ax, ay graphics.Pointf
+	if s.Align {
+		bestX, bestY := tol, tol
+		for _, v := range points {
+			if d := graphics.AbsF(v.X - p.X); d <= bestX {
+				bestX, ax, alignedX = d, v, true
+			}
+			if d := graphics.AbsF(v.Y - p.Y); d <= bestY {
+				bestY, ay, alignedY = d, v, true
+			}
+		}
+		if alignedX {
+			q.X = ax.X
+		}
+		if alignedY {
+			q.Y = ay.Y
+		}
+	}
+	if g := s.Grid; g > 0 {
+		if !alignedX {
+			q.X = float32(math.Floor(float64(q.X/g)+.5)) * g
+		}
+		if !alignedY {
+			q.Y = float32(math.Floor(float64(q.Y/g)+.5)) * g
+		}
+	}
+	if alignedX {
+		guides = append(guides, guide{graphics.Pointf{q.X, ax.Y}, q})
+	}
+	if alignedY {
+		guides = append(guides, guide{graphics.Pointf{ay.X, q.Y}, q})
+	}
+	return q, guides
+}
+
+func length(v graphics.Pointf) float32 {
+	return float32(math.Hypot(float64(v.X), float64(v.Y)))
+}
+
+// closestOnSegment returns the point of the segment from a to b closest to
+// p.
+func closestOnSegment(p, a, b graphics.Pointf) graphics.Pointf {
+	d := b.Sub(a)
+	l2 := d.X*d.X + d.Y*d.Y
+	if l2 == 0 {
+		return a
+	}
+	t := ((p.X-a.X)*d.X + (p.Y-a.Y)*d.Y) / l2
+	t = graphics.MaxF(0, graphics.MinF(1, t))
+	return a.Add(d.Mul(t))
+}
+
+var guideColor = color.RGBA{0xff, 0x00, 0xc0, 0xc0}
+
+// drawGuides draws the guides of the snap made by the current drag. Lines
+// are a screen pixel wide.
+func (f *Frame) drawGuides(dl *graphics.DisplayList) {
+	if len(f.guides) == 0 {
+		return
+	}
+	px := f.pixel
+	dl.SetColor(guideColor)
+	var points []graphics.Pointf
+	var quads [][4]graphics.Pointf
+	for _, g := range f.guides {
+		d := g.to.Sub(g.from)
+		l := length(d)
+		if l == 0 {
+			points = append(points, g.from)
+			continue
+		}
+		n := graphics.Pointf{-d.Y, d.X}.Mul(px / 2 / l)
+		quads = append(quads, [4]graphics.Pointf{g.from.Add(n), g.to.Add(n), g.to.Sub(n), g.from.Sub(n)})
+	}
+	if len(quads) > 0 {
+		dl.DrawQuads(quads)
+	}
+	if len(points) > 0 {
+		dl.SetPointSize(2 * (dom.QUAD_ELEMENT_DH + 1) * px)
+		dl.DrawPoints(points)
+	}
+}
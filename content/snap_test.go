@@ -0,0 +1,109 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package content
+
+import (
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+// dragVertex drags the vertex at from to to, holding mods, and returns
+// the Frame's guides just before the release.
+func dragVertex(f *Frame, from, to graphics.Pointf, mods uint32) []guide {
+	s := new(Script).Hold(mods).PressAt(from, 0).DragAlong(from.Add(to).Div(2), to)
+	n := len(s.Events())
+	s.Release(0)
+	var guides []guide
+	for i, ev := range s.Events() {
+		if i == n {
+			guides = f.guides
+		}
+		Deliver(f, ev)
+	}
+	return guides
+}
+
+func Test_SnapToGrid(t *testing.T) {
+	f := NewFrame()
+	id := f.AddElement(graphics.Ptf(100, 100))
+	f.SetSnapping(Snapping{Grid: 10})
+	dragVertex(f, graphics.Ptf(145, 145), graphics.Ptf(158, 147), 0)
+	if v := vertex(t, f, id, 2); !v.Eq(graphics.Ptf(160, 150)) {
+		t.Errorf("vertex not on the grid: %v", v)
+	}
+
+	// The modifier turns snapping off.
+	dragVertex(f, graphics.Ptf(160, 150), graphics.Ptf(173, 152), SNAP_OFF_MODIFIER)
+	if v := vertex(t, f, id, 2); !v.Eq(graphics.Ptf(173, 152)) {
+		t.Errorf("vertex snapped: %v", v)
+	}
+}
+
+func Test_SnapToVerticesAndEdges(t *testing.T) {
+	f := NewFrame()
+	a := f.AddElement(graphics.Ptf(100, 100))
+	f.AddElement(graphics.Ptf(300, 100))
+	f.SetSnapping(Snapping{Vertices: true, Edges: true})
+
+	// Vertex 0 of the second quad is at (255, 55).
+	guides := dragVertex(f, graphics.Ptf(145, 55), graphics.Ptf(251, 58), 0)
+	if v := vertex(t, f, a, 1); !v.Eq(graphics.Ptf(255, 55)) {
+		t.Errorf("vertex didn't snap to a vertex: %v", v)
+	}
+	if len(guides) != 1 || !guides[0].from.Eq(graphics.Ptf(255, 55)) {
+		t.Errorf("bad guides %v", guides)
+	}
+	testhelpers.AssertInt(t, 0, len(f.guides))
+
+	// Its left edge is at x = 255.
+	guides = dragVertex(f, graphics.Ptf(145, 145), graphics.Ptf(259, 100), 0)
+	if v := vertex(t, f, a, 2); !v.Eq(graphics.Ptf(255, 100)) {
+		t.Errorf("vertex didn't snap to an edge: %v", v)
+	}
+	if len(guides) != 1 || guides[0].from.X != 255 || guides[0].to.X != 255 {
+		t.Errorf("bad guides %v", guides)
+	}
+}
+
+func Test_SnapAlignment(t *testing.T) {
+	f := NewFrame()
+	id := f.AddElement(graphics.Ptf(100, 100))
+	f.SetSnapping(Snapping{Align: true, Grid: 100})
+
+	// Vertex 3 is at (55, 145): vertex 2 comes into line with it and the
+	// grid takes care of x.
+	guides := dragVertex(f, graphics.Ptf(145, 145), graphics.Ptf(190, 141), 0)
+	if v := vertex(t, f, id, 2); !v.Eq(graphics.Ptf(200, 145)) {
+		t.Errorf("vertex not aligned: %v", v)
+	}
+	if len(guides) != 1 || !guides[0].from.Eq(graphics.Ptf(55, 145)) || !guides[0].to.Eq(graphics.Ptf(200, 145)) {
+		t.Errorf("bad guides %v", guides)
+	}
+}
+
+func Test_SnapZoomed(t *testing.T) {
+	f := NewFrame()
+	id := f.AddElement(graphics.Ptf(100, 100))
+	f.AddElement(graphics.Ptf(300, 100))
+	f.SetSnapping(Snapping{Vertices: true})
+	// 4 document units is 16 screen pixels: too far to snap.
+	f.DisplayList(graphics.Scale(4, 4))
+	dragVertex(f, graphics.Ptf(145, 55), graphics.Ptf(251, 55), 0)
+	if v := vertex(t, f, id, 1); !v.Eq(graphics.Ptf(251, 55)) {
+		t.Errorf("vertex snapped from too far: %v", v)
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/go-gl/gl"
+)
+
+// The GL path feathers anti-aliased edges over a band FEATHER_WIDTH pixels
+// wide centred on the edge. Corners sharper than MAX_FEATHER_MITER are
+// bevelled rather than mitered.
+const (
+	FEATHER_WIDTH     = 1
+	MAX_FEATHER_MITER = 4
+)
+
+// SetAntialias sets whether subsequent quads and fills are anti-aliased.
+// They are by default. Turn it off for crisp, pixel aligned edges. Saved
+// and restored by Save and Restore.
+func (dl *DisplayList) SetAntialias(on bool) {
+	dl.current().antialias = on
+}
+
+// Antialias reports whether subsequent quads and fills are anti-aliased.
+func (dl *DisplayList) Antialias() bool {
+	return dl.current().antialias
+}
+
+func (dl *DisplayList) antialiasFlag() uint32 {
+	if dl.current().antialias {
+		return 1
+	}
+	return 0
+}
+
+const (
+	// The coverage vertex shader is the default one passing along the
+	// fraction of the pixel covered at each vertex.
+	coverageVertexShader = `
+#version 400
+
+uniform vec2 u_Viewport;
+
+in vec2 in_Position;
+in float in_Coverage;
+out float v_Coverage;
+
+void main()
+{
+    gl_Position = vec4(2.0 * in_Position.x * u_Viewport.x - 1.0,
+                       -(2.0 * in_Position.y * u_Viewport.y - 1.0),
+                       0.0, 1.0);
+    v_Coverage = in_Coverage;
+}` // coverageVertexShader
+
+	// It is formatted with the paint shader.
+	coverageFragmentShader = `
+#version 400
+
+uniform float u_Height;
+in float v_Coverage;
+out vec4 out_Color;
+%s
+void main()
+{
+    vec4 c = paint(vec2(gl_FragCoord.x, u_Height - gl_FragCoord.y));
+    out_Color = vec4(c.rgb, c.a * v_Coverage);
+}` // coverageFragmentShader
+)
+
+var (
+	coverageProgram      gl.Program
+	coverageProgramReady bool
+)
+
+func useCoverageProgram() gl.Program {
+	if !coverageProgramReady {
+		coverageProgram = createProgram(coverageVertexShader, fmt.Sprintf(coverageFragmentShader, paintShader()))
+		coverageProgramReady = true
+	}
+	coverageProgram.Use()
+	return coverageProgram
+}
+
+// drawCoverage draws triangles with vertices given as x, y and coverage,
+// in viewport coordinates, in the current paint scaled by the coverage
+// interpolated across them. It leaves program in use.
+func (dl *DisplayList) drawCoverage(program *gl.Program, vertices []float32) {
+	if len(vertices) == 0 {
+		return
+	}
+	cp := useCoverageProgram()
+	defer program.Use()
+	cp.GetUniformLocation("u_Viewport").Uniform2f(1/dl.cur_width, 1/dl.cur_height)
+	dl.setPaintUniforms(cp)
+	drawVertices(cp, vertices)
+}
+
+// drawVertices draws triangles with vertices given as x, y and coverage
+// with program, which has the coverage vertex shader.
+func drawVertices(program gl.Program, vertices []float32) {
+	// FIXME: as in drawTriangles, we should retain these.
+	vao := gl.GenVertexArray()
+	vao.Bind()
+	vbo := gl.GenBuffer()
+	vbo.Bind(gl.ARRAY_BUFFER)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, vertices, gl.STATIC_DRAW)
+
+	positionAttrib := program.GetAttribLocation("in_Position")
+	positionAttrib.AttribPointer(2, gl.FLOAT, false, 12, nil)
+	positionAttrib.EnableArray()
+	defer positionAttrib.DisableArray()
+	coverageAttrib := program.GetAttribLocation("in_Coverage")
+	coverageAttrib.AttribPointer(1, gl.FLOAT, false, 12, uintptr(8))
+	coverageAttrib.EnableArray()
+	defer coverageAttrib.DisableArray()
+
+	gl.DrawArrays(gl.TRIANGLES, 0, len(vertices)/3)
+
+	CheckForGLErrors()
+}
+
+// feather appends to vertices the triangles, as x, y and coverage, of the
+// convex polygon ps with its edges faded out over FEATHER_WIDTH pixels: an
+// inset polygon of full coverage and a ring around it fading to none.
+func feather(vertices []float32, ps []Pointf) []float32 {
+	// Drop repeated vertices, which have no edge to offset along.
+	var poly []Pointf
+	for i, p := range ps {
+		if !p.Eq(ps[(i+1)%len(ps)]) {
+			poly = append(poly, p)
+		}
+	}
+	n := len(poly)
+	if n < 3 {
+		return vertices
+	}
+	var area float32
+	for i, p := range poly {
+		area += cross(p, poly[(i+1)%n])
+	}
+	if area == 0 {
+		return vertices
+	}
+	// Outward normals are on the left of edges of negative area polygons,
+	// y being down.
+	side := float32(FEATHER_WIDTH) / 2
+	if area > 0 {
+		side = -side
+	}
+
+	inner := make([]Pointf, n)
+	outer := make([]Pointf, n)
+	for i, p := range poly {
+		n0 := normal(unit(p.Sub(poly[(i+n-1)%n])))
+		n1 := normal(unit(poly[(i+1)%n].Sub(p)))
+		// The miter of the two normals reaches where the offset edges
+		// meet. It is longer than MAX_FEATHER_MITER when 1+n0·n1 is less
+		// than 2/MAX_FEATHER_MITER².
+		m := n0.Add(n1)
+		if d := 1 + dot(n0, n1); d >= 2/(MAX_FEATHER_MITER*MAX_FEATHER_MITER) {
+			m = m.Mul(1 / d)
+		} else if length(m) > 1e-3 {
+			m = unit(m).Mul(MAX_FEATHER_MITER)
+		} else {
+			m = n1
+		}
+		inner[i] = p.Sub(m.Mul(side))
+		outer[i] = p.Add(m.Mul(side))
+	}
+
+	vertex := func(p Pointf, coverage float32) {
+		vertices = append(vertices, p.X, p.Y, coverage)
+	}
+	for i := 1; i+1 < n; i++ {
+		vertex(inner[0], 1)
+		vertex(inner[i], 1)
+		vertex(inner[i+1], 1)
+	}
+	for i := range poly {
+		j := (i + 1) % n
+		vertex(outer[i], 0)
+		vertex(outer[j], 0)
+		vertex(inner[j], 1)
+		vertex(outer[i], 0)
+		vertex(inner[j], 1)
+		vertex(inner[i], 1)
+	}
+	return vertices
+}
+
+// fringe returns the triangles, as x, y and coverage, of strips
+// FEATHER_WIDTH pixels wide along the edges of contours, fading from half
+// coverage on the edges to none. Drawn outside a fill, they approximate
+// the coverage of the pixels its edges pass through.
+func fringe(contours [][]Pointf) []float32 {
+	var vertices []float32
+	vertex := func(p Pointf, coverage float32) {
+		vertices = append(vertices, p.X, p.Y, coverage)
+	}
+	for _, c := range contours {
+		for i, p := range c {
+			q := c[(i+1)%len(c)]
+			if p.Eq(q) {
+				continue
+			}
+			o := normal(unit(q.Sub(p))).Mul(FEATHER_WIDTH / 2.)
+			for _, o := range []Pointf{o, o.Mul(-1)} {
+				vertex(p, .5)
+				vertex(q, .5)
+				vertex(q.Add(o), 0)
+				vertex(p, .5)
+				vertex(q.Add(o), 0)
+				vertex(p.Add(o), 0)
+			}
+		}
+	}
+	return vertices
+}
+
+// fillCoverage fills the contours, in viewport coordinates, following rule,
+// covering each pixel by the exact fraction of its area inside them.
+//
+// Each row of pixels is cut into strips at the ends of the edges and where
+// they cross, so that within a strip the edges don't cross and the inside
+// is a run of trapezoids between neighbouring edges.
+func (sr *softwareRasterizer) fillCoverage(contours [][]Pointf, rule uint32) {
+	var edges []coverageEdge
+	b := Rectanglef{contours[0][0], contours[0][0]}
+	for _, c := range contours {
+		for i, p := range c {
+			b = b.Union(Rectanglef{p, p})
+			q := c[(i+1)%len(c)]
+			if p.Y == q.Y {
+				continue
+			}
+			e := coverageEdge{float64(p.X), float64(p.Y), float64(q.X), float64(q.Y), 1}
+			if p.Y > q.Y {
+				e = coverageEdge{float64(q.X), float64(q.Y), float64(p.X), float64(p.Y), -1}
+			}
+			edges = append(edges, e)
+		}
+	}
+	r := image.Rect(
+		int(math.Floor(float64(b.Min.X))), int(math.Floor(float64(b.Min.Y))),
+		int(math.Ceil(float64(b.Max.X))), int(math.Ceil(float64(b.Max.Y)))).Intersect(sr.clip)
+	if r.Empty() {
+		return
+	}
+
+	cover := make([]float64, r.Dx())
+	var row []coverageEdge
+	var ys []float64
+	var strip stripEdges
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		top, bottom := float64(y), float64(y+1)
+		row = row[:0]
+		ys = append(ys[:0], top, bottom)
+		for _, e := range edges {
+			if e.y1 <= top || e.y0 >= bottom {
+				continue
+			}
+			row = append(row, e)
+			for _, ey := range []float64{e.y0, e.y1} {
+				if ey > top && ey < bottom {
+					ys = append(ys, ey)
+				}
+			}
+		}
+		if len(row) == 0 {
+			continue
+		}
+		for i, e := range row {
+			for _, f := range row[i+1:] {
+				// Where the lines of e and f cross, solving
+				// e.x(y) == f.x(y).
+				de := (e.x1 - e.x0) / (e.y1 - e.y0)
+				df := (f.x1 - f.x0) / (f.y1 - f.y0)
+				if de == df {
+					continue
+				}
+				cy := ((f.x0 - f.y0*df) - (e.x0 - e.y0*de)) / (de - df)
+				if cy > top && cy < bottom {
+					ys = append(ys, cy)
+				}
+			}
+		}
+		sort.Float64s(ys)
+
+		for i := range cover {
+			cover[i] = 0
+		}
+		for i := 0; i+1 < len(ys); i++ {
+			ya, yb := ys[i], ys[i+1]
+			if yb <= ya {
+				continue
+			}
+			// The strip's edges ordered by where they cross its middle.
+			ym := (ya + yb) / 2
+			strip = strip[:0]
+			for _, e := range row {
+				if e.y0 <= ym && e.y1 > ym {
+					strip = append(strip, stripEdge{e, e.x(ym)})
+				}
+			}
+			sort.Sort(strip)
+
+			winding := 0
+			for j := 0; j+1 < len(strip); j++ {
+				l, rt := strip[j].coverageEdge, strip[j+1].coverageEdge
+				winding += l.dir
+				if winding == 0 || (rule == FILL_EVEN_ODD && winding%2 == 0) {
+					continue
+				}
+				addTrapezoid(cover, r.Min.X, yb-ya, l.x(ya), l.x(yb), rt.x(ya), rt.x(yb))
+			}
+		}
+		for i, c := range cover {
+			if c > 0 {
+				sr.blend(r.Min.X+i, y, sr.colorAt(r.Min.X+i, y), float32(math.Min(c, 1)))
+			}
+		}
+	}
+}
+
+// A coverageEdge goes down from x0, y0 to x1, y1. Its dir is 1 if the
+// contour goes down it and -1 if up.
+type coverageEdge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+// x returns where e crosses y.
+func (e coverageEdge) x(y float64) float64 {
+	return e.x0 + (y-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+}
+
+// A stripEdge is an edge crossing the middle of a strip at xm.
+type stripEdge struct {
+	coverageEdge
+	xm float64
+}
+
+type stripEdges []stripEdge
+
+func (a stripEdges) Len() int           { return len(a) }
+func (a stripEdges) Less(i, j int) bool { return a[i].xm < a[j].xm }
+func (a stripEdges) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// addTrapezoid adds to cover, the coverage of a row of pixels starting at
+// x0, the area in each pixel of a trapezoid of height h whose left side
+// goes from l0 to l1 and right side from r0 to r1.
+func addTrapezoid(cover []float64, x0 int, h, l0, l1, r0, r1 float64) {
+	from := int(math.Floor(math.Min(l0, l1))) - x0
+	to := int(math.Ceil(math.Max(r0, r1))) - x0
+	if from < 0 {
+		from = 0
+	}
+	if to > len(cover) {
+		to = len(cover)
+	}
+	for i := from; i < to; i++ {
+		px := float64(x0 + i)
+		// The width of the pixel left of the right side less that left of
+		// the left side, averaged down the strip.
+		cover[i] += h * (clampIntegral(r0-px, r1-r0) - clampIntegral(l0-px, l1-l0))
+	}
+}
+
+// clampIntegral returns the integral over s from 0 to 1 of a+b*s clamped
+// to [0, 1].
+func clampIntegral(a, b float64) float64 {
+	clamp := func(v float64) float64 {
+		return math.Max(0, math.Min(1, v))
+	}
+	if b == 0 {
+		return clamp(a)
+	}
+	// The clamped line is straight between where it crosses 0 and 1, so
+	// the midpoint rule is exact on each piece.
+	ss := []float64{0, 1}
+	for _, s := range []float64{-a / b, (1 - a) / b} {
+		if s > 0 && s < 1 {
+			ss = append(ss, s)
+		}
+	}
+	sort.Float64s(ss)
+	var sum float64
+	for i := 0; i+1 < len(ss); i++ {
+		sum += (ss[i+1] - ss[i]) * clamp(a+b*(ss[i]+ss[i+1])/2)
+	}
+	return sum
+}
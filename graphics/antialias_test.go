@@ -0,0 +1,109 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func TestAntialiasedQuadEdges(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.DrawQuads([][4]Pointf{{{2.5, 2}, {8.5, 2}, {8.5, 8}, {2.5, 8}}})
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 5, 5)
+	AssertRGBA(t, color.RGBA{0x80, 0, 0, 0x80}, img, 2, 5)
+	AssertRGBA(t, color.RGBA{0x80, 0, 0, 0x80}, img, 8, 5)
+	AssertRGBA(t, color.RGBA{}, img, 1, 5)
+	AssertRGBA(t, color.RGBA{}, img, 9, 5)
+}
+
+func TestAntialiasedDiagonal(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.FillPolygon([]Pointf{{0, 0}, {20, 0}, {0, 20}}, FILL_NONZERO)
+	img := rasterize(dl)
+
+	// Half of each pixel on the diagonal is inside.
+	for i := 0; i < 20; i++ {
+		AssertRGBA(t, color.RGBA{0x80, 0, 0, 0x80}, img, i, 19-i)
+	}
+	AssertRGBA(t, red, img, 5, 5)
+	AssertRGBA(t, color.RGBA{}, img, 15, 15)
+}
+
+func TestAntialiasedCrossing(t *testing.T) {
+	// A bowtie crossing in the middle of pixel 10, 10 covers a quarter of
+	// it with each half.
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.FillPolygon([]Pointf{{0, 0}, {21, 21}, {0, 21}, {21, 0}}, FILL_EVEN_ODD)
+	img := rasterize(dl)
+
+	AssertRGBA(t, color.RGBA{0x80, 0, 0, 0x80}, img, 10, 10)
+	AssertRGBA(t, red, img, 10, 3)
+	AssertRGBA(t, color.RGBA{}, img, 3, 10)
+}
+
+func TestAntialiasOff(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.Save()
+	dl.SetAntialias(false)
+	AssertFalse(t, dl.Antialias())
+	dl.DrawQuads([][4]Pointf{{{2.5, 2}, {8.5, 2}, {8.5, 8}, {2.5, 8}}})
+	dl.FillPolygon([]Pointf{{10, 0}, {20, 0}, {10, 10}}, FILL_NONZERO)
+	dl.Restore()
+	AssertTrue(t, dl.Antialias())
+	img := rasterize(dl)
+
+	// Pixels are covered when their centres are.
+	AssertRGBA(t, red, img, 2, 5)
+	AssertRGBA(t, color.RGBA{}, img, 8, 5)
+	AssertRGBA(t, red, img, 14, 4)
+	AssertRGBA(t, color.RGBA{}, img, 15, 4)
+}
+
+func TestClampIntegral(t *testing.T) {
+	AssertFloatEqual(t, 0.5, float32(clampIntegral(0.5, 0)))
+	AssertFloatEqual(t, 0.5, float32(clampIntegral(0, 1)))
+	AssertFloatEqual(t, 0.5, float32(clampIntegral(1, -1)))
+	// Clamped to 1 for the second half.
+	AssertFloatEqual(t, 0.875, float32(clampIntegral(0.5, 1)))
+	AssertFloatEqual(t, 0.125, float32(clampIntegral(-0.5, 1)))
+	AssertFloatEqual(t, 1, float32(clampIntegral(2, 3)))
+}
+
+func TestFeather(t *testing.T) {
+	// Either orientation has its outer ring outside the square.
+	for _, q := range [][]Pointf{
+		{{2, 2}, {8, 2}, {8, 8}, {2, 8}},
+		{{2, 2}, {2, 8}, {8, 8}, {8, 2}},
+	} {
+		vs := feather(nil, q)
+		testhelpers.AssertInt(t, 3*3*(2+4*2), len(vs))
+		for i := 0; i < len(vs); i += 3 {
+			p := Pointf{vs[i], vs[i+1]}
+			outside := p.X < 2 || p.X > 8 || p.Y < 2 || p.Y > 8
+			if outside != (vs[i+2] == 0) {
+				t.Errorf("vertex %v has coverage %f", p, vs[i+2])
+			}
+		}
+	}
+}
@@ -16,6 +16,7 @@
 package graphics
 
 import (
+	"errors"
 	"math"
 )
 
@@ -26,7 +27,8 @@ type Arc struct {
 	// of the doubled angle. Roughly speaking, it's the "depth" of the arc.
 	//
 	// NB: It MUST be the case that -1 < d < 1. In fact, it would do well to be
-	// smaller than 1/2 in magnitude.
+	// smaller than 1/2 in magnitude. ArcsFromCenter and Subdivide make Arcs
+	// that are.
 	d float32
 }
 
@@ -183,3 +185,113 @@ func (arc *Arc) EuclideanDistanceTo(point *Dual) float32 {
 	}
 	return distance
 }
+
+// NewArc returns the Arc from p0 to p1 with depth d. See Arc.d.
+func NewArc(p0, p1 Pointf, d float32) *Arc {
+	return &Arc{&Dual{p0.X, p0.Y, 1}, &Dual{p1.X, p1.Y, 1}, d}
+}
+
+// ArcFromCenter returns the Arc of the circle with centre c and radius r
+// that starts at angle start and turns through sweep radians. Angles are
+// measured from the x axis towards the y axis. An Arc must sweep less than
+// a half circle: use ArcsFromCenter for bigger ones.
+func ArcFromCenter(c Pointf, r, start, sweep float32) (*Arc, error) {
+	if r <= 0 || sweep == 0 || AbsF(sweep) >= math.Pi {
+		return nil, errors.New("graphics: an Arc needs a positive radius and a sweep less than a half circle")
+	}
+	p0 := c.Add(polar(r, start))
+	p1 := c.Add(polar(r, start+sweep))
+	// Each half of the arc subtends 2 * atan(d) at the centre. See Normals.
+	d := -float32(math.Tan(float64(sweep) / 4))
+	return NewArc(p0, p1, d), nil
+}
+
+// ArcThroughPoints returns the Arc from a to c that passes through b.
+func ArcThroughPoints(a, b, c Pointf) (*Arc, error) {
+	center, start, sweep, err := circleThrough(a, b, c)
+	if err != nil {
+		return nil, err
+	}
+	return ArcFromCenter(center, length(a.Sub(center)), start, sweep)
+}
+
+// circleThrough returns the centre of the circle through a, b and c and
+// the angles of the arc from a to c through b.
+func circleThrough(a, b, c Pointf) (center Pointf, start, sweep float32, err error) {
+	da, db, dc := &Dual{a.X, a.Y, 1}, &Dual{b.X, b.Y, 1}, &Dual{c.X, c.Y, 1}
+	o := da.Bisector(db).Intersection(db.Bisector(dc))
+	if o.w == 0 {
+		return center, 0, 0, errors.New("graphics: the points of an Arc can't be collinear")
+	}
+	o = o.Normalize()
+	center = Pointf{o.x, o.y}
+	start = angle(a.Sub(center))
+	sweep = angle(c.Sub(center)) - start
+	// Go the way round that passes through b.
+	if cross(b.Sub(a), c.Sub(b)) > 0 {
+		for sweep < 0 {
+			sweep += 2 * math.Pi
+		}
+	} else {
+		for sweep > 0 {
+			sweep -= 2 * math.Pi
+		}
+	}
+	return center, start, sweep, nil
+}
+
+// P0 returns the start of the Arc.
+func (arc *Arc) P0() Pointf {
+	return Pointf{arc.p0.x / arc.p0.w, arc.p0.y / arc.p0.w}
+}
+
+// P1 returns the end of the Arc.
+func (arc *Arc) P1() Pointf {
+	return Pointf{arc.p1.x / arc.p1.w, arc.p1.y / arc.p1.w}
+}
+
+// Depth returns d. 0 means that the Arc is a straight line.
+func (arc *Arc) Depth() float32 {
+	return arc.d
+}
+
+// Center returns the centre of the Arc's circle. The Arc mustn't be
+// straight.
+func (arc *Arc) Center() Pointf {
+	p0, p1 := arc.P0(), arc.P1()
+	mid := p0.Add(p1).Mul(0.5)
+	chord := p1.Sub(p0)
+	// The apex is mid + d/2 * perp and the centre is on the same line.
+	perp := Pointf{-chord.Y, chord.X}
+	return mid.Add(perp.Mul((arc.d*arc.d - 1) / (4 * arc.d)))
+}
+
+// Radius returns the radius of the Arc's circle. The Arc mustn't be
+// straight.
+func (arc *Arc) Radius() float32 {
+	return length(arc.P1().Sub(arc.P0())) * (1 + arc.d*arc.d) / (4 * AbsF(arc.d))
+}
+
+// Angles returns the angle of P0 about the Center and the angle that the
+// Arc turns through to get to P1.
+func (arc *Arc) Angles() (start, sweep float32) {
+	start = angle(arc.P0().Sub(arc.Center()))
+	sweep = -4 * float32(math.Atan(float64(arc.d)))
+	return
+}
+
+// polar returns the vector of length r at angle theta.
+func polar(r, theta float32) Pointf {
+	s, c := math.Sincos(float64(theta))
+	return Pointf{r * float32(c), r * float32(s)}
+}
+
+// angle returns the angle of v from the x axis.
+func angle(v Pointf) float32 {
+	return float32(math.Atan2(float64(v.Y), float64(v.X)))
+}
+
+// length returns the length of v.
+func length(v Pointf) float32 {
+	return float32(math.Hypot(float64(v.X), float64(v.Y)))
+}
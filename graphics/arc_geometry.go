@@ -0,0 +1,251 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"errors"
+	"math"
+)
+
+// ARC_MAX_DEPTH is the largest |d| of the Arcs made by ArcsFromCenter and
+// Subdivide. Arcs this shallow keep their wedges and distances well
+// conditioned.
+const ARC_MAX_DEPTH = 0.5
+
+// Angles within angleTolerance of the end of an Arc are on it.
+const angleTolerance = 1e-5
+
+// ArcsFromCenter returns Arcs, each no deeper than ARC_MAX_DEPTH, that
+// together make the arc of the circle with centre c and radius r that
+// starts at angle start and turns through sweep radians. The sweep may be
+// any size.
+func ArcsFromCenter(c Pointf, r, start, sweep float32) ([]*Arc, error) {
+	if r <= 0 || sweep == 0 || math.IsInf(float64(sweep), 0) || math.IsNaN(float64(sweep)) {
+		return nil, errors.New("graphics: arcs need a positive radius and a finite sweep")
+	}
+	maxSweep := 4 * math.Atan(ARC_MAX_DEPTH)
+	n := int(math.Ceil(math.Abs(float64(sweep)) / maxSweep))
+	step := sweep / float32(n)
+	d := -float32(math.Tan(float64(step) / 4))
+	arcs := make([]*Arc, n)
+	p0 := c.Add(polar(r, start))
+	for i := range arcs {
+		p1 := c.Add(polar(r, start+float32(i+1)*step))
+		arcs[i] = NewArc(p0, p1, d)
+		p0 = p1
+	}
+	return arcs, nil
+}
+
+// ArcsThroughPoints returns Arcs as ArcsFromCenter does that go from a to c
+// through b.
+func ArcsThroughPoints(a, b, c Pointf) ([]*Arc, error) {
+	center, start, sweep, err := circleThrough(a, b, c)
+	if err != nil {
+		return nil, err
+	}
+	arcs, err := ArcsFromCenter(center, length(a.Sub(center)), start, sweep)
+	if err != nil {
+		return nil, err
+	}
+	// Exactly the given ends.
+	arcs[0].p0 = &Dual{a.X, a.Y, 1}
+	arcs[len(arcs)-1].p1 = &Dual{c.X, c.Y, 1}
+	return arcs, nil
+}
+
+// Subdivide splits the Arc in halves until no piece is deeper than
+// ARC_MAX_DEPTH.
+func (arc *Arc) Subdivide() []*Arc {
+	if AbsF(arc.d) <= ARC_MAX_DEPTH {
+		return []*Arc{arc}
+	}
+	a, b, _ := arc.Split(0.5)
+	return append(a.Subdivide(), b.Subdivide()...)
+}
+
+// Split splits the Arc at the fraction t of its length. ok is false unless
+// 0 < t < 1.
+func (arc *Arc) Split(t float32) (a, b *Arc, ok bool) {
+	if !(t > 0 && t < 1) {
+		return nil, nil, false
+	}
+	p0, p1 := arc.P0(), arc.P1()
+	if arc.d == 0 {
+		mid := p0.Add(p1.Sub(p0).Mul(t))
+		return NewArc(p0, mid, 0), NewArc(mid, p1, 0), true
+	}
+	start, sweep := arc.Angles()
+	mid := arc.Center().Add(polar(arc.Radius(), start+t*sweep))
+	a = NewArc(p0, mid, -float32(math.Tan(float64(t*sweep)/4)))
+	b = NewArc(mid, p1, -float32(math.Tan(float64((1-t)*sweep)/4)))
+	return a, b, true
+}
+
+// SplitAngle splits the Arc at the point at angle theta about its Center.
+// ok is false if that point isn't strictly inside the Arc or the Arc is
+// straight.
+func (arc *Arc) SplitAngle(theta float32) (a, b *Arc, ok bool) {
+	if arc.d == 0 {
+		return nil, nil, false
+	}
+	_, sweep := arc.Angles()
+	return arc.Split(arc.turn(theta) / AbsF(sweep))
+}
+
+// Length returns the length of the Arc.
+func (arc *Arc) Length() float32 {
+	if arc.d == 0 {
+		return length(arc.P1().Sub(arc.P0()))
+	}
+	_, sweep := arc.Angles()
+	return arc.Radius() * AbsF(sweep)
+}
+
+// Bounds returns the smallest Rectanglef that encloses the Arc.
+func (arc *Arc) Bounds() Rectanglef {
+	p0, p1 := arc.P0(), arc.P1()
+	r := Rectanglef{p0, p0}.Union(Rectanglef{p1, p1})
+	if arc.d == 0 {
+		return r
+	}
+	c, radius := arc.Center(), arc.Radius()
+	// The extremes of the circle that are on the Arc.
+	for i := 0; i < 4; i++ {
+		theta := float32(i) * math.Pi / 2
+		if arc.onArc(theta) {
+			p := c.Add(polar(radius, theta))
+			r = r.Union(Rectanglef{p, p})
+		}
+	}
+	return r
+}
+
+// IntersectLine returns the points where the line through p and q crosses
+// the Arc.
+func (arc *Arc) IntersectLine(p, q Pointf) []Pointf {
+	return arc.intersectLine(p, q, false)
+}
+
+// IntersectSegment returns the points where the segment from p to q
+// crosses the Arc.
+func (arc *Arc) IntersectSegment(p, q Pointf) []Pointf {
+	return arc.intersectLine(p, q, true)
+}
+
+// IntersectArc returns the points where the Arc crosses o. Arcs on the
+// same circle and overlapping straight Arcs don't cross.
+func (arc *Arc) IntersectArc(o *Arc) []Pointf {
+	if o.d == 0 {
+		return arc.IntersectSegment(o.P0(), o.P1())
+	}
+	if arc.d == 0 {
+		return o.IntersectSegment(arc.P0(), arc.P1())
+	}
+	c0, r0 := arc.Center(), arc.Radius()
+	c1, r1 := o.Center(), o.Radius()
+	v := c1.Sub(c0)
+	d := length(v)
+	if d == 0 || d > r0+r1 || d < AbsF(r0-r1) {
+		return nil
+	}
+	// The points are a along the line of centres from c0 and h either side
+	// of it.
+	a := (r0*r0 - r1*r1 + d*d) / (2 * d)
+	h := float32(math.Sqrt(float64(MaxF(0, r0*r0-a*a))))
+	u := v.Mul(1 / d)
+	base := c0.Add(u.Mul(a))
+	perp := Pointf{-u.Y, u.X}
+	candidates := []Pointf{base.Add(perp.Mul(h))}
+	if h > 0 {
+		candidates = append(candidates, base.Sub(perp.Mul(h)))
+	}
+	var ps []Pointf
+	for _, p := range candidates {
+		if arc.onArc(angle(p.Sub(c0))) && o.onArc(angle(p.Sub(c1))) {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// intersectLine returns the points where the line through p and q, or
+// only the segment if segment is set, crosses the Arc.
+func (arc *Arc) intersectLine(p, q Pointf, segment bool) []Pointf {
+	v := q.Sub(p)
+	onLine := func(t float32) bool {
+		return !segment || (t >= 0 && t <= 1)
+	}
+	var ps []Pointf
+	if arc.d == 0 {
+		p0, p1 := arc.P0(), arc.P1()
+		w := p1.Sub(p0)
+		den := cross(v, w)
+		if den == 0 {
+			return nil
+		}
+		t := cross(p0.Sub(p), w) / den
+		s := cross(p0.Sub(p), v) / den
+		if onLine(t) && s >= 0 && s <= 1 {
+			ps = append(ps, p.Add(v.Mul(t)))
+		}
+		return ps
+	}
+
+	// Solve |p + t*v - c| = r.
+	c, r := arc.Center(), arc.Radius()
+	pc := p.Sub(c)
+	a := v.X*v.X + v.Y*v.Y
+	b := 2 * (v.X*pc.X + v.Y*pc.Y)
+	disc := b*b - 4*a*(pc.X*pc.X+pc.Y*pc.Y-r*r)
+	if a == 0 || disc < 0 {
+		return nil
+	}
+	sq := float32(math.Sqrt(float64(disc)))
+	ts := []float32{(-b - sq) / (2 * a)}
+	if sq > 0 {
+		ts = append(ts, (-b+sq)/(2*a))
+	}
+	for _, t := range ts {
+		x := p.Add(v.Mul(t))
+		if onLine(t) && arc.onArc(angle(x.Sub(c))) {
+			ps = append(ps, x)
+		}
+	}
+	return ps
+}
+
+// turn returns how far the Arc turns from P0 to get to angle theta about
+// its Center, between 0 and 2 pi.
+func (arc *Arc) turn(theta float32) float32 {
+	start, sweep := arc.Angles()
+	off := float64(theta - start)
+	if sweep < 0 {
+		off = -off
+	}
+	off = math.Mod(off, 2*math.Pi)
+	if off < 0 {
+		off += 2 * math.Pi
+	}
+	return float32(off)
+}
+
+// onArc reports whether the point at angle theta about the Center of a
+// curved Arc is on it.
+func (arc *Arc) onArc(theta float32) bool {
+	_, sweep := arc.Angles()
+	off := arc.turn(theta)
+	return off <= AbsF(sweep)+angleTolerance || off >= 2*math.Pi-angleTolerance
+}
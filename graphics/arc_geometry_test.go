@@ -0,0 +1,161 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArcsFromCenter(t *testing.T) {
+	c := Pointf{1, 2}
+	arcs, err := ArcsFromCenter(c, 3, 0.5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arcs) != 3 {
+		t.Fatalf("expected 3 arcs, got %d", len(arcs))
+	}
+	var total float32
+	for i, a := range arcs {
+		AssertTrue(t, AbsF(a.Depth()) <= ARC_MAX_DEPTH)
+		AssertPointEqual(t, c, a.Center())
+		AssertFloatEqual(t, 3, a.Radius())
+		if i > 0 {
+			AssertPointEqual(t, arcs[i-1].P1(), a.P0())
+		}
+		total += a.Length()
+	}
+	AssertFloatEqual(t, 15, total)
+	AssertPointEqual(t, c.Add(polar(3, 5.5)), arcs[2].P1())
+
+	// A whole circle.
+	arcs, err = ArcsFromCenter(c, 3, 0, -2*math.Pi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertPointEqual(t, arcs[0].P0(), arcs[len(arcs)-1].P1())
+
+	if _, err := ArcsFromCenter(c, 3, 0, float32(math.Inf(1))); err == nil {
+		t.Error("made arcs with an infinite sweep")
+	}
+}
+
+func TestArcsThroughPoints(t *testing.T) {
+	c := Pointf{2, 3}
+	p := func(theta float32) Pointf { return c.Add(polar(4, theta)) }
+	arcs, err := ArcsThroughPoints(p(0), p(2), p(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertTrue(t, len(arcs) > 1)
+	if arcs[0].P0() != p(0) || arcs[len(arcs)-1].P1() != p(4) {
+		t.Errorf("ends moved: %v %v", arcs[0].P0(), arcs[len(arcs)-1].P1())
+	}
+	var sweep float32
+	for _, a := range arcs {
+		_, s := a.Angles()
+		sweep += s
+	}
+	AssertFloatEqual(t, 4, sweep)
+}
+
+func TestArcSplit(t *testing.T) {
+	arc, _ := ArcFromCenter(Pointf{0, 0}, 2, 0, 3)
+	a, b, ok := arc.Split(0.25)
+	AssertTrue(t, ok)
+	AssertPointEqual(t, polar(2, 0.75), a.P1())
+	AssertPointEqual(t, a.P1(), b.P0())
+	AssertPointEqual(t, arc.P1(), b.P1())
+	AssertFloatEqual(t, arc.Length(), a.Length()+b.Length())
+	_, s := b.Angles()
+	AssertFloatEqual(t, 2.25, s)
+
+	a, b, ok = arc.SplitAngle(1)
+	AssertTrue(t, ok)
+	AssertFloatEqual(t, 2, a.Length())
+	AssertFloatEqual(t, 4, b.Length())
+	_, _, ok = arc.SplitAngle(-1)
+	AssertFalse(t, ok)
+	_, _, ok = arc.Split(1)
+	AssertFalse(t, ok)
+
+	pieces := arc.Subdivide()
+	if len(pieces) != 2 {
+		t.Errorf("expected 2 pieces, got %d", len(pieces))
+	}
+
+	line := NewArc(Pointf{0, 0}, Pointf{4, 0}, 0)
+	a, b, ok = line.Split(0.25)
+	AssertTrue(t, ok)
+	AssertPointEqual(t, Pointf{1, 0}, a.P1())
+	AssertFloatEqual(t, 3, b.Length())
+	_, _, ok = line.SplitAngle(1)
+	AssertFalse(t, ok)
+}
+
+func TestArcBounds(t *testing.T) {
+	// From the top of the circle, clockwise on screen, round to the bottom.
+	arc, _ := ArcFromCenter(Pointf{10, 10}, 5, -math.Pi/2+0.1, 2.9)
+	r := arc.Bounds()
+	AssertFloatEqual(t, 15, r.Max.X)
+	AssertFloatEqual(t, arc.P0().Y, r.Min.Y)
+	AssertFloatEqual(t, arc.P1().Y, r.Max.Y)
+	AssertFloatEqual(t, arc.P0().X, r.Min.X)
+
+	// The other way round passes through the left side.
+	arc, _ = ArcFromCenter(Pointf{10, 10}, 5, math.Pi/2-0.1, 2.9)
+	AssertFloatEqual(t, 5, arc.Bounds().Min.X)
+}
+
+func TestArcIntersections(t *testing.T) {
+	arc, _ := ArcFromCenter(Pointf{0, 0}, 5, -0.5, 2)
+	ps := arc.IntersectLine(Pointf{3, -10}, Pointf{3, -9})
+	if len(ps) != 1 {
+		t.Fatalf("expected one crossing, got %v", ps)
+	}
+	AssertPointEqual(t, Pointf{3, 4}, ps[0])
+	if ps := arc.IntersectSegment(Pointf{3, -10}, Pointf{3, 0}); len(ps) != 0 {
+		t.Errorf("segment short of the arc crossed it: %v", ps)
+	}
+	if ps := arc.IntersectLine(Pointf{-3, -10}, Pointf{-3, 10}); len(ps) != 0 {
+		t.Errorf("line missing the arc crossed it: %v", ps)
+	}
+
+	// Circles of radius 5 about the origin and (8, 0) cross at (4, +-3).
+	other, _ := ArcFromCenter(Pointf{8, 0}, 5, 2, 2)
+	ps = arc.IntersectArc(other)
+	if len(ps) != 1 {
+		t.Fatalf("expected one crossing, got %v", ps)
+	}
+	AssertPointEqual(t, Pointf{4, 3}, ps[0])
+	wide, _ := ArcFromCenter(Pointf{0, 0}, 5, -0.7, 2)
+	if ps := wide.IntersectArc(other); len(ps) != 2 {
+		t.Errorf("expected two crossings, got %v", ps)
+	}
+
+	line := NewArc(Pointf{0, 4}, Pointf{10, 4}, 0)
+	ps = arc.IntersectArc(line)
+	if len(ps) != 1 {
+		t.Fatalf("expected one crossing, got %v", ps)
+	}
+	AssertPointEqual(t, Pointf{3, 4}, ps[0])
+	AssertPointEqual(t, Pointf{3, 4}, line.IntersectArc(arc)[0])
+	ps = line.IntersectArc(NewArc(Pointf{5, 0}, Pointf{5, 10}, 0))
+	if len(ps) != 1 {
+		t.Fatalf("expected lines to cross, got %v", ps)
+	}
+	AssertPointEqual(t, Pointf{5, 4}, ps[0])
+}
@@ -131,3 +131,69 @@ func TestEuclideanDistanceTo(t *testing.T) {
 		AssertFloatEqual(t, test.expectedDistance, arc.EuclideanDistanceTo(test.p))
 	}
 }
+
+func TestArcFromCenter(t *testing.T) {
+	c := Pointf{10, 20}
+	for _, sweep := range []float32{1.2, -1.2, 3} {
+		a, err := ArcFromCenter(c, 5, 0.3, sweep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertPointEqual(t, Pointf{10 + 5*float32(math.Cos(0.3)), 20 + 5*float32(math.Sin(0.3))}, a.P0())
+		AssertPointEqual(t, c, a.Center())
+		AssertFloatEqual(t, 5, a.Radius())
+		start, s := a.Angles()
+		AssertFloatEqual(t, 0.3, start)
+		AssertFloatEqual(t, sweep, s)
+
+		// The apex is half way round.
+		apex := a.Apex()
+		AssertPointEqual(t, c.Add(polar(5, 0.3+sweep/2)), Pointf{apex.x, apex.y})
+	}
+
+	if _, err := ArcFromCenter(c, 5, 0, math.Pi); err == nil {
+		t.Error("made a half circle Arc")
+	}
+	if _, err := ArcFromCenter(c, 0, 0, 1); err == nil {
+		t.Error("made an Arc without a radius")
+	}
+}
+
+func TestArcThroughPoints(t *testing.T) {
+	c := Pointf{2, 3}
+	p := func(theta float32) Pointf { return c.Add(polar(4, theta)) }
+
+	a, err := ArcThroughPoints(p(0.1), p(0.6), p(1.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertPointEqual(t, c, a.Center())
+	AssertFloatEqual(t, 4, a.Radius())
+	start, sweep := a.Angles()
+	AssertFloatEqual(t, 0.1, start)
+	AssertFloatEqual(t, 1.4, sweep)
+
+	// Backwards.
+	a, err = ArcThroughPoints(p(1.5), p(0.6), p(0.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, sweep = a.Angles()
+	AssertFloatEqual(t, 1.5, start)
+	AssertFloatEqual(t, -1.4, sweep)
+
+	// Across the negative x axis.
+	a, err = ArcThroughPoints(p(3), p(-3), p(-2.8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sweep = a.Angles()
+	AssertFloatEqual(t, float32(2*math.Pi-5.8), sweep)
+
+	if _, err := ArcThroughPoints(Pointf{0, 0}, Pointf{1, 1}, Pointf{3, 3}); err == nil {
+		t.Error("made an Arc through collinear points")
+	}
+	if _, err := ArcThroughPoints(p(0), p(2), p(4)); err == nil {
+		t.Error("made an Arc of more than a half circle")
+	}
+}
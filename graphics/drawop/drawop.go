@@ -0,0 +1,73 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drawop names the Porter-Duff compositing operators. Names and
+// definitions are per Plan 9: an operator is the sum of the terms whose
+// bits it has, where S is the source and D the destination, both
+// premultiplied.
+//
+//	SinD:  S·αD
+//	SoutD: S·(1-αD)
+//	DinS:  D·αS
+//	DoutS: D·(1-αS)
+package drawop
+
+type Drawop int
+
+const (
+	Clear Drawop = 0
+	DoutS Drawop = 1
+	SoutD Drawop = 2
+	DinS  Drawop = 4
+	SinD  Drawop = 8
+
+	S      = SinD | SoutD
+	SoverD = SinD | SoutD | DoutS
+	SatopD = SinD | DoutS
+	SxorD  = SoutD | DoutS
+	D      = DinS | DoutS
+	DoverS = DinS | DoutS | SoutD
+	DatopS = DinS | SoutD
+	DxorS  = DoutS | SoutD // == SxorD
+	Ncomp  = 12
+)
+
+// Factors returns what op multiplies the source and destination by when
+// their alphas are sa and da.
+func (op Drawop) Factors(sa, da float32) (fs, fd float32) {
+	if op&SinD != 0 {
+		fs += da
+	}
+	if op&SoutD != 0 {
+		fs += 1 - da
+	}
+	if op&DinS != 0 {
+		fd += sa
+	}
+	if op&DoutS != 0 {
+		fd += 1 - sa
+	}
+	return fs, fd
+}
+
+// Composite returns the premultiplied result of op on the premultiplied
+// source s and destination d, as red, green, blue and alpha.
+func (op Drawop) Composite(s, d [4]float32) [4]float32 {
+	fs, fd := op.Factors(s[3], d[3])
+	var r [4]float32
+	for i := range r {
+		r[i] = s[i]*fs + d[i]*fd
+	}
+	return r
+}
@@ -0,0 +1,43 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drawop
+
+import "testing"
+
+func TestComposite(t *testing.T) {
+	s := [4]float32{0.5, 0, 0, 0.5}
+	d := [4]float32{0, 0.25, 0, 0.25}
+	for _, c := range []struct {
+		op       Drawop
+		expected [4]float32
+	}{
+		{Clear, [4]float32{}},
+		{S, s},
+		{D, d},
+		{SoverD, [4]float32{0.5, 0.125, 0, 0.625}},
+		{DoverS, [4]float32{0.375, 0.25, 0, 0.625}},
+		{SinD, [4]float32{0.125, 0, 0, 0.125}},
+		{DinS, [4]float32{0, 0.125, 0, 0.125}},
+		{SoutD, [4]float32{0.375, 0, 0, 0.375}},
+		{DoutS, [4]float32{0, 0.125, 0, 0.125}},
+		{SatopD, [4]float32{0.125, 0.125, 0, 0.25}},
+		{DatopS, [4]float32{0.375, 0.125, 0, 0.5}},
+		{SxorD, [4]float32{0.375, 0.125, 0, 0.5}},
+	} {
+		if r := c.op.Composite(s, d); r != c.expected {
+			t.Errorf("op %d: expected %v, got %v", c.op, c.expected, r)
+		}
+	}
+}
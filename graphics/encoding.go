@@ -0,0 +1,148 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+// The first byte of an encoded DisplayList. Bump it when the encoding
+// changes.
+const DISPLAY_LIST_ENCODING = 7
+
+// MarshalBinary encodes the ops of the DisplayList and the pixels of the
+// images they draw: what Draw and Rasterize use but not the recording
+// state or the caches of layers.
+func (dl *DisplayList) MarshalBinary() ([]byte, error) {
+	dl.checkLayers("MarshalBinary")
+	var b bytes.Buffer
+	b.WriteByte(DISPLAY_LIST_ENCODING)
+	lengths := [5]uint32{
+		uint32(len(dl.opCodes)), uint32(len(dl.integers)),
+		uint32(len(dl.floats)), uint32(len(dl.bytes)), uint32(len(dl.images))}
+	vs := []interface{}{lengths, dl.opCodes, dl.integers, dl.floats, dl.bytes, [2]float32{dl.W, dl.H}}
+	// Each image is its bounds and then its rows of pixels.
+	for _, im := range dl.images {
+		r := im.pix.Rect
+		vs = append(vs, [4]int32{int32(r.Min.X), int32(r.Min.Y), int32(r.Max.X), int32(r.Max.Y)})
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := im.pix.PixOffset(r.Min.X, y)
+			vs = append(vs, im.pix.Pix[i:i+4*r.Dx()])
+		}
+	}
+	for _, v := range vs {
+		if err := binary.Write(&b, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary replaces dl with the DisplayList encoded in data by
+// MarshalBinary.
+func (dl *DisplayList) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != DISPLAY_LIST_ENCODING {
+		return errors.New("graphics: unknown DisplayList encoding")
+	}
+	r := bytes.NewReader(data[1:])
+	var lengths [5]uint32
+	if err := binary.Read(r, binary.LittleEndian, &lengths); err != nil {
+		return errors.New("graphics: DisplayList encoding truncated")
+	}
+	// The images' sizes are checked as they are read.
+	size := int64(lengths[0]) + 4*int64(lengths[1]) + 4*int64(lengths[2]) + int64(lengths[3]) + 8
+	if size > int64(r.Len()) {
+		return errors.New("graphics: DisplayList encoding has the wrong length")
+	}
+
+	*dl = DisplayList{
+		opCodes:  make([]uint8, lengths[0]),
+		integers: make([]uint32, lengths[1]),
+		floats:   make([]float32, lengths[2]),
+		bytes:    make([]uint8, lengths[3]),
+	}
+	var wh [2]float32
+	for _, v := range []interface{}{dl.opCodes, dl.integers, dl.floats, dl.bytes, &wh} {
+		// The length was checked so this can't fail.
+		binary.Read(r, binary.LittleEndian, v)
+	}
+	dl.W, dl.H = wh[0], wh[1]
+
+	for i := uint32(0); i < lengths[4]; i++ {
+		var b [4]int32
+		if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
+			return errors.New("graphics: DisplayList encoding truncated")
+		}
+		rect := image.Rect(int(b[0]), int(b[1]), int(b[2]), int(b[3]))
+		if rect.Empty() || int64(rect.Dx())*int64(rect.Dy())*4 > int64(r.Len()) {
+			return errors.New("graphics: DisplayList encoding has the wrong length")
+		}
+		pix := image.NewRGBA(rect)
+		// The length was checked so this can't fail.
+		binary.Read(r, binary.LittleEndian, pix.Pix)
+		dl.images = append(dl.images, &Image{pix: pix})
+		dl.copies = append(dl.copies, dl.images[len(dl.images)-1])
+	}
+	if r.Len() != 0 {
+		return errors.New("graphics: DisplayList encoding has the wrong length")
+	}
+	return nil
+}
+
+// Equal reports whether dl and o have the same ops and so draw the same
+// pixels.
+func (dl *DisplayList) Equal(o *DisplayList) bool {
+	if len(dl.opCodes) != len(o.opCodes) || len(dl.integers) != len(o.integers) ||
+		len(dl.floats) != len(o.floats) || len(dl.bytes) != len(o.bytes) ||
+		len(dl.images) != len(o.images) {
+		return false
+	}
+	for i := range dl.opCodes {
+		if dl.opCodes[i] != o.opCodes[i] {
+			return false
+		}
+	}
+	for i := range dl.integers {
+		if dl.integers[i] != o.integers[i] {
+			return false
+		}
+	}
+	for i := range dl.floats {
+		if dl.floats[i] != o.floats[i] {
+			return false
+		}
+	}
+	for i := range dl.bytes {
+		if dl.bytes[i] != o.bytes[i] {
+			return false
+		}
+	}
+	for i, im := range dl.images {
+		a, b := im.pix, o.images[i].pix
+		if a.Rect != b.Rect {
+			return false
+		}
+		for y := a.Rect.Min.Y; y < a.Rect.Max.Y; y++ {
+			ia, ib := a.PixOffset(a.Rect.Min.X, y), b.PixOffset(b.Rect.Min.X, y)
+			if !bytes.Equal(a.Pix[ia:ia+4*a.Rect.Dx()], b.Pix[ib:ib+4*b.Rect.Dx()]) {
+				return false
+			}
+		}
+	}
+	return true
+}
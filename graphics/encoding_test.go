@@ -0,0 +1,51 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestDisplayListEncoding(t *testing.T) {
+	dl := &DisplayList{}
+	dl.ClipRect(Rect(1, 2, 30, 40))
+	dl.SetColor(color.RGBA{1, 2, 3, 4})
+	dl.DrawQuads([][4]Pointf{{{2, 2}, {6, 2}, {6, 6}, {2, 6}}})
+
+	data, err := dl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &DisplayList{}
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	AssertTrue(t, got.Equal(dl))
+	AssertFloatEqual(t, dl.W, got.W)
+	AssertFloatEqual(t, dl.H, got.H)
+
+	// Another op makes it different.
+	dl.DrawPoints([]Pointf{{1, 1}})
+	AssertFalse(t, got.Equal(dl))
+
+	AssertTrue(t, got.UnmarshalBinary(data[:len(data)-1]) != nil)
+	AssertTrue(t, got.UnmarshalBinary(nil) != nil)
+
+	empty := &DisplayList{}
+	data, _ = empty.MarshalBinary()
+	AssertTrue(t, got.UnmarshalBinary(data) == nil)
+	AssertTrue(t, got.Equal(empty))
+}
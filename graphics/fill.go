@@ -0,0 +1,142 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"github.com/go-gl/gl"
+)
+
+// Fill rules: which of the regions that the contours of a polygon or Path
+// divide the plane into are inside it.
+const (
+	// Inside where the contours wind round a point other than 0 times.
+	FILL_NONZERO = iota
+	// Inside where a line from a point to infinity crosses an odd number of
+	// contours.
+	FILL_EVEN_ODD
+)
+
+// FLATTEN_TOLERANCE is how far in viewport pixels FillPath may stray from
+// the Arcs of a Path.
+const FLATTEN_TOLERANCE = 0.25
+
+// FillPolygon fills the polygon with vertices ps, which may be concave or
+// cross itself, with fill rule.
+func (dl *DisplayList) FillPolygon(ps []Pointf, rule int) {
+	dl.fillContours([][]Pointf{ps}, rule)
+}
+
+// FillPath fills p with fill rule. Each subpath is closed by a straight
+// line.
+func (dl *DisplayList) FillPath(p *Path, rule int) {
+	dl.fillContours(p.Flatten(FLATTEN_TOLERANCE*dl.PixelSize()), rule)
+}
+
+// A DRAW_OP_PATH has the fill rule, whether to anti-alias, the number of
+// contours and the number of points in each in the integers and the
+// points, in viewport coordinates, in the floats.
+func (dl *DisplayList) fillContours(cs [][]Pointf, rule int) {
+	m := dl.current().transform
+	var counts []uint32
+	var floats []float32
+	for _, c := range cs {
+		if len(c) < 3 {
+			continue
+		}
+		counts = append(counts, uint32(len(c)))
+		for _, p := range c {
+			p = m.Transform(p)
+			dl.W = MaxF(dl.W, p.X)
+			dl.H = MaxF(dl.H, p.Y)
+			floats = append(floats, p.X, p.Y)
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+	dl.opCodes = append(dl.opCodes, DRAW_OP_PATH)
+	dl.integers = append(dl.integers, uint32(rule), dl.antialiasFlag(), uint32(len(counts)))
+	dl.integers = append(dl.integers, counts...)
+	dl.floats = append(dl.floats, floats...)
+}
+
+// pathContours reads the contours of a DRAW_OP_PATH whose integers start
+// at integers and whose floats start at floats.
+func (dl *DisplayList) pathContours(integer, float int) (rule uint32, antialias bool, contours [][]Pointf, integers, floats int) {
+	rule = dl.integers[integer]
+	antialias = dl.integers[integer+1] != 0
+	n := int(dl.integers[integer+2])
+	for _, c := range dl.integers[integer+3 : integer+3+n] {
+		ps := make([]Pointf, c)
+		for i := range ps {
+			ps[i] = Pointf{dl.floats[float], dl.floats[float+1]}
+			float += 2
+		}
+		contours = append(contours, ps)
+	}
+	return rule, antialias, contours, integer + 3 + n, float
+}
+
+// DoPath fills by stencil and cover: the triangles fanning out from the
+// first point of each contour count the windings of each pixel in the
+// stencil buffer and a quad over the contours then draws the pixels that
+// the fill rule puts inside, zeroing the stencil buffer behind it. When
+// anti-aliasing, a fringe fading out over half a pixel is drawn outside
+// the edges before the cover.
+func (dl *DisplayList) DoPath(program *gl.Program) {
+	var rule uint32
+	var antialias bool
+	var contours [][]Pointf
+	rule, antialias, contours, dl.cur_integer, dl.cur_float = dl.pathContours(dl.cur_integer, dl.cur_float)
+
+	var fan []float32
+	b := Rectanglef{contours[0][0], contours[0][0]}
+	for _, c := range contours {
+		for i := 1; i+1 < len(c); i++ {
+			fan = append(fan, c[0].X, c[0].Y, c[i].X, c[i].Y, c[i+1].X, c[i+1].Y)
+		}
+		for _, p := range c {
+			b = b.Union(Rectanglef{p, p})
+		}
+	}
+
+	gl.Enable(gl.STENCIL_TEST)
+	defer gl.Disable(gl.STENCIL_TEST)
+	gl.ColorMask(false, false, false, false)
+	gl.StencilFunc(gl.ALWAYS, 0, 0xff)
+	mask := uint(0xff)
+	if rule == FILL_EVEN_ODD {
+		mask = 1
+		gl.StencilOp(gl.KEEP, gl.KEEP, gl.INVERT)
+	} else {
+		gl.StencilOpSeparate(gl.FRONT, gl.KEEP, gl.KEEP, gl.INCR_WRAP)
+		gl.StencilOpSeparate(gl.BACK, gl.KEEP, gl.KEEP, gl.DECR_WRAP)
+	}
+	gl.StencilMask(mask)
+	drawTriangles(program, fan)
+
+	gl.ColorMask(true, true, true, true)
+	if antialias {
+		gl.StencilFunc(gl.EQUAL, 0, mask)
+		gl.StencilOp(gl.KEEP, gl.KEEP, gl.KEEP)
+		dl.drawCoverage(program, fringe(contours))
+	}
+	gl.StencilFunc(gl.NOTEQUAL, 0, mask)
+	gl.StencilOp(gl.ZERO, gl.ZERO, gl.ZERO)
+	drawTriangles(program, []float32{
+		b.Min.X, b.Min.Y, b.Max.X, b.Min.Y, b.Max.X, b.Max.Y,
+		b.Min.X, b.Min.Y, b.Max.X, b.Max.Y, b.Min.X, b.Max.Y})
+	gl.StencilMask(0xff)
+}
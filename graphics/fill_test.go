@@ -0,0 +1,110 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestFillConcavePolygon(t *testing.T) {
+	dl := &DisplayList{}
+	// Translucent, so pixels drawn twice would show.
+	dl.SetColor(color.RGBA{0, 0, 0xff, 0x80})
+	half := color.RGBA{0, 0, 0x80, 0x80}
+	dl.FillPolygon([]Pointf{{2, 2}, {10, 2}, {10, 6}, {6, 6}, {6, 14}, {2, 14}}, FILL_NONZERO)
+	img := rasterize(dl)
+	AssertRGBA(t, half, img, 8, 4)
+	AssertRGBA(t, half, img, 4, 12)
+	AssertRGBA(t, half, img, 2, 2)
+	AssertRGBA(t, color.RGBA{}, img, 8, 8)
+	AssertRGBA(t, color.RGBA{}, img, 10, 4)
+	AssertRGBA(t, color.RGBA{}, img, 4, 14)
+
+	// A quad that crosses itself is two triangles.
+	dl = &DisplayList{}
+	dl.SetColor(red)
+	dl.FillPolygon([]Pointf{{2, 2}, {18, 2}, {2, 18}, {18, 18}}, FILL_EVEN_ODD)
+	img = rasterize(dl)
+	AssertRGBA(t, red, img, 10, 4)
+	AssertRGBA(t, red, img, 10, 16)
+	AssertRGBA(t, color.RGBA{}, img, 4, 10)
+	AssertRGBA(t, color.RGBA{}, img, 16, 10)
+}
+
+func TestFillMatchesQuads(t *testing.T) {
+	q := [4]Pointf{{1.5, 2.25}, {17, 1}, {15.5, 18.5}, {3, 12}}
+	quads := &DisplayList{}
+	quads.SetColor(red)
+	quads.DrawQuads([][4]Pointf{q})
+	poly := &DisplayList{}
+	poly.SetColor(red)
+	poly.FillPolygon(q[:], FILL_NONZERO)
+
+	a, b := rasterize(quads), rasterize(poly)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			AssertRGBA(t, a.RGBAAt(x, y), b, x, y)
+		}
+	}
+}
+
+func TestFillRules(t *testing.T) {
+	// Two squares wound the same way.
+	p := new(Path)
+	for _, r := range []Rectanglef{Rect(0, 0, 20, 20), Rect(5, 5, 15, 15)} {
+		p.MoveTo(r.Min)
+		p.LineTo(Pointf{r.Max.X, r.Min.Y})
+		p.LineTo(r.Max)
+		p.LineTo(Pointf{r.Min.X, r.Max.Y})
+		p.Close()
+	}
+	for _, c := range []struct {
+		rule   int
+		centre color.RGBA
+	}{{FILL_NONZERO, red}, {FILL_EVEN_ODD, color.RGBA{}}} {
+		dl := &DisplayList{}
+		dl.SetColor(red)
+		dl.FillPath(p, c.rule)
+		img := rasterize(dl)
+		AssertRGBA(t, red, img, 2, 2)
+		AssertRGBA(t, c.centre, img, 10, 10)
+	}
+}
+
+func TestFillPathArcs(t *testing.T) {
+	c := Pointf{10, 10}
+	p := new(Path)
+	p.CircularArc(c, 6, 0, 2*math.Pi)
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.FillPath(p, FILL_NONZERO)
+	img := rasterize(dl)
+	AssertRGBA(t, red, img, 10, 10)
+	AssertRGBA(t, red, img, 14, 10)
+	AssertPartial(t, img, 15, 10)
+	AssertRGBA(t, color.RGBA{}, img, 16, 16)
+
+	// The lines are within the tolerance of the circle.
+	for _, poly := range p.Flatten(0.1) {
+		for i, q := range poly {
+			mid := q.Add(poly[(i+1)%len(poly)]).Mul(0.5)
+			if d := 6 - length(mid.Sub(c)); d < -FLOAT_TOL || d > 0.1+FLOAT_TOL {
+				t.Errorf("chord %d is %f from the arc", i, d)
+			}
+		}
+	}
+}
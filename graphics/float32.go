@@ -28,6 +28,13 @@ func MinF(x1, x2 float32) float32 {
 	return x2
 }
 
+func AbsF(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 // Returns sin(2 * atan(d))
 func Sin2Atan(d float32) float32 {
 	return 2.0 * d / (1.0 + d*d)
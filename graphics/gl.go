@@ -25,8 +25,16 @@ import (
 
 const (
 	// Sort these alphabetically or vollick will hunt you down.
-	DRAW_OP_COLOR = iota
+	DRAW_OP_BEGIN_LAYER = iota
+	DRAW_OP_CLIP
+	DRAW_OP_COLOR
+	DRAW_OP_END_LAYER
+	DRAW_OP_IMAGE
+	DRAW_OP_MASKED
+	DRAW_OP_PAINT
+	DRAW_OP_PATH
 	DRAW_OP_QUADS
+	DRAW_OP_SHAPE
 )
 
 func CheckForGLErrors() {
@@ -62,29 +70,46 @@ void main()
                        0.0, 1.0);
 }` // defaultVertexShader
 
-	// The default fragment shader simply passes along a uniform color.
+	// The default fragment shader simply passes along the paint. It is
+	// formatted with the paint shader.
 	defaultFragmentShader = `
 #version 400
 
-uniform vec4 u_Color;
+uniform float u_Height;
 out vec4 out_Color;
-
+%s
 void main()
 {
-    out_Color = u_Color;
+    // GL puts the origin at the bottom left.
+    out_Color = paint(vec2(gl_FragCoord.x, u_Height - gl_FragCoord.y));
 }` // defaultFragmentShader
 
 )
 
+// DefaultBlend sets the blending Draw expects: colors, which aren't
+// premultiplied, are drawn source-over and the destination's alpha is kept
+// right for the drawops that use it.
+func DefaultBlend() {
+	gl.BlendFuncSeparate(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA, gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+}
+
 func CreateDefaultShaders() (program gl.Program) {
+	program = createProgram(defaultVertexShader, fmt.Sprintf(defaultFragmentShader, paintShader()))
+	program.Use()
+	return
+}
+
+// createProgram compiles and links a program from the source of its vertex
+// and fragment shaders.
+func createProgram(vertex, fragment string) (program gl.Program) {
 	vertex_shader := gl.CreateShader(gl.VERTEX_SHADER)
-	vertex_shader.Source(defaultVertexShader)
+	vertex_shader.Source(vertex)
 	vertex_shader.Compile()
 	fmt.Println(vertex_shader.GetInfoLog())
 	defer vertex_shader.Delete()
 
 	fragment_shader := gl.CreateShader(gl.FRAGMENT_SHADER)
-	fragment_shader.Source(defaultFragmentShader)
+	fragment_shader.Source(fragment)
 	fragment_shader.Compile()
 	fmt.Println(fragment_shader.GetInfoLog())
 	defer fragment_shader.Delete()
@@ -95,7 +120,6 @@ func CreateDefaultShaders() (program gl.Program) {
 
 	program.BindFragDataLocation(0, "out_Color")
 	program.Link()
-	program.Use()
 	return
 }
 
@@ -110,12 +134,153 @@ type DisplayList struct {
 	integers                         []uint32
 	floats                           []float32
 	bytes                            []uint8
+	images                           []*Image
+	cur_op                           int
 	cur_integer, cur_float, cur_byte int
 	W, H                             float32
 	cur_point_size                   float32
+	cur_width, cur_height            float32
+	cur_color                        [4]float32
+	cur_paint                        *paint
+	cur_clip                         Rectanglef
+	cur_clipped                      bool
+	cur_layers                       []glLayer
+
+	// Recording state. Transforms and opacity are applied as the list is
+	// built so that the ops themselves are always in viewport coordinates.
+	state       dlState
+	stack       []dlState
+	initialized bool
+	color       color.RGBA
+	hasColor    bool
+	// The paint set by SetPaint and the transform from its coordinates to
+	// the viewport.
+	paint          Gradient
+	paintTransform Matrix
+	hasPaint       bool
+	// The images copied by DrawImage and DrawMasked or decoded, whose
+	// textures Draw frees when done.
+	copies []*Image
+	// The layers begun and not yet ended and the caches of all layers.
+	layers []layerStart
+	caches []*LayerCache
+}
+
+// dlState is the recording state saved and restored by Save and Restore.
+type dlState struct {
+	transform Matrix
+	opacity   float32
+	// The clip rectangle in viewport coordinates.
+	clip    Rectanglef
+	clipped bool
+	// Whether to anti-alias quads and fills.
+	antialias bool
+}
+
+func (dl *DisplayList) current() *dlState {
+	if !dl.initialized {
+		dl.state = dlState{transform: Identity(), opacity: 1, antialias: true}
+		dl.initialized = true
+	}
+	return &dl.state
+}
+
+// Save pushes the current transform, opacity, clip and anti-aliasing.
+func (dl *DisplayList) Save() {
+	dl.stack = append(dl.stack, *dl.current())
+}
+
+// Restore pops the transform, opacity, clip and anti-aliasing pushed by the
+// matching Save.
+func (dl *DisplayList) Restore() {
+	n := len(dl.stack)
+	if n == 0 {
+		log.Panic("DisplayList.Restore without a matching Save")
+	}
+	old := *dl.current()
+	dl.state = dl.stack[n-1]
+	dl.stack = dl.stack[:n-1]
+	if old.opacity != dl.state.opacity {
+		dl.emitColor()
+	}
+	if old.clipped != dl.state.clipped || !old.clip.Eq(dl.state.clip) {
+		dl.emitClip()
+	}
+}
+
+// Transform concatenates m onto the current transform: subsequent drawing
+// is transformed by m and then by the previous current transform.
+func (dl *DisplayList) Transform(m Matrix) {
+	s := dl.current()
+	s.transform = s.transform.Mul(m)
+}
+
+// CurrentTransform returns the transform from the current drawing
+// coordinates to the viewport.
+func (dl *DisplayList) CurrentTransform() Matrix {
+	return dl.current().transform
+}
+
+// PixelSize returns the approximate size of a viewport pixel in the current
+// drawing coordinates. Use it to draw things that should stay the same size
+// on screen whatever the transform.
+func (dl *DisplayList) PixelSize() float32 {
+	return PixelSize(dl.current().transform)
+}
+
+// SetOpacity multiplies the alpha of each subsequent primitive by a, so
+// overlapping primitives show through each other. BeginLayer fades a group
+// of primitives as one.
+func (dl *DisplayList) SetOpacity(a float32) {
+	dl.current().opacity *= a
+	dl.emitColor()
+}
+
+// ClipRect intersects the clip with r, given in the current drawing
+// coordinates. Clips are axis aligned in the viewport so a rotated r clips
+// to its bounding box.
+func (dl *DisplayList) ClipRect(r Rectanglef) {
+	s := dl.current()
+	c := s.transform.TransformRect(r)
+	if s.clipped {
+		c = c.Intersect(s.clip)
+	}
+	s.clip = c
+	s.clipped = true
+	dl.emitClip()
+}
+
+func (dl *DisplayList) emitClip() {
+	s := dl.current()
+	dl.opCodes = append(dl.opCodes, DRAW_OP_CLIP)
+	if s.clipped {
+		dl.integers = append(dl.integers, 1)
+	} else {
+		dl.integers = append(dl.integers, 0)
+	}
+	dl.floats = append(dl.floats, s.clip.Min.X, s.clip.Min.Y, s.clip.Max.X, s.clip.Max.Y)
 }
 
 func (dl *DisplayList) SetColor(c color.RGBA) {
+	dl.color = c
+	dl.hasColor = true
+	dl.hasPaint = false
+	dl.emitColor()
+}
+
+// emitColor records the current color or paint with the current opacity.
+func (dl *DisplayList) emitColor() {
+	if dl.hasPaint {
+		dl.emitPaint()
+		return
+	}
+	if !dl.hasColor {
+		return
+	}
+	c := dl.color
+	if o := dl.current().opacity; o != 1 {
+		c.A = uint8(float32(c.A)*o + 0.5)
+	}
 	dl.opCodes = append(dl.opCodes, DRAW_OP_COLOR)
 	dl.bytes = append(dl.bytes, c.R, c.G, c.B, c.A)
 }
@@ -139,11 +304,18 @@ func (dl *DisplayList) DrawPoints(ps []Pointf) {
 	dl.DrawQuads(quads)
 }
 
+// DrawQuads fills each of qs, which must be convex. Use FillPolygon for
+// quads that might not be.
 func (dl *DisplayList) DrawQuads(qs [][4]Pointf) {
+	m := dl.current().transform
+	identity := m.IsIdentity()
 	dl.opCodes = append(dl.opCodes, DRAW_OP_QUADS)
-	dl.integers = append(dl.integers, uint32(len(qs)))
+	dl.integers = append(dl.integers, uint32(len(qs)), dl.antialiasFlag())
 	for _, q := range qs {
 		for _, p := range q {
+			if !identity {
+				p = m.Transform(p)
+			}
 			dl.W = MaxF(dl.W, p.X)
 			dl.H = MaxF(dl.H, p.Y)
 			dl.floats = append(dl.floats, p.X, p.Y)
@@ -152,6 +324,7 @@ func (dl *DisplayList) DrawQuads(qs [][4]Pointf) {
 }
 
 func (dl *DisplayList) Draw(program *gl.Program, width, height float32) {
+	dl.checkLayers("Draw")
 	viewportUniform := program.GetUniformLocation("u_Viewport")
 	viewportUniform.Uniform2f(1.0/width, 1.0/height)
 
@@ -159,43 +332,96 @@ func (dl *DisplayList) Draw(program *gl.Program, width, height float32) {
 	dl.cur_integer = 0
 	dl.cur_float = 0
 	dl.cur_byte = 0
-	for _, op := range dl.opCodes {
-		switch op {
+	dl.cur_width = width
+	dl.cur_height = height
+	dl.cur_clipped = false
+	dl.cur_layers = nil
+	defer gl.Disable(gl.SCISSOR_TEST)
+	defer dl.releaseCopies()
+	// Cached layers skip their ops.
+	for dl.cur_op = 0; dl.cur_op < len(dl.opCodes); dl.cur_op++ {
+		switch dl.opCodes[dl.cur_op] {
+		case DRAW_OP_BEGIN_LAYER:
+			dl.DoBeginLayer(program)
+		case DRAW_OP_CLIP:
+			dl.DoClip(program)
 		case DRAW_OP_COLOR:
 			dl.DoColor(program)
+		case DRAW_OP_END_LAYER:
+			dl.DoEndLayer(program)
+		case DRAW_OP_IMAGE:
+			dl.DoImage(program)
+		case DRAW_OP_MASKED:
+			dl.DoMasked(program)
+		case DRAW_OP_PAINT:
+			dl.DoPaint(program)
+		case DRAW_OP_PATH:
+			dl.DoPath(program)
 		case DRAW_OP_QUADS:
 			dl.DoQuads(program)
+		case DRAW_OP_SHAPE:
+			dl.DoShape(program)
 		}
 		CheckForGLErrors()
 	}
 }
 
+func (dl *DisplayList) DoClip(program *gl.Program) {
+	clipped := dl.integers[dl.cur_integer] != 0
+	dl.cur_integer++
+	r := Rect(dl.floats[dl.cur_float], dl.floats[dl.cur_float+1],
+		dl.floats[dl.cur_float+2], dl.floats[dl.cur_float+3])
+	dl.cur_float += 4
+
+	dl.cur_clip, dl.cur_clipped = r, clipped
+	dl.scissor()
+}
+
 func (dl *DisplayList) DoColor(program *gl.Program) {
-	colorLocation := program.GetUniformLocation("u_Color")
-	colorLocation.Uniform4f(
-		float32(dl.bytes[dl.cur_byte])/255,
-		float32(dl.bytes[dl.cur_byte+1])/255,
-		float32(dl.bytes[dl.cur_byte+2])/255,
-		float32(dl.bytes[dl.cur_byte+3])/255)
+	for i := range dl.cur_color {
+		dl.cur_color[i] = float32(dl.bytes[dl.cur_byte+i]) / 255
+	}
 	dl.cur_byte += 4
+	dl.cur_paint = nil
+	dl.setPaintUniforms(*program)
 	CheckForGLErrors()
 }
 
 func (dl *DisplayList) DoQuads(program *gl.Program) {
-	num_quads := dl.integers[dl.cur_integer]
-	dl.cur_integer++
+	num_quads := int(dl.integers[dl.cur_integer])
+	antialias := dl.integers[dl.cur_integer+1] != 0
+	dl.cur_integer += 2
+	dl.drawQuads(program, dl.floats[dl.cur_float:dl.cur_float+8*num_quads], antialias)
+	dl.cur_float += 8 * num_quads
+}
+
+// drawQuads fills the quads whose corners, in viewport coordinates, are
+// each 8 of f.
+func (dl *DisplayList) drawQuads(program *gl.Program, f []float32, antialias bool) {
+	if antialias {
+		var vertices []float32
+		for i := 0; i < len(f); i += 8 {
+			vertices = feather(vertices, []Pointf{{f[i], f[i+1]}, {f[i+2], f[i+3]}, {f[i+4], f[i+5]}, {f[i+6], f[i+7]}})
+		}
+		dl.drawCoverage(program, vertices)
+		return
+	}
 	quads := []float32{}
 
 	// FIXME: we shouldn't recreate this every time the display list is
 	// drawn.
-	for i := uint32(0); i < num_quads; i++ {
-		quads = append(quads, dl.floats[dl.cur_float:dl.cur_float+6]...)
-		quads = append(quads, dl.floats[dl.cur_float:dl.cur_float+2]...)
-		quads = append(quads, dl.floats[dl.cur_float+4:dl.cur_float+6]...)
-		quads = append(quads, dl.floats[dl.cur_float+6:dl.cur_float+8]...)
-		dl.cur_float += 8
+	for i := 0; i < len(f); i += 8 {
+		quads = append(quads, f[i:i+6]...)
+		quads = append(quads, f[i:i+2]...)
+		quads = append(quads, f[i+4:i+6]...)
+		quads = append(quads, f[i+6:i+8]...)
 	}
+	drawTriangles(program, quads)
+}
 
+// drawTriangles draws the triangles with vertices, in viewport coordinates,
+// with program.
+func drawTriangles(program *gl.Program, vertices []float32) {
 	// FIXME: this is atrocious. We need to retain these objects rather than
 	// creating and destroying them constantly.
 	vao := gl.GenVertexArray()
@@ -204,14 +430,14 @@ func (dl *DisplayList) DoQuads(program *gl.Program) {
 	vbo := gl.GenBuffer()
 	vbo.Bind(gl.ARRAY_BUFFER)
 
-	gl.BufferData(gl.ARRAY_BUFFER, len(quads)*4, quads, gl.STATIC_DRAW)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, vertices, gl.STATIC_DRAW)
 
 	positionAttrib := program.GetAttribLocation("in_Position")
 	positionAttrib.AttribPointer(2, gl.FLOAT, false, 0, nil)
 	positionAttrib.EnableArray()
 	defer positionAttrib.DisableArray()
 
-	gl.DrawArrays(gl.TRIANGLES, 0, len(quads)/2)
+	gl.DrawArrays(gl.TRIANGLES, 0, len(vertices)/2)
 
 	CheckForGLErrors()
 }
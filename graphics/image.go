@@ -0,0 +1,315 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/go-gl/gl"
+)
+
+// Filters say how DrawImage samples the source between its pixels.
+const (
+	// The pixel the sample falls in.
+	FILTER_NEAREST = iota
+	// Interpolated between the four nearest pixels.
+	FILTER_BILINEAR
+	// Interpolated between the two mipmap levels nearest the scale of the
+	// draw, and bilinearly within each.
+	FILTER_MIPMAP
+)
+
+// An Image holds premultiplied pixels for DrawImage, uploaded to GL when
+// first drawn. It is an image.Image.
+type Image struct {
+	// Repl makes the image tile the plane. Otherwise it is transparent
+	// outside its bounds.
+	Repl bool
+
+	pix *image.RGBA
+	// The mipmap levels after the first, made when first needed.
+	mips []*image.RGBA
+
+	texture  gl.Texture
+	uploaded bool
+}
+
+// NewImage copies src into an Image with the same bounds. Upload images
+// that are drawn repeatedly once with NewImage rather than passing them to
+// DrawImage each time.
+func NewImage(src image.Image) *Image {
+	pix := image.NewRGBA(src.Bounds())
+	draw.Draw(pix, pix.Rect, src, pix.Rect.Min, draw.Src)
+	return &Image{pix: pix}
+}
+
+// SubImage returns the part of im inside r, sharing its pixels. Drawn
+// with Repl, the sub-image tiles the plane by itself.
+func (im *Image) SubImage(r image.Rectangle) *Image {
+	return &Image{Repl: im.Repl, pix: im.pix.SubImage(r).(*image.RGBA)}
+}
+
+func (im *Image) Bounds() image.Rectangle {
+	return im.pix.Rect
+}
+
+func (im *Image) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (im *Image) At(x, y int) color.Color {
+	return im.pix.At(x, y)
+}
+
+// Release frees the GL texture of im. It is uploaded again if drawn.
+func (im *Image) Release() {
+	if im.uploaded {
+		deleteTexture(im.texture)
+		im.uploaded = false
+	}
+}
+
+// deleteTexture frees a texture. Tests replace it to run without GL.
+var deleteTexture = func(t gl.Texture) {
+	t.Delete()
+}
+
+// DrawImage draws src inside r, given in the current drawing coordinates,
+// following docs/inkings.md: m maps the drawing coordinates to src's, and
+// the src pixel there is drawn, sampled with filter. An *Image is drawn as
+// it is and anything else is copied by NewImage and its texture freed at
+// the end of each Draw.
+func (dl *DisplayList) DrawImage(r Rectanglef, src image.Image, m Matrix, filter int) {
+	im := dl.toImage(src)
+	if im.pix.Rect.Empty() {
+		return
+	}
+	s := dl.current()
+	toSource, ok := s.transform.Invert()
+	if !ok {
+		return
+	}
+	toSource = m.Mul(toSource)
+
+	dl.opCodes = append(dl.opCodes, DRAW_OP_IMAGE)
+	dl.integers = append(dl.integers, uint32(dl.imageIndex(im)), uint32(filter), flag(im.Repl), dl.antialiasFlag())
+	for _, p := range []Pointf{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+		p = s.transform.Transform(p)
+		dl.floats = append(dl.floats, p.X, p.Y)
+	}
+	t := toSource
+	dl.floats = append(dl.floats, t.A, t.B, t.C, t.D, t.E, t.F, s.opacity)
+}
+
+// A DRAW_OP_IMAGE has the index of the image in the DisplayList, the
+// filter, whether to tile and whether to anti-alias in the integers and
+// the quad to fill, the transform from the viewport to the image and the
+// opacity in the floats.
+const IMAGE_FLOATS = 8 + 6 + 1
+
+// imageAt decodes the DRAW_OP_IMAGE whose data starts at the given
+// indices. It returns the quad to fill with the image as a paint and
+// whether to anti-alias it.
+func (dl *DisplayList) imageAt(integer, float int) (p *paint, quad []float32, antialias bool, integers, floats int) {
+	f := dl.floats[float : float+IMAGE_FLOATS]
+	p = &paint{
+		kind:       paintImage,
+		image:      dl.images[dl.integers[integer]],
+		filter:     int(dl.integers[integer+1]),
+		repl:       dl.integers[integer+2] != 0,
+		toGradient: Matrix{f[8], f[9], f[10], f[11], f[12], f[13]},
+		opacity:    f[14],
+	}
+	return p, f[:8], dl.integers[integer+3] != 0, integer + 4, float + IMAGE_FLOATS
+}
+
+// DoImage fills the op's quad with the image as the paint and then
+// restores the current paint.
+func (dl *DisplayList) DoImage(program *gl.Program) {
+	saved := dl.cur_paint
+	var quad []float32
+	var antialias bool
+	dl.cur_paint, quad, antialias, dl.cur_integer, dl.cur_float = dl.imageAt(dl.cur_integer, dl.cur_float)
+	dl.cur_paint.image.bind(gl.TEXTURE0, dl.cur_paint.filter, dl.cur_paint.repl)
+	dl.setPaintUniforms(*program)
+	dl.drawQuads(program, quad, antialias)
+	dl.cur_paint = saved
+	dl.setPaintUniforms(*program)
+}
+
+// bind binds im's texture to unit, uploading it if need be, set up to
+// sample with filter.
+func (im *Image) bind(unit gl.GLenum, filter int, repl bool) {
+	gl.ActiveTexture(unit)
+	if !im.uploaded {
+		im.texture = gl.GenTexture()
+		im.texture.Bind(gl.TEXTURE_2D)
+		r := im.pix.Rect
+		// GL wants the rows packed.
+		pix := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(pix, pix.Rect, im.pix, r.Min, draw.Src)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, int(gl.RGBA), r.Dx(), r.Dy(), 0, gl.RGBA, gl.UNSIGNED_BYTE, pix.Pix)
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+		im.uploaded = true
+	}
+	im.texture.Bind(gl.TEXTURE_2D)
+
+	minFilter, magFilter := gl.NEAREST, gl.NEAREST
+	switch filter {
+	case FILTER_BILINEAR:
+		minFilter, magFilter = gl.LINEAR, gl.LINEAR
+	case FILTER_MIPMAP:
+		minFilter, magFilter = gl.LINEAR_MIPMAP_LINEAR, gl.LINEAR
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, int(minFilter))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, int(magFilter))
+	wrap := gl.CLAMP_TO_EDGE
+	if repl {
+		wrap = gl.REPEAT
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, int(wrap))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, int(wrap))
+	CheckForGLErrors()
+}
+
+// sample returns the premultiplied color of the image paint p at q in the
+// image's coordinates, as GL would sample its texture.
+func (p *paint) sample(q Pointf) [4]float32 {
+	im := p.image
+	r := im.pix.Rect
+	if r.Empty() {
+		return [4]float32{}
+	}
+	if !p.repl && (q.X < float32(r.Min.X) || q.X >= float32(r.Max.X) ||
+		q.Y < float32(r.Min.Y) || q.Y >= float32(r.Max.Y)) {
+		return [4]float32{}
+	}
+	// Relative to the image's top left corner.
+	q = q.Sub(Pointf{float32(r.Min.X), float32(r.Min.Y)})
+
+	switch p.filter {
+	case FILTER_NEAREST:
+		return im.texel(0, int(math.Floor(float64(q.X))), int(math.Floor(float64(q.Y))), p.repl)
+	case FILTER_MIPMAP:
+		// The level where a viewport pixel is about a texel.
+		m := p.toGradient
+		scale := MaxF(length(m.TransformVector(Pointf{1, 0})), length(m.TransformVector(Pointf{0, 1})))
+		lod := math.Log2(float64(scale))
+		if lod > 0 {
+			l0 := int(lod)
+			f := float32(lod) - float32(l0)
+			c0 := im.bilinear(l0, q, p.repl)
+			c1 := im.bilinear(l0+1, q, p.repl)
+			for i := range c0 {
+				c0[i] += (c1[i] - c0[i]) * f
+			}
+			return c0
+		}
+	}
+	return im.bilinear(0, q, p.repl)
+}
+
+// bilinear interpolates between the four texels of level nearest q, which
+// is relative to the top left corner of the image at level 0.
+func (im *Image) bilinear(level int, q Pointf, repl bool) [4]float32 {
+	l := im.level(level)
+	r := im.pix.Rect
+	q.X = q.X*float32(l.Rect.Dx())/float32(r.Dx()) - .5
+	q.Y = q.Y*float32(l.Rect.Dy())/float32(r.Dy()) - .5
+	x, y := math.Floor(float64(q.X)), math.Floor(float64(q.Y))
+	fx, fy := q.X-float32(x), q.Y-float32(y)
+	i, j := int(x), int(y)
+	c00, c10 := im.texel(level, i, j, repl), im.texel(level, i+1, j, repl)
+	c01, c11 := im.texel(level, i, j+1, repl), im.texel(level, i+1, j+1, repl)
+	var c [4]float32
+	for k := range c {
+		top := c00[k] + (c10[k]-c00[k])*fx
+		bottom := c01[k] + (c11[k]-c01[k])*fx
+		c[k] = top + (bottom-top)*fy
+	}
+	return c
+}
+
+// texel returns the premultiplied color of the texel at i, j of level,
+// counted from its top left, wrapping or clamping to the edge.
+func (im *Image) texel(level, i, j int, repl bool) [4]float32 {
+	l := im.level(level)
+	w, h := l.Rect.Dx(), l.Rect.Dy()
+	if repl {
+		i, j = ((i%w)+w)%w, ((j%h)+h)%h
+	} else {
+		i, j = clampInt(i, 0, w-1), clampInt(j, 0, h-1)
+	}
+	c := l.RGBAAt(l.Rect.Min.X+i, l.Rect.Min.Y+j)
+	return [4]float32{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+}
+
+// level returns mipmap level n of im, or its last level if it has fewer.
+// Each level halves the size of the one before, rounding down, and
+// averages its pixels.
+func (im *Image) level(n int) *image.RGBA {
+	if n == 0 {
+		return im.pix
+	}
+	if im.mips == nil {
+		l := im.pix
+		for l.Rect.Dx() > 1 || l.Rect.Dy() > 1 {
+			w, h := l.Rect.Dx(), l.Rect.Dy()
+			next := image.NewRGBA(image.Rect(0, 0, maxInt(1, w/2), maxInt(1, h/2)))
+			for y := 0; y < next.Rect.Dy(); y++ {
+				for x := 0; x < next.Rect.Dx(); x++ {
+					var sum [4]int
+					for _, d := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+						c := l.RGBAAt(l.Rect.Min.X+clampInt(2*x+d.X, 0, w-1), l.Rect.Min.Y+clampInt(2*y+d.Y, 0, h-1))
+						sum[0] += int(c.R)
+						sum[1] += int(c.G)
+						sum[2] += int(c.B)
+						sum[3] += int(c.A)
+					}
+					next.SetRGBA(x, y, color.RGBA{uint8((sum[0] + 2) / 4), uint8((sum[1] + 2) / 4), uint8((sum[2] + 2) / 4), uint8((sum[3] + 2) / 4)})
+				}
+			}
+			im.mips = append(im.mips, next)
+			l = next
+		}
+	}
+	if len(im.mips) == 0 {
+		return im.pix
+	}
+	if n > len(im.mips) {
+		n = len(im.mips)
+	}
+	return im.mips[n-1]
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/gl"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+var (
+	green = color.RGBA{0, 0xff, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// row returns an image one pixel high of the given colors.
+func row(cs ...color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(cs), 1))
+	for i, c := range cs {
+		img.SetRGBA(i, 0, c)
+	}
+	return img
+}
+
+func TestDrawImageNearest(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, green)
+	src.SetRGBA(0, 1, blue)
+	src.SetRGBA(1, 1, white)
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 8, 8), src, Scale(.25, .25), FILTER_NEAREST)
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 3, 0)
+	AssertRGBA(t, green, img, 4, 3)
+	AssertRGBA(t, blue, img, 0, 7)
+	AssertRGBA(t, white, img, 7, 4)
+	AssertRGBA(t, color.RGBA{}, img, 8, 0)
+}
+
+func TestDrawImageBilinear(t *testing.T) {
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 20, 10), row(black, white), Scale(.1, .1), FILTER_BILINEAR)
+	img := rasterize(dl)
+
+	// Clamped to the edge pixels within half a pixel of the edge.
+	AssertRGBA(t, black, img, 2, 5)
+	AssertRGBA(t, color.RGBA{115, 115, 115, 0xff}, img, 9, 5)
+	AssertRGBA(t, white, img, 17, 5)
+}
+
+func TestDrawImageRepl(t *testing.T) {
+	src := NewImage(row(red, green))
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 20, 1), src, Identity(), FILTER_NEAREST)
+	src.Repl = true
+	dl.DrawImage(Rect(0, 1, 20, 2), src, Identity(), FILTER_NEAREST)
+	img := rasterize(dl)
+
+	AssertRGBA(t, green, img, 1, 0)
+	AssertRGBA(t, color.RGBA{}, img, 2, 0)
+	AssertRGBA(t, red, img, 4, 1)
+	AssertRGBA(t, green, img, 5, 1)
+}
+
+func TestDrawSubImage(t *testing.T) {
+	src := NewImage(row(red, green, blue, white)).SubImage(image.Rect(1, 0, 3, 1))
+	src.Repl = true
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 6, 1), src, Identity(), FILTER_NEAREST)
+	img := rasterize(dl)
+
+	AssertRGBA(t, blue, img, 0, 0)
+	AssertRGBA(t, green, img, 1, 0)
+	AssertRGBA(t, blue, img, 2, 0)
+	AssertRGBA(t, green, img, 5, 0)
+}
+
+func TestDrawImageMipmap(t *testing.T) {
+	checker := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if (x+y)%2 == 0 {
+				checker.SetRGBA(x, y, white)
+			} else {
+				checker.SetRGBA(x, y, black)
+			}
+		}
+	}
+	src := NewImage(checker)
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 2, 2), src, Scale(4, 4), FILTER_NEAREST)
+	dl.DrawImage(Rect(2, 0, 4, 2), src, Translate(Pointf{-8, 0}).Mul(Scale(4, 4)), FILTER_MIPMAP)
+	img := rasterize(dl)
+
+	AssertRGBA(t, white, img, 0, 0)
+	// Averaged rather than aliased.
+	AssertRGBA(t, color.RGBA{128, 128, 128, 0xff}, img, 2, 0)
+	AssertRGBA(t, color.RGBA{128, 128, 128, 0xff}, img, 3, 1)
+}
+
+func TestDrawImageTransformAndOpacity(t *testing.T) {
+	dl := &DisplayList{}
+	dl.Transform(Translate(Pointf{5, 5}))
+	dl.SetOpacity(.5)
+	dl.DrawImage(Rect(0, 0, 2, 2), row(red, green), Identity(), FILTER_NEAREST)
+	img := rasterize(dl)
+
+	AssertRGBA(t, color.RGBA{}, img, 4, 5)
+	AssertRGBA(t, color.RGBA{0x80, 0, 0, 0x80}, img, 5, 5)
+	AssertRGBA(t, color.RGBA{0, 0x80, 0, 0x80}, img, 6, 5)
+	AssertRGBA(t, color.RGBA{}, img, 6, 6)
+}
+
+func TestEncodeImages(t *testing.T) {
+	src := NewImage(row(red, green, blue))
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 3, 1), src.SubImage(image.Rect(1, 0, 3, 1)), Identity(), FILTER_NEAREST)
+	dl.DrawImage(Rect(0, 1, 3, 2), src, Identity(), FILTER_BILINEAR)
+	b, err := dl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o DisplayList
+	if err := o.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	AssertTrue(t, dl.Equal(&o))
+	AssertRGBA(t, green, rasterize(&o), 1, 0)
+
+	o.images[0].pix.Pix[1] = 0
+	AssertFalse(t, dl.Equal(&o))
+	if err := o.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("truncated images decoded")
+	}
+}
+
+func TestDrawImageReleasesCopies(t *testing.T) {
+	deleted := 0
+	saved := deleteTexture
+	deleteTexture = func(gl.Texture) { deleted++ }
+	defer func() { deleteTexture = saved }()
+
+	kept := NewImage(row(red))
+	dl := &DisplayList{}
+	dl.DrawImage(Rect(0, 0, 10, 10), row(green), Identity(), FILTER_NEAREST)
+	dl.DrawImage(Rect(10, 0, 20, 10), kept, Identity(), FILTER_NEAREST)
+	// As if drawn.
+	for _, im := range dl.images {
+		im.uploaded = true
+	}
+	dl.releaseCopies()
+
+	testhelpers.AssertInt(t, 1, deleted)
+	AssertFalse(t, dl.images[0].uploaded)
+	AssertTrue(t, kept.uploaded)
+}
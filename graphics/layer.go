@@ -0,0 +1,436 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"log"
+	"math"
+
+	"github.com/go-gl/gl"
+	"github.com/google/gojiraw/graphics/drawop"
+)
+
+// A LayerCache keeps the pixels of a layer from one draw to the next so
+// that a layer drawing the same ops into the same bounds is composited
+// again without drawing its contents.
+type LayerCache struct {
+	// What Rasterize drew.
+	software layerKey
+	pix      *image.RGBA
+
+	// What Draw drew.
+	gl         layerKey
+	texture    gl.Texture
+	hasTexture bool
+}
+
+// Release frees the GL texture of c and forgets what it cached.
+func (c *LayerCache) Release() {
+	if c.hasTexture {
+		c.texture.Delete()
+	}
+	*c = LayerCache{}
+}
+
+// A layerKey is what a layer's pixels depend on: its ops, the images and
+// the caches of the layers they draw, its bounds and for GL the size of the
+// viewport.
+type layerKey struct {
+	bounds        image.Rectangle
+	width, height float32
+	opCodes       []uint8
+	integers      []uint32
+	floats        []float32
+	bytes         []uint8
+	images        []*Image
+	caches        []*LayerCache
+}
+
+func (k *layerKey) equal(o *layerKey) bool {
+	if k.bounds != o.bounds || k.width != o.width || k.height != o.height ||
+		len(k.opCodes) != len(o.opCodes) || len(k.integers) != len(o.integers) ||
+		len(k.floats) != len(o.floats) || len(k.bytes) != len(o.bytes) ||
+		len(k.images) != len(o.images) || len(k.caches) != len(o.caches) {
+		return false
+	}
+	for i := range k.opCodes {
+		if k.opCodes[i] != o.opCodes[i] {
+			return false
+		}
+	}
+	for i := range k.integers {
+		if k.integers[i] != o.integers[i] {
+			return false
+		}
+	}
+	for i := range k.floats {
+		if k.floats[i] != o.floats[i] {
+			return false
+		}
+	}
+	for i := range k.bytes {
+		if k.bytes[i] != o.bytes[i] {
+			return false
+		}
+	}
+	for i := range k.images {
+		if k.images[i] != o.images[i] {
+			return false
+		}
+	}
+	for i := range k.caches {
+		if k.caches[i] != o.caches[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// layerStart is where the contents of a layer being recorded start.
+type layerStart struct {
+	counts                           int
+	opCodes, integers, floats, bytes int
+}
+
+// BeginLayer draws what follows, until the matching EndLayer, into an
+// offscreen layer that is then composited with op and opacity. Only what
+// is inside bounds, given in the current drawing coordinates, is kept. Use
+// it to fade or blend a group of primitives as one.
+func (dl *DisplayList) BeginLayer(bounds Rectanglef, opacity float32, op drawop.Drawop) {
+	dl.BeginCachedLayer(bounds, opacity, op, nil)
+}
+
+// BeginCachedLayer is BeginLayer keeping the layer's pixels in c, if not
+// nil, for the next time the layer is drawn.
+func (dl *DisplayList) BeginCachedLayer(bounds Rectanglef, opacity float32, op drawop.Drawop, c *LayerCache) {
+	s := dl.current()
+	b := s.transform.TransformRect(bounds)
+	if s.clipped {
+		b = b.Intersect(s.clip)
+	}
+	cache := uint32(0)
+	if c != nil {
+		dl.caches = append(dl.caches, c)
+		cache = uint32(len(dl.caches))
+	}
+
+	dl.opCodes = append(dl.opCodes, DRAW_OP_BEGIN_LAYER)
+	dl.integers = append(dl.integers, uint32(op), cache, 0, 0, 0, 0)
+	// Whole pixels.
+	dl.floats = append(dl.floats,
+		float32(math.Floor(float64(b.Min.X))), float32(math.Floor(float64(b.Min.Y))),
+		float32(math.Ceil(float64(b.Max.X))), float32(math.Ceil(float64(b.Max.Y))), opacity)
+	dl.layers = append(dl.layers, layerStart{
+		len(dl.integers) - 4, len(dl.opCodes), len(dl.integers), len(dl.floats), len(dl.bytes)})
+	// The layer doesn't depend on the color before it.
+	dl.emitColor()
+}
+
+// EndLayer composites the layer begun by the matching BeginLayer.
+func (dl *DisplayList) EndLayer() {
+	n := len(dl.layers)
+	if n == 0 {
+		log.Panic("DisplayList.EndLayer without a matching BeginLayer")
+	}
+	l := dl.layers[n-1]
+	dl.layers = dl.layers[:n-1]
+	copy(dl.integers[l.counts:], []uint32{
+		uint32(len(dl.opCodes) - l.opCodes), uint32(len(dl.integers) - l.integers),
+		uint32(len(dl.floats) - l.floats), uint32(len(dl.bytes) - l.bytes)})
+	dl.opCodes = append(dl.opCodes, DRAW_OP_END_LAYER)
+	// What follows doesn't depend on whether the layer's ops were drawn or
+	// cached.
+	dl.emitColor()
+	dl.emitClip()
+}
+
+// checkLayers panics if a layer was begun and not ended: its ops would
+// draw offscreen and never be composited.
+func (dl *DisplayList) checkLayers(method string) {
+	if len(dl.layers) != 0 {
+		log.Panicf("DisplayList.%s with a BeginLayer without a matching EndLayer", method)
+	}
+}
+
+// A DRAW_OP_BEGIN_LAYER has the op, one more than the index of the cache
+// or 0 for none and the number of op codes, integers, floats and bytes
+// before the DRAW_OP_END_LAYER in the integers. It has the bounds in whole
+// viewport pixels and the opacity in the floats. A DRAW_OP_END_LAYER has
+// nothing.
+type layer struct {
+	op      drawop.Drawop
+	cache   *LayerCache
+	counts  [4]int
+	bounds  image.Rectangle
+	opacity float32
+}
+
+// layerAt decodes the DRAW_OP_BEGIN_LAYER whose data starts at the given
+// indices.
+func (dl *DisplayList) layerAt(integer, float int) (l *layer, integers, floats int) {
+	n := dl.integers[integer : integer+6]
+	f := dl.floats[float : float+5]
+	l = &layer{
+		op:      drawop.Drawop(n[0]),
+		counts:  [4]int{int(n[2]), int(n[3]), int(n[4]), int(n[5])},
+		bounds:  image.Rect(int(f[0]), int(f[1]), int(f[2]), int(f[3])),
+		opacity: f[4],
+	}
+	// Caches aren't encoded.
+	if c := int(n[1]); c > 0 && c <= len(dl.caches) {
+		l.cache = dl.caches[c-1]
+	}
+	return l, integer + 6, float + 5
+}
+
+// key returns a copy of what the contents of l, starting at the given
+// indices, depend on. The indices of images and caches in its integers are
+// replaced by their order in the layer so that what is drawn outside the
+// layer doesn't change the key.
+func (dl *DisplayList) key(l *layer, opCode, integer, float, byte int, bounds image.Rectangle) layerKey {
+	k := layerKey{
+		bounds:   bounds,
+		opCodes:  append([]uint8(nil), dl.opCodes[opCode:opCode+l.counts[0]]...),
+		integers: append([]uint32(nil), dl.integers[integer:integer+l.counts[1]]...),
+		floats:   append([]float32(nil), dl.floats[float:float+l.counts[2]]...),
+		bytes:    append([]uint8(nil), dl.bytes[byte:byte+l.counts[3]]...),
+	}
+	local := func(i uint32) uint32 {
+		im := dl.images[i]
+		for j, o := range k.images {
+			if o == im {
+				return uint32(j)
+			}
+		}
+		k.images = append(k.images, im)
+		return uint32(len(k.images) - 1)
+	}
+	n := k.integers
+	for i, op := range k.opCodes {
+		switch op {
+		case DRAW_OP_BEGIN_LAYER:
+			if c := int(n[1]); c > 0 && c <= len(dl.caches) {
+				k.caches = append(k.caches, dl.caches[c-1])
+				n[1] = uint32(len(k.caches))
+			}
+			n = n[6:]
+		case DRAW_OP_CLIP, DRAW_OP_SHAPE:
+			n = n[1:]
+		case DRAW_OP_IMAGE:
+			n[0] = local(n[0])
+			n = n[4:]
+		case DRAW_OP_MASKED:
+			n[0] = local(n[0])
+			if n[3] != 0 {
+				n[3] = local(n[3]-1) + 1
+			}
+			n = n[9:]
+		case DRAW_OP_PAINT:
+			n = n[3:]
+		case DRAW_OP_PATH:
+			n = n[3+n[2]:]
+		case DRAW_OP_QUADS:
+			n = n[2:]
+		case DRAW_OP_COLOR, DRAW_OP_END_LAYER:
+		default:
+			log.Panicf("layer op %d: unknown op code %d", i, op)
+		}
+	}
+	return k
+}
+
+// softwareLayer is a layer being drawn by Rasterize.
+type softwareLayer struct {
+	*layer
+	parent     *image.RGBA
+	parentClip image.Rectangle
+	pix        *image.RGBA
+	key        layerKey
+	fromCache  bool
+}
+
+func (sr *softwareRasterizer) doBeginLayer() {
+	var l *layer
+	l, sr.integer, sr.float = sr.dl.layerAt(sr.integer, sr.float)
+	b := l.bounds.Intersect(sr.dst.Bounds())
+	sl := softwareLayer{layer: l, parent: sr.dst, parentClip: sr.clip}
+	if c := l.cache; c != nil {
+		sl.key = sr.dl.key(l, sr.opCode+1, sr.integer, sr.float, sr.byte, b)
+		if c.pix != nil && c.software.equal(&sl.key) {
+			sl.pix, sl.fromCache = c.pix, true
+			sr.layers = append(sr.layers, sl)
+			sr.opCode += l.counts[0]
+			sr.integer += l.counts[1]
+			sr.float += l.counts[2]
+			sr.byte += l.counts[3]
+			return
+		}
+	}
+	sl.pix = image.NewRGBA(b)
+	sr.layers = append(sr.layers, sl)
+	sr.dst = sl.pix
+	sr.clip = sr.clip.Intersect(b)
+}
+
+// doEndLayer composites the layer with its op and opacity.
+func (sr *softwareRasterizer) doEndLayer() {
+	n := len(sr.layers)
+	sl := sr.layers[n-1]
+	sr.layers = sr.layers[:n-1]
+	sr.dst, sr.clip = sl.parent, sl.parentClip
+	if c := sl.cache; c != nil && !sl.fromCache {
+		c.software, c.pix = sl.key, sl.pix
+	}
+
+	r := sl.pix.Rect.Intersect(sr.clip)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i, j := sl.pix.PixOffset(x, y), sr.dst.PixOffset(x, y)
+			src, dst := sl.pix.Pix[i:i+4], sr.dst.Pix[j:j+4]
+			var s, d [4]float32
+			for k := range s {
+				s[k] = float32(src[k]) / 255 * sl.opacity
+				d[k] = float32(dst[k]) / 255
+			}
+			c := sl.op.Composite(s, d)
+			for k := range c {
+				dst[k] = uint8(MaxF(0, MinF(c[k], 1))*255 + .5)
+			}
+		}
+	}
+}
+
+// glLayer is a layer being drawn by Draw, into the texture of a
+// framebuffer the size of the viewport.
+type glLayer struct {
+	*layer
+	framebuffer  gl.Framebuffer
+	renderbuffer gl.Renderbuffer
+	texture      gl.Texture
+	key          layerKey
+	fromCache    bool
+}
+
+func (dl *DisplayList) DoBeginLayer(program *gl.Program) {
+	var l *layer
+	l, dl.cur_integer, dl.cur_float = dl.layerAt(dl.cur_integer, dl.cur_float)
+	w, h := int(dl.cur_width), int(dl.cur_height)
+	l.bounds = l.bounds.Intersect(image.Rect(0, 0, w, h))
+	gll := glLayer{layer: l}
+	if c := l.cache; c != nil {
+		gll.key = dl.key(l, dl.cur_op+1, dl.cur_integer, dl.cur_float, dl.cur_byte, l.bounds)
+		gll.key.width, gll.key.height = dl.cur_width, dl.cur_height
+		if c.hasTexture && c.gl.equal(&gll.key) {
+			gll.texture, gll.fromCache = c.texture, true
+			dl.cur_layers = append(dl.cur_layers, gll)
+			dl.cur_op += l.counts[0]
+			dl.cur_integer += l.counts[1]
+			dl.cur_float += l.counts[2]
+			dl.cur_byte += l.counts[3]
+			return
+		}
+	}
+
+	gll.texture = gl.GenTexture()
+	gll.texture.Bind(gl.TEXTURE_2D)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, int(gl.RGBA), w, h, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	// Paths need a stencil buffer.
+	gll.renderbuffer = gl.GenRenderbuffer()
+	gll.renderbuffer.Bind()
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.STENCIL_INDEX8, w, h)
+	gll.framebuffer = gl.GenFramebuffer()
+	gll.framebuffer.Bind()
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, gll.texture, 0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.STENCIL_ATTACHMENT, gl.RENDERBUFFER, gll.renderbuffer)
+	if s := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); s != gl.FRAMEBUFFER_COMPLETE {
+		log.Panicf("layer framebuffer incomplete: %x", s)
+	}
+	gl.Disable(gl.SCISSOR_TEST)
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+	dl.cur_layers = append(dl.cur_layers, gll)
+	dl.scissor()
+}
+
+// DoEndLayer returns to the framebuffer before the layer and composites the
+// layer's texture into it with the masked program.
+func (dl *DisplayList) DoEndLayer(program *gl.Program) {
+	n := len(dl.cur_layers)
+	gll := dl.cur_layers[n-1]
+	dl.cur_layers = dl.cur_layers[:n-1]
+	if n > 1 {
+		dl.cur_layers[n-2].framebuffer.Bind()
+	} else {
+		gl.Framebuffer(0).Bind()
+	}
+	dl.scissor()
+	if !gll.fromCache {
+		gll.framebuffer.Delete()
+		gll.renderbuffer.Delete()
+		if c := gll.cache; c != nil {
+			if c.hasTexture {
+				c.texture.Delete()
+			}
+			c.gl, c.texture, c.hasTexture = gll.key, gll.texture, true
+		}
+	}
+
+	// The texture's rows are upside down.
+	r := gll.bounds
+	x0, y0, x1, y1 := float32(r.Min.X), float32(r.Min.Y), float32(r.Max.X), float32(r.Max.Y)
+	dl.drawMasked(program, &masked{
+		source: &paint{
+			kind: paintImage,
+			image: &Image{
+				pix:      &image.RGBA{Rect: image.Rect(0, 0, int(dl.cur_width), int(dl.cur_height))},
+				texture:  gll.texture,
+				uploaded: true,
+			},
+			filter:     FILTER_NEAREST,
+			toGradient: Matrix{1, 0, 0, -1, 0, dl.cur_height},
+			opacity:    gll.opacity,
+		},
+		op:   gll.op,
+		quad: []float32{x0, y0, x1, y0, x1, y1, x0, y1},
+	})
+	if gll.cache == nil {
+		gll.texture.Delete()
+	}
+}
+
+// scissor limits drawing to the clip and the bounds of the current layer.
+func (dl *DisplayList) scissor() {
+	r := image.Rect(0, 0, int(dl.cur_width), int(dl.cur_height))
+	scissored := false
+	if dl.cur_clipped {
+		// Truncated to whole pixels like glScissor.
+		c := dl.cur_clip
+		r = image.Rect(int(c.Min.X), int(c.Min.Y), int(c.Min.X)+int(c.Dx()), int(c.Min.Y)+int(c.Dy()))
+		scissored = true
+	}
+	if n := len(dl.cur_layers); n > 0 {
+		r = r.Intersect(dl.cur_layers[n-1].bounds)
+		scissored = true
+	}
+	if !scissored {
+		gl.Disable(gl.SCISSOR_TEST)
+		return
+	}
+	// GL puts the origin at the bottom left.
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(r.Min.X, int(dl.cur_height)-r.Max.Y, r.Dx(), r.Dy())
+	CheckForGLErrors()
+}
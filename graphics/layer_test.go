@@ -0,0 +1,196 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/gojiraw/graphics/drawop"
+)
+
+func TestLayerGroupOpacity(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.BeginLayer(Rect(0, 0, 20, 20), 0.5, drawop.SoverD)
+	dl.DrawQuads([][4]Pointf{
+		{{0, 0}, {12, 0}, {12, 20}, {0, 20}},
+		{{8, 0}, {20, 0}, {20, 20}, {8, 20}}})
+	dl.EndLayer()
+	img := rasterize(dl)
+
+	// The overlap isn't blended twice.
+	half := color.RGBA{0x80, 0, 0, 0x80}
+	AssertRGBA(t, half, img, 4, 5)
+	AssertRGBA(t, half, img, 10, 5)
+	AssertRGBA(t, half, img, 16, 5)
+}
+
+func TestLayerNested(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.BeginLayer(Rect(0, 0, 20, 20), 0.5, drawop.SoverD)
+	dl.BeginLayer(Rect(0, 0, 20, 20), 0.5, drawop.SoverD)
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}})
+	dl.EndLayer()
+	dl.EndLayer()
+	img := rasterize(dl)
+
+	AssertRGBA(t, color.RGBA{0x40, 0, 0, 0x40}, img, 10, 10)
+}
+
+func TestLayerBounds(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.Transform(Translate(Pointf{2, 2}))
+	dl.BeginLayer(Rect(3, 3, 13, 13), 1, drawop.SoverD)
+	dl.DrawQuads([][4]Pointf{{{-2, -2}, {18, -2}, {18, 18}, {-2, 18}}})
+	dl.EndLayer()
+	img := rasterize(dl)
+
+	AssertRGBA(t, color.RGBA{}, img, 4, 4)
+	AssertRGBA(t, red, img, 5, 5)
+	AssertRGBA(t, red, img, 14, 14)
+	AssertRGBA(t, color.RGBA{}, img, 15, 15)
+}
+
+func TestLayerOp(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}})
+	dl.BeginLayer(Rect(0, 0, 20, 10), 1, drawop.SinD)
+	dl.SetColor(green)
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {10, 0}, {10, 20}, {0, 20}}})
+	dl.EndLayer()
+	img := rasterize(dl)
+
+	AssertRGBA(t, green, img, 5, 5)
+	// The layer is transparent where nothing was drawn into it.
+	AssertRGBA(t, color.RGBA{}, img, 15, 5)
+	// Outside the bounds.
+	AssertRGBA(t, red, img, 5, 15)
+	AssertRGBA(t, red, img, 15, 15)
+}
+
+func TestLayerCache(t *testing.T) {
+	c := &LayerCache{}
+	record := func(inside color.RGBA) *DisplayList {
+		dl := &DisplayList{}
+		dl.SetColor(red)
+		dl.BeginCachedLayer(Rect(0, 0, 10, 10), 1, drawop.SoverD, c)
+		dl.SetColor(inside)
+		dl.DrawQuads([][4]Pointf{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}})
+		dl.EndLayer()
+		dl.DrawQuads([][4]Pointf{{{10, 10}, {20, 10}, {20, 20}, {10, 20}}})
+		return dl
+	}
+
+	img := rasterize(record(green))
+	AssertRGBA(t, green, img, 5, 5)
+	AssertRGBA(t, green, img, 15, 15)
+	pix := c.pix
+	if pix == nil {
+		t.Fatal("the layer wasn't cached")
+	}
+
+	// Drawing the same layer again composites the cached pixels.
+	pix.SetRGBA(5, 5, blue)
+	img = rasterize(record(green))
+	AssertRGBA(t, blue, img, 5, 5)
+	AssertRGBA(t, green, img, 6, 6)
+	// The color set in the layer outlives it.
+	AssertRGBA(t, green, img, 15, 15)
+
+	// Different ops draw the layer again.
+	img = rasterize(record(white))
+	AssertRGBA(t, white, img, 5, 5)
+	AssertRGBA(t, white, img, 15, 15)
+	AssertTrue(t, c.pix != pix)
+}
+
+func TestLayerCacheImages(t *testing.T) {
+	c := &LayerCache{}
+	inside := tile(green)
+	record := func(outside bool) *DisplayList {
+		dl := &DisplayList{}
+		if outside {
+			dl.DrawImage(Rect(10, 10, 20, 20), tile(blue), Identity(), FILTER_NEAREST)
+		}
+		dl.BeginCachedLayer(Rect(0, 0, 10, 10), 1, drawop.SoverD, c)
+		dl.DrawImage(Rect(0, 0, 10, 10), inside, Identity(), FILTER_NEAREST)
+		dl.EndLayer()
+		return dl
+	}
+
+	rasterize(record(false))
+	c.pix.SetRGBA(5, 5, white)
+	// The layer's image has another index but the cache still hits.
+	img := rasterize(record(true))
+	AssertRGBA(t, white, img, 5, 5)
+	AssertRGBA(t, green, img, 6, 6)
+	AssertRGBA(t, blue, img, 15, 15)
+}
+
+func TestLayerEncoding(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.BeginCachedLayer(Rect(0, 0, 20, 20), 0.5, drawop.SoverD, &LayerCache{})
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {10, 0}, {10, 20}, {0, 20}}})
+	dl.EndLayer()
+	data, err := dl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &DisplayList{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	AssertTrue(t, dl.Equal(decoded))
+
+	// The cache isn't encoded.
+	a, b := rasterize(dl), rasterize(decoded)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			AssertRGBA(t, a.RGBAAt(x, y), b, x, y)
+		}
+	}
+}
+
+func TestEndLayerWithoutBegin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("EndLayer without BeginLayer didn't panic")
+		}
+	}()
+	(&DisplayList{}).EndLayer()
+}
+
+func TestUnendedLayer(t *testing.T) {
+	for name, use := range map[string]func(dl *DisplayList){
+		"Rasterize":     func(dl *DisplayList) { rasterize(dl) },
+		"MarshalBinary": func(dl *DisplayList) { dl.MarshalBinary() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s with a layer not ended didn't panic", name)
+				}
+			}()
+			dl := &DisplayList{}
+			dl.BeginLayer(Rect(0, 0, 20, 20), 1, drawop.SoverD)
+			use(dl)
+		}()
+	}
+}
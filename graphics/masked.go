@@ -0,0 +1,333 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/gl"
+	"github.com/google/gojiraw/graphics/drawop"
+)
+
+// Mask kinds say which part of a mask's pixels is its alpha.
+const (
+	MASK_ALPHA = iota
+	// The luminance of the premultiplied color.
+	MASK_LUMINANCE
+)
+
+// A Mask says how much of the source DrawMasked draws at each point. It
+// tiles the plane if its Image is an *Image with Repl set.
+type Mask struct {
+	Image image.Image
+	// Transform maps the drawing coordinates to the mask's, independently
+	// of the source.
+	Transform Matrix
+	Kind      int
+	Filter    int
+}
+
+// DrawMasked combines src, in mask, with what is drawn inside r, given in
+// the current drawing coordinates, using op. As in DrawInkings in
+// docs/inkings.md, m maps the drawing coordinates to src's and src sampled
+// with filter there, its alpha scaled by the mask's, is composited onto
+// each pixel. A nil mask draws all of src and a mask with a nil or empty
+// Image, like an empty src, draws nothing. Outside r nothing changes.
+//
+// The edges of r are anti-aliased only for ops that keep the destination
+// where the source is transparent, those with drawop.DoutS.
+func (dl *DisplayList) DrawMasked(r Rectanglef, src image.Image, m Matrix, filter int, mask *Mask, op drawop.Drawop) {
+	s := dl.current()
+	toDrawing, ok := s.transform.Invert()
+	if !ok {
+		return
+	}
+	// An empty mask covers nothing.
+	if mask != nil && (mask.Image == nil || mask.Image.Bounds().Empty()) {
+		return
+	}
+	im := dl.toImage(src)
+	if im.pix.Rect.Empty() {
+		return
+	}
+	maskIndex, maskRepl, maskKind, maskFilter := uint32(0), uint32(0), uint32(0), uint32(0)
+	toMask := Identity()
+	if mask != nil {
+		mi := dl.toImage(mask.Image)
+		maskIndex = uint32(dl.imageIndex(mi) + 1)
+		maskRepl = flag(mi.Repl)
+		maskKind, maskFilter = uint32(mask.Kind), uint32(mask.Filter)
+		toMask = mask.Transform.Mul(toDrawing)
+	}
+
+	dl.opCodes = append(dl.opCodes, DRAW_OP_MASKED)
+	dl.integers = append(dl.integers, uint32(dl.imageIndex(im)), uint32(filter), flag(im.Repl),
+		maskIndex, maskFilter, maskRepl, maskKind, uint32(op), dl.antialiasFlag())
+	for _, p := range []Pointf{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+		p = s.transform.Transform(p)
+		dl.floats = append(dl.floats, p.X, p.Y)
+	}
+	t, u := m.Mul(toDrawing), toMask
+	dl.floats = append(dl.floats, t.A, t.B, t.C, t.D, t.E, t.F, u.A, u.B, u.C, u.D, u.E, u.F, s.opacity)
+}
+
+// A DRAW_OP_MASKED has the index of the source image, its filter and
+// whether it tiles; one more than the index of the mask or 0 for none, its
+// filter, whether it tiles and its kind; the op and whether to anti-alias
+// in the integers. It has the quad to fill, the transforms from the
+// viewport to the source and to the mask and the opacity in the floats.
+const MASKED_FLOATS = 8 + 6 + 6 + 1
+
+// masked is a decoded DRAW_OP_MASKED.
+type masked struct {
+	source, mask *paint
+	op           drawop.Drawop
+	quad         []float32
+	antialias    bool
+}
+
+func (dl *DisplayList) maskedAt(integer, float int) (m *masked, integers, floats int) {
+	n := dl.integers[integer : integer+9]
+	f := dl.floats[float : float+MASKED_FLOATS]
+	m = &masked{
+		source: &paint{
+			kind:       paintImage,
+			image:      dl.images[n[0]],
+			filter:     int(n[1]),
+			repl:       n[2] != 0,
+			toGradient: Matrix{f[8], f[9], f[10], f[11], f[12], f[13]},
+			opacity:    f[20],
+		},
+		op:        drawop.Drawop(n[7]),
+		quad:      f[:8],
+		antialias: n[8] != 0,
+	}
+	if n[3] != 0 {
+		m.mask = &paint{
+			kind:       paintImage,
+			image:      dl.images[n[3]-1],
+			filter:     int(n[4]),
+			repl:       n[5] != 0,
+			luminance:  n[6] == MASK_LUMINANCE,
+			toGradient: Matrix{f[14], f[15], f[16], f[17], f[18], f[19]},
+			opacity:    1,
+		}
+	}
+	return m, integer + 9, float + MASKED_FLOATS
+}
+
+// maskAt returns the alpha of the mask p at v in viewport coordinates.
+func (p *paint) maskAt(v Pointf) float32 {
+	c := p.sample(p.toGradient.Transform(v))
+	if p.luminance {
+		return luminance(c)
+	}
+	return c[3]
+}
+
+// luminance returns the luminance of c by the coefficients of SVG's
+// luminance masks.
+func luminance(c [4]float32) float32 {
+	return 0.2125*c[0] + 0.7154*c[1] + 0.0721*c[2]
+}
+
+// The fragment shader for DRAW_OP_MASKED, run with the coverage vertex
+// shader. It outputs premultiplied colors for the blending of the op. It is
+// formatted with MASK_LUMINANCE.
+const maskedFragmentShader = `
+#version 400
+
+#define MASK_LUMINANCE %d
+
+uniform float u_Height;
+uniform sampler2D u_Source;
+uniform sampler2D u_Mask;
+// The rows of the transforms from the viewport to the source and mask.
+uniform vec3 u_ToSourceX;
+uniform vec3 u_ToSourceY;
+uniform vec3 u_ToMaskX;
+uniform vec3 u_ToMaskY;
+// The top left corner and size of each and whether it tiles.
+uniform vec4 u_SourceRect;
+uniform vec4 u_MaskRect;
+uniform int u_SourceRepl;
+uniform int u_MaskRepl;
+// -1 for no mask.
+uniform int u_MaskKind;
+uniform float u_Opacity;
+
+in float v_Coverage;
+out vec4 out_Color;
+
+vec4 sampleImage(sampler2D s, vec2 q, vec4 rect, int repl)
+{
+    vec2 uv = (q - rect.xy) / rect.zw;
+    vec4 c = texture(s, uv);
+    if (repl == 0 && (any(lessThan(uv, vec2(0.0))) || any(greaterThanEqual(uv, vec2(1.0))))) {
+        return vec4(0.0);
+    }
+    return c;
+}
+
+void main()
+{
+    vec3 p = vec3(gl_FragCoord.x, u_Height - gl_FragCoord.y, 1.0);
+    vec4 c = sampleImage(u_Source, vec2(dot(u_ToSourceX, p), dot(u_ToSourceY, p)), u_SourceRect, u_SourceRepl);
+    c *= u_Opacity;
+    if (u_MaskKind >= 0) {
+        vec4 m = sampleImage(u_Mask, vec2(dot(u_ToMaskX, p), dot(u_ToMaskY, p)), u_MaskRect, u_MaskRepl);
+        c *= u_MaskKind == MASK_LUMINANCE ? dot(m.rgb, vec3(0.2125, 0.7154, 0.0721)) : m.a;
+    }
+    out_Color = c * v_Coverage;
+}` // maskedFragmentShader
+
+// The program for DRAW_OP_MASKED, made when first needed.
+var (
+	maskedProgram      gl.Program
+	maskedProgramReady bool
+)
+
+func useMaskedProgram() gl.Program {
+	if !maskedProgramReady {
+		maskedProgram = createProgram(coverageVertexShader, fmt.Sprintf(maskedFragmentShader, MASK_LUMINANCE))
+		maskedProgramReady = true
+	}
+	maskedProgram.Use()
+	return maskedProgram
+}
+
+// DoMasked draws with the masked program, blending by the op's factors.
+func (dl *DisplayList) DoMasked(program *gl.Program) {
+	var m *masked
+	m, dl.cur_integer, dl.cur_float = dl.maskedAt(dl.cur_integer, dl.cur_float)
+	dl.drawMasked(program, m)
+}
+
+// drawMasked fills m's quad with its source in its mask, compositing with
+// its op.
+func (dl *DisplayList) drawMasked(program *gl.Program, m *masked) {
+	mp := useMaskedProgram()
+	defer program.Use()
+	mp.GetUniformLocation("u_Viewport").Uniform2f(1/dl.cur_width, 1/dl.cur_height)
+	mp.GetUniformLocation("u_Height").Uniform1f(dl.cur_height)
+	mp.GetUniformLocation("u_Opacity").Uniform1f(m.source.opacity)
+	setImageUniforms := func(p *paint, unit gl.GLenum, index int, name string) {
+		p.image.bind(unit, p.filter, p.repl)
+		r := p.image.pix.Rect
+		t := p.toGradient
+		mp.GetUniformLocation("u_" + name).Uniform1i(index)
+		mp.GetUniformLocation("u_To"+name+"X").Uniform3f(t.A, t.C, t.E)
+		mp.GetUniformLocation("u_To"+name+"Y").Uniform3f(t.B, t.D, t.F)
+		mp.GetUniformLocation("u_"+name+"Rect").Uniform4f(float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy()))
+		mp.GetUniformLocation("u_" + name + "Repl").Uniform1i(int(flag(p.repl)))
+	}
+	setImageUniforms(m.source, gl.TEXTURE0, 0, "Source")
+	if m.mask != nil {
+		setImageUniforms(m.mask, gl.TEXTURE1, 1, "Mask")
+		kind := MASK_ALPHA
+		if m.mask.luminance {
+			kind = MASK_LUMINANCE
+		}
+		mp.GetUniformLocation("u_MaskKind").Uniform1i(kind)
+	} else {
+		mp.GetUniformLocation("u_MaskKind").Uniform1i(-1)
+	}
+
+	fs, fd := gl.GLenum(gl.ZERO), gl.GLenum(gl.ZERO)
+	switch m.op & (drawop.SinD | drawop.SoutD) {
+	case drawop.SinD | drawop.SoutD:
+		fs = gl.ONE
+	case drawop.SinD:
+		fs = gl.DST_ALPHA
+	case drawop.SoutD:
+		fs = gl.ONE_MINUS_DST_ALPHA
+	}
+	switch m.op & (drawop.DinS | drawop.DoutS) {
+	case drawop.DinS | drawop.DoutS:
+		fd = gl.ONE
+	case drawop.DinS:
+		fd = gl.SRC_ALPHA
+	case drawop.DoutS:
+		fd = gl.ONE_MINUS_SRC_ALPHA
+	}
+	gl.BlendFuncSeparate(fs, fd, fs, fd)
+	defer DefaultBlend()
+
+	// Scaling a premultiplied source by its coverage blends it with the
+	// destination by the coverage only when the destination's factor is
+	// 1-αS or 1.
+	f := m.quad
+	var vertices []float32
+	if m.antialias && m.op&drawop.DoutS != 0 {
+		vertices = feather(nil, []Pointf{{f[0], f[1]}, {f[2], f[3]}, {f[4], f[5]}, {f[6], f[7]}})
+	} else {
+		vertices = []float32{
+			f[0], f[1], 1, f[2], f[3], 1, f[4], f[5], 1,
+			f[0], f[1], 1, f[4], f[5], 1, f[6], f[7], 1}
+	}
+	drawVertices(mp, vertices)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// doMasked fills the op's quad with the source in the mask, compositing
+// with the op.
+func (sr *softwareRasterizer) doMasked() {
+	var m *masked
+	m, sr.integer, sr.float = sr.dl.maskedAt(sr.integer, sr.float)
+	saved := sr.paint
+	sr.paint, sr.mask, sr.op = m.source, m.mask, m.op
+	// Like Draw, which can only blend partly covered pixels for these ops.
+	sr.fillQuad(m.quad, m.antialias && m.op&drawop.DoutS != 0)
+	sr.paint, sr.mask, sr.op = saved, nil, drawop.SoverD
+}
+
+// toImage returns src as an *Image, copying it if it isn't one. The
+// DisplayList owns the copies.
+func (dl *DisplayList) toImage(src image.Image) *Image {
+	if im, ok := src.(*Image); ok {
+		return im
+	}
+	im := NewImage(src)
+	dl.copies = append(dl.copies, im)
+	return im
+}
+
+// releaseCopies frees the textures of the images the DisplayList owns.
+// They are uploaded again if it is drawn again.
+func (dl *DisplayList) releaseCopies() {
+	for _, im := range dl.copies {
+		im.Release()
+	}
+}
+
+// imageIndex returns the index of im in the DisplayList's images, adding it
+// if need be.
+func (dl *DisplayList) imageIndex(im *Image) int {
+	for i, o := range dl.images {
+		if o == im {
+			return i
+		}
+	}
+	dl.images = append(dl.images, im)
+	return len(dl.images) - 1
+}
+
+func flag(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,160 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/gojiraw/graphics/drawop"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+// tile returns an Image of one pixel of c that tiles the plane.
+func tile(c color.RGBA) *Image {
+	im := NewImage(row(c))
+	im.Repl = true
+	return im
+}
+
+func TestDrawMaskedOps(t *testing.T) {
+	half := &Mask{Image: tile(color.RGBA{0, 0, 0, 0x80}), Transform: Identity()}
+	for _, c := range []struct {
+		op          drawop.Drawop
+		onRed, onNo color.RGBA
+	}{
+		{drawop.Clear, color.RGBA{}, color.RGBA{}},
+		{drawop.S, color.RGBA{0, 0x80, 0, 0x80}, color.RGBA{0, 0x80, 0, 0x80}},
+		{drawop.D, red, color.RGBA{}},
+		{drawop.SoverD, color.RGBA{0x7f, 0x80, 0, 0xff}, color.RGBA{0, 0x80, 0, 0x80}},
+		{drawop.DoverS, red, color.RGBA{0, 0x80, 0, 0x80}},
+		{drawop.SinD, color.RGBA{0, 0x80, 0, 0x80}, color.RGBA{}},
+		{drawop.DinS, color.RGBA{0x80, 0, 0, 0x80}, color.RGBA{}},
+		{drawop.SoutD, color.RGBA{}, color.RGBA{0, 0x80, 0, 0x80}},
+		{drawop.DoutS, color.RGBA{0x7f, 0, 0, 0x7f}, color.RGBA{}},
+		{drawop.SatopD, color.RGBA{0x7f, 0x80, 0, 0xff}, color.RGBA{}},
+		{drawop.DatopS, color.RGBA{0x80, 0, 0, 0x80}, color.RGBA{0, 0x80, 0, 0x80}},
+		{drawop.SxorD, color.RGBA{0x7f, 0, 0, 0x7f}, color.RGBA{0, 0x80, 0, 0x80}},
+	} {
+		dl := &DisplayList{}
+		dl.SetColor(red)
+		dl.DrawQuads([][4]Pointf{{{0, 0}, {10, 0}, {10, 20}, {0, 20}}})
+		dl.DrawMasked(Rect(0, 0, 20, 20), tile(green), Identity(), FILTER_NEAREST, half, c.op)
+		img := rasterize(dl)
+		if p := img.RGBAAt(5, 5); p != c.onRed {
+			t.Errorf("op %d over red: expected %v, got %v", c.op, c.onRed, p)
+		}
+		if p := img.RGBAAt(15, 5); p != c.onNo {
+			t.Errorf("op %d over nothing: expected %v, got %v", c.op, c.onNo, p)
+		}
+	}
+}
+
+func TestDrawMaskedOutsideR(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}})
+	dl.DrawMasked(Rect(0, 0, 10, 20), tile(green), Identity(), FILTER_NEAREST, nil, drawop.Clear)
+	img := rasterize(dl)
+
+	AssertRGBA(t, color.RGBA{}, img, 9, 5)
+	AssertRGBA(t, red, img, 10, 5)
+}
+
+func TestDrawMaskedLuminance(t *testing.T) {
+	dl := &DisplayList{}
+	mask := &Mask{Image: tile(green), Transform: Identity(), Kind: MASK_LUMINANCE}
+	dl.DrawMasked(Rect(0, 0, 10, 10), tile(red), Identity(), FILTER_NEAREST, mask, drawop.S)
+	mask = &Mask{Image: tile(green), Transform: Identity()}
+	dl.DrawMasked(Rect(10, 0, 20, 10), tile(red), Identity(), FILTER_NEAREST, mask, drawop.S)
+	img := rasterize(dl)
+
+	// Green is 0.7154 as bright as white.
+	AssertRGBA(t, color.RGBA{182, 0, 0, 182}, img, 5, 5)
+	AssertRGBA(t, red, img, 15, 5)
+}
+
+func TestDrawMaskedTransformAndRepl(t *testing.T) {
+	// The mask is stretched to twice the width of the source's pixels and
+	// tiles.
+	stripes := NewImage(row(black, color.RGBA{}))
+	stripes.Repl = true
+	mask := &Mask{Image: stripes, Transform: Scale(.5, 1)}
+	dl := &DisplayList{}
+	dl.DrawMasked(Rect(0, 0, 20, 1), tile(red), Identity(), FILTER_NEAREST, mask, drawop.SoverD)
+	// Without Repl, the mask is transparent outside its bounds.
+	mask = &Mask{Image: row(black, black), Transform: Translate(Pointf{0, -1})}
+	dl.DrawMasked(Rect(0, 1, 20, 2), tile(red), Identity(), FILTER_NEAREST, mask, drawop.SoverD)
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 1, 0)
+	AssertRGBA(t, color.RGBA{}, img, 2, 0)
+	AssertRGBA(t, color.RGBA{}, img, 3, 0)
+	AssertRGBA(t, red, img, 4, 0)
+	AssertRGBA(t, red, img, 1, 1)
+	AssertRGBA(t, color.RGBA{}, img, 2, 1)
+}
+
+func TestDrawMaskedAntialias(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(blue)
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}})
+	dl.DrawMasked(Rect(0, 0, 10.5, 10), tile(red), Identity(), FILTER_NEAREST, nil, drawop.SoverD)
+	// Without drawop.DoutS the destination can't show through the edges.
+	dl.DrawMasked(Rect(0, 10, 10.6, 20), tile(red), Identity(), FILTER_NEAREST, nil, drawop.S)
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 9, 5)
+	AssertRGBA(t, color.RGBA{0x80, 0, 0x80, 0xff}, img, 10, 5)
+	AssertRGBA(t, blue, img, 11, 5)
+	AssertRGBA(t, red, img, 9, 15)
+	AssertRGBA(t, red, img, 10, 15)
+	AssertRGBA(t, blue, img, 11, 15)
+}
+
+func TestEncodeMasked(t *testing.T) {
+	dl := &DisplayList{}
+	mask := &Mask{Image: tile(green), Transform: Scale(2, 2), Kind: MASK_LUMINANCE, Filter: FILTER_BILINEAR}
+	dl.DrawMasked(Rect(0, 0, 10, 10), tile(red), Identity(), FILTER_NEAREST, mask, drawop.DatopS)
+	b, err := dl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o DisplayList
+	if err := o.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	AssertTrue(t, dl.Equal(&o))
+}
+
+func TestDrawMaskedEmpty(t *testing.T) {
+	empty := NewImage(image.NewRGBA(image.Rectangle{}))
+	for _, c := range []struct {
+		src  image.Image
+		mask *Mask
+	}{
+		{empty, nil},
+		{tile(green), &Mask{Image: empty, Transform: Identity()}},
+		{tile(green), &Mask{Transform: Identity()}},
+	} {
+		dl := &DisplayList{}
+		dl.SetColor(red)
+		dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}})
+		dl.DrawMasked(Rect(0, 0, 20, 20), c.src, Identity(), FILTER_NEAREST, c.mask, drawop.S)
+		testhelpers.AssertInt(t, 0, len(dl.images))
+		AssertRGBA(t, red, rasterize(dl), 10, 10)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"math"
+)
+
+// A Matrix is a 2D affine transform. It maps (x, y) to
+// (A*x + C*y + E, B*x + D*y + F), i.e. it is the matrix
+//
+//	| A C E |
+//	| B D F |
+//	| 0 0 1 |
+//
+// The zero Matrix is degenerate. Use Identity() instead.
+type Matrix struct {
+	A, B, C, D, E, F float32
+}
+
+// Identity returns the identity transform.
+func Identity() Matrix {
+	return Matrix{1, 0, 0, 1, 0, 0}
+}
+
+// Translate returns a Matrix that translates by p.
+func Translate(p Pointf) Matrix {
+	return Matrix{1, 0, 0, 1, p.X, p.Y}
+}
+
+// Scale returns a Matrix that scales by sx, sy about the origin.
+func Scale(sx, sy float32) Matrix {
+	return Matrix{sx, 0, 0, sy, 0, 0}
+}
+
+// Rotate returns a Matrix that rotates by theta radians about the origin.
+// Because y increases down the page, positive angles rotate clockwise.
+func Rotate(theta float32) Matrix {
+	s, c := math.Sincos(float64(theta))
+	return Matrix{float32(c), float32(s), float32(-s), float32(c), 0, 0}
+}
+
+// Mul returns the product m*n: the transform that applies n and then m.
+func (m Matrix) Mul(n Matrix) Matrix {
+	return Matrix{
+		m.A*n.A + m.C*n.B,
+		m.B*n.A + m.D*n.B,
+		m.A*n.C + m.C*n.D,
+		m.B*n.C + m.D*n.D,
+		m.A*n.E + m.C*n.F + m.E,
+		m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+// Det returns the determinant of the linear part of m.
+func (m Matrix) Det() float32 {
+	return m.A*m.D - m.B*m.C
+}
+
+// Invert returns the inverse of m. ok is false if m is degenerate.
+func (m Matrix) Invert() (inv Matrix, ok bool) {
+	det := m.Det()
+	if det == 0 {
+		return Matrix{}, false
+	}
+	a := m.D / det
+	b := -m.B / det
+	c := -m.C / det
+	d := m.A / det
+	return Matrix{a, b, c, d, -(a*m.E + c*m.F), -(b*m.E + d*m.F)}, true
+}
+
+// IsIdentity reports whether m is the identity transform.
+func (m Matrix) IsIdentity() bool {
+	return m == Identity()
+}
+
+// Transform returns the image of point p under m.
+func (m Matrix) Transform(p Pointf) Pointf {
+	return Pointf{m.A*p.X + m.C*p.Y + m.E, m.B*p.X + m.D*p.Y + m.F}
+}
+
+// TransformVector returns the image of vector v under m, ignoring the
+// translation.
+func (m Matrix) TransformVector(v Pointf) Pointf {
+	return Pointf{m.A*v.X + m.C*v.Y, m.B*v.X + m.D*v.Y}
+}
+
+// TransformRect returns the smallest Rectanglef enclosing the image of r
+// under m.
+func (m Matrix) TransformRect(r Rectanglef) Rectanglef {
+	p := m.Transform(r.Min)
+	t := Rectanglef{p, p}
+	for _, q := range [...]Pointf{{r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+		p = m.Transform(q)
+		t = t.Union(Rectanglef{p, p})
+	}
+	return t
+}
+
+// PixelSize returns the approximate length in the source coordinates of m
+// of a unit in its destination coordinates. It is exact for transforms that
+// scale uniformly.
+func PixelSize(m Matrix) float32 {
+	det := m.Det()
+	if det == 0 {
+		return 0
+	}
+	return float32(1 / math.Sqrt(math.Abs(float64(det))))
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func AssertPointEqual(t *testing.T, expected, actual Pointf) {
+	AssertFloatEqual(t, expected.X, actual.X)
+	AssertFloatEqual(t, expected.Y, actual.Y)
+}
+
+func TestMatrixMul(t *testing.T) {
+	// Scale then translate.
+	m := Translate(Pointf{10, 20}).Mul(Scale(2, 3))
+	AssertPointEqual(t, Pointf{12, 23}, m.Transform(Pointf{1, 1}))
+	AssertPointEqual(t, Pointf{2, 3}, m.TransformVector(Pointf{1, 1}))
+
+	r := Rotate(math.Pi / 2)
+	AssertPointEqual(t, Pointf{0, 1}, r.Transform(Pointf{1, 0}))
+
+	AssertTrue(t, Identity().Mul(m) == m)
+	AssertTrue(t, m.Mul(Identity()) == m)
+	AssertTrue(t, Identity().IsIdentity())
+	AssertFalse(t, m.IsIdentity())
+}
+
+func TestMatrixInvert(t *testing.T) {
+	m := Translate(Pointf{-4, 7}).Mul(Rotate(0.3)).Mul(Scale(2, 0.5))
+	inv, ok := m.Invert()
+	AssertTrue(t, ok)
+	p := Pointf{3, -9}
+	AssertPointEqual(t, p, inv.Transform(m.Transform(p)))
+
+	_, ok = Scale(0, 1).Invert()
+	AssertFalse(t, ok)
+}
+
+func TestMatrixTransformRect(t *testing.T) {
+	r := Rotate(math.Pi / 4).TransformRect(Rect(-1, -1, 1, 1))
+	s := float32(math.Sqrt2)
+	AssertPointEqual(t, Pointf{-s, -s}, r.Min)
+	AssertPointEqual(t, Pointf{s, s}, r.Max)
+}
+
+func TestDisplayListState(t *testing.T) {
+	dl := &DisplayList{}
+	dl.Save()
+	dl.Transform(Translate(Pointf{100, 0}))
+	dl.Transform(Scale(2, 2))
+	dl.ClipRect(Rect(0, 0, 10, 10))
+	dl.DrawPoints([]Pointf{{5, 5}})
+	AssertFloatEqual(t, 110, dl.W)
+	AssertFloatEqual(t, 10, dl.H)
+	AssertTrue(t, dl.current().clip.Eq(Rect(100, 0, 120, 20)))
+	dl.Restore()
+
+	AssertTrue(t, dl.CurrentTransform().IsIdentity())
+	AssertFalse(t, dl.current().clipped)
+	// The clip, the points and the clip put back by Restore.
+	AssertTrue(t, len(dl.opCodes) == 3)
+	AssertTrue(t, dl.opCodes[0] == DRAW_OP_CLIP && dl.opCodes[2] == DRAW_OP_CLIP)
+}
+
+func TestDisplayListOpacity(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(color.RGBA{0, 0, 0, 200})
+	dl.Save()
+	dl.SetOpacity(0.5)
+	dl.SetOpacity(0.5)
+	dl.Restore()
+
+	AssertTrue(t, len(dl.bytes) == 16)
+	AssertTrue(t, dl.bytes[3] == 200 && dl.bytes[7] == 100 && dl.bytes[11] == 50 && dl.bytes[15] == 200)
+}
+
+func TestPixelSize(t *testing.T) {
+	AssertFloatEqual(t, 1, PixelSize(Identity()))
+	AssertFloatEqual(t, 0.25, PixelSize(Translate(Pointf{10, 10}).Mul(Scale(4, 4))))
+	AssertFloatEqual(t, 0.5, PixelSize(Rotate(1).Mul(Scale(2, 2))))
+
+	dl := &DisplayList{}
+	dl.Transform(Scale(2, 2))
+	dl.Save()
+	dl.Transform(Scale(4, 4))
+	AssertFloatEqual(t, 0.125, dl.PixelSize())
+	dl.Restore()
+	AssertFloatEqual(t, 0.5, dl.PixelSize())
+}
@@ -0,0 +1,467 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/go-gl/gl"
+)
+
+// Gradient kinds. A zero kind is a plain color.
+const (
+	GRADIENT_LINEAR = iota + 1
+	GRADIENT_RADIAL
+	GRADIENT_SWEEP
+
+	// The paint of DrawImage.
+	paintImage
+)
+
+// Spread modes say how a gradient continues past its ends.
+const (
+	SPREAD_PAD = iota
+	SPREAD_REPEAT
+	SPREAD_REFLECT
+)
+
+// Draw only uses the first MAX_GRADIENT_STOPS stops of a Gradient.
+const MAX_GRADIENT_STOPS = 16
+
+// A ColorStop is the color at Offset along a gradient, from 0 at its start
+// to 1 at its end.
+type ColorStop struct {
+	Offset float32
+	Color  color.RGBA
+}
+
+// A Gradient is a paint whose color varies across the plane. Colors are
+// interpolated premultiplied between the stops, which are in increasing
+// order of offset.
+type Gradient struct {
+	Kind int
+
+	// A linear gradient goes from P0 to P1, constant along perpendiculars to
+	// the line between them. A radial gradient goes from the circle of
+	// radius R0 around P0 to that of radius R1 around P1, the larger circle
+	// painting over the smaller where they overlap. A sweep gradient goes
+	// clockwise around P0 from StartAngle to EndAngle, in radians.
+	P0, P1               Pointf
+	R0, R1               float32
+	StartAngle, EndAngle float32
+
+	Stops  []ColorStop
+	Spread int
+
+	// Transform maps the gradient's coordinates to drawing coordinates.
+	Transform Matrix
+}
+
+// NewLinearGradient returns a padded linear gradient from p0 to p1.
+func NewLinearGradient(p0, p1 Pointf, stops ...ColorStop) *Gradient {
+	return &Gradient{Kind: GRADIENT_LINEAR, P0: p0, P1: p1, Stops: stops, Transform: Identity()}
+}
+
+// NewRadialGradient returns a padded radial gradient from the circle of
+// radius r0 around c0 to that of radius r1 around c1.
+func NewRadialGradient(c0 Pointf, r0 float32, c1 Pointf, r1 float32, stops ...ColorStop) *Gradient {
+	return &Gradient{Kind: GRADIENT_RADIAL, P0: c0, R0: r0, P1: c1, R1: r1, Stops: stops, Transform: Identity()}
+}
+
+// NewSweepGradient returns a padded sweep gradient around c from start to
+// end.
+func NewSweepGradient(c Pointf, start, end float32, stops ...ColorStop) *Gradient {
+	return &Gradient{Kind: GRADIENT_SWEEP, P0: c, StartAngle: start, EndAngle: end, Stops: stops, Transform: Identity()}
+}
+
+// SetPaint paints subsequent drawing with g, positioned in the current
+// drawing coordinates, until the next SetPaint or SetColor.
+func (dl *DisplayList) SetPaint(g *Gradient) {
+	dl.paint = *g
+	dl.paint.Stops = append([]ColorStop(nil), g.Stops...)
+	sort.Stable(colorStops(dl.paint.Stops))
+	if len(dl.paint.Stops) > MAX_GRADIENT_STOPS {
+		dl.paint.Stops = dl.paint.Stops[:MAX_GRADIENT_STOPS]
+	}
+	dl.paintTransform = dl.current().transform.Mul(g.Transform)
+	dl.hasPaint = true
+	dl.emitColor()
+}
+
+// emitPaint records a DRAW_OP_PAINT. It has the kind, spread and number of
+// stops in the integers; the transform from the viewport to the
+// gradient, the points, radii and angles and the stops' offsets in the
+// floats and the stops' colors in the bytes.
+func (dl *DisplayList) emitPaint() {
+	g := &dl.paint
+	toGradient, ok := dl.paintTransform.Invert()
+	if !ok || len(g.Stops) == 0 {
+		// Paints nothing.
+		dl.opCodes = append(dl.opCodes, DRAW_OP_COLOR)
+		dl.bytes = append(dl.bytes, 0, 0, 0, 0)
+		return
+	}
+	o := dl.current().opacity
+	dl.opCodes = append(dl.opCodes, DRAW_OP_PAINT)
+	dl.integers = append(dl.integers, uint32(g.Kind), uint32(g.Spread), uint32(len(g.Stops)))
+	m := toGradient
+	dl.floats = append(dl.floats, m.A, m.B, m.C, m.D, m.E, m.F,
+		g.P0.X, g.P0.Y, g.P1.X, g.P1.Y, g.R0, g.R1, g.StartAngle, g.EndAngle)
+	for _, s := range g.Stops {
+		dl.floats = append(dl.floats, s.Offset)
+		dl.bytes = append(dl.bytes, s.Color.R, s.Color.G, s.Color.B, uint8(float32(s.Color.A)*o+0.5))
+	}
+}
+
+// paint is a decoded DRAW_OP_PAINT or DRAW_OP_IMAGE. For an image,
+// toGradient goes to the image's coordinates.
+type paint struct {
+	kind, spread       int
+	toGradient         Matrix
+	p0, p1             Pointf
+	r0, r1, start, end float32
+	offsets            []float32
+	colors             []color.RGBA
+
+	image     *Image
+	filter    int
+	repl      bool
+	opacity   float32
+	luminance bool
+}
+
+// paintAt decodes the DRAW_OP_PAINT whose data starts at the given indices
+// and returns the indices after it.
+func (dl *DisplayList) paintAt(integer, float, byte int) (p *paint, integers, floats, bytes int) {
+	p = &paint{kind: int(dl.integers[integer]), spread: int(dl.integers[integer+1])}
+	n := int(dl.integers[integer+2])
+	f := dl.floats[float : float+14+n]
+	p.toGradient = Matrix{f[0], f[1], f[2], f[3], f[4], f[5]}
+	p.p0, p.p1 = Pointf{f[6], f[7]}, Pointf{f[8], f[9]}
+	p.r0, p.r1, p.start, p.end = f[10], f[11], f[12], f[13]
+	p.offsets = f[14:]
+	b := dl.bytes[byte : byte+4*n]
+	for i := 0; i < n; i++ {
+		p.colors = append(p.colors, color.RGBA{b[4*i], b[4*i+1], b[4*i+2], b[4*i+3]})
+	}
+	return p, integer + 3, float + 14 + n, byte + 4*n
+}
+
+// at returns the color, not premultiplied, of the paint at v in viewport
+// coordinates.
+func (p *paint) at(v Pointf) color.RGBA {
+	var c [4]float32
+	if p.kind == paintImage {
+		c = p.sample(p.toGradient.Transform(v))
+		for i := range c {
+			c[i] *= p.opacity
+		}
+	} else {
+		c = p.gradientAt(p.toGradient.Transform(v))
+	}
+	if c[3] <= 0 {
+		return color.RGBA{}
+	}
+	unit := func(v float32) uint8 {
+		return uint8(MaxF(0, MinF(v, 1))*255 + 0.5)
+	}
+	return color.RGBA{unit(c[0] / c[3]), unit(c[1] / c[3]), unit(c[2] / c[3]), unit(c[3])}
+}
+
+// gradientAt returns the premultiplied color of the gradient at q in its
+// coordinates. Radial gradients leave transparent the points no circle
+// passes through.
+func (p *paint) gradientAt(q Pointf) [4]float32 {
+	t, ok := p.offset(q)
+	if !ok {
+		return [4]float32{}
+	}
+	switch p.spread {
+	case SPREAD_REPEAT:
+		t -= float32(math.Floor(float64(t)))
+	case SPREAD_REFLECT:
+		t -= 2 * float32(math.Floor(float64(t/2)))
+		if t > 1 {
+			t = 2 - t
+		}
+	}
+
+	// Premultiplied, as floats.
+	premul := func(c color.RGBA) [4]float32 {
+		a := float32(c.A) / 255
+		return [4]float32{float32(c.R) / 255 * a, float32(c.G) / 255 * a, float32(c.B) / 255 * a, a}
+	}
+	n := len(p.offsets)
+	c := premul(p.colors[n-1])
+	if t <= p.offsets[0] {
+		c = premul(p.colors[0])
+	} else {
+		for i := 0; i+1 < n; i++ {
+			if t < p.offsets[i+1] {
+				f := (t - p.offsets[i]) / (p.offsets[i+1] - p.offsets[i])
+				c0, c1 := premul(p.colors[i]), premul(p.colors[i+1])
+				for j := range c {
+					c[j] = c0[j] + (c1[j]-c0[j])*f
+				}
+				break
+			}
+		}
+	}
+	return c
+}
+
+// offset returns how far along the gradient, before spreading, q in the
+// gradient's coordinates is. It returns false if the gradient doesn't
+// reach q.
+func (p *paint) offset(q Pointf) (float32, bool) {
+	switch p.kind {
+	case GRADIENT_LINEAR:
+		d := p.p1.Sub(p.p0)
+		l2 := dot(d, d)
+		if l2 == 0 {
+			return 0, true
+		}
+		return dot(q.Sub(p.p0), d) / l2, true
+	case GRADIENT_SWEEP:
+		sweep := float64(p.end - p.start)
+		if sweep == 0 {
+			return 0, true
+		}
+		a := math.Mod(float64(angle(q.Sub(p.p0))-p.start), 2*math.Pi)
+		if a < 0 {
+			a += 2 * math.Pi
+		}
+		return float32(a / sweep), true
+	case GRADIENT_RADIAL:
+		// The largest t with r(t) = r0 + t(r1-r0) not negative and q on the
+		// circle of radius r(t) around c(t) = p0 + t(p1-p0), solving
+		// a t² - 2b t + c = 0.
+		cd, pd := p.p1.Sub(p.p0), q.Sub(p.p0)
+		dr := float64(p.r1 - p.r0)
+		r0 := float64(p.r0)
+		a := float64(dot(cd, cd)) - dr*dr
+		b := float64(dot(pd, cd)) + r0*dr
+		c := float64(dot(pd, pd)) - r0*r0
+		var ts []float64
+		if math.Abs(a) < 1e-6 {
+			if b == 0 {
+				return 0, false
+			}
+			ts = []float64{c / (2 * b)}
+		} else {
+			disc := b*b - a*c
+			if disc < 0 {
+				return 0, false
+			}
+			s := math.Sqrt(disc)
+			ts = []float64{(b + s) / a, (b - s) / a}
+			if ts[1] > ts[0] {
+				ts[0], ts[1] = ts[1], ts[0]
+			}
+		}
+		for _, t := range ts {
+			if r0+t*dr >= 0 {
+				return float32(t), true
+			}
+		}
+		return 0, false
+	}
+	return 0, true
+}
+
+type colorStops []ColorStop
+
+func (a colorStops) Len() int           { return len(a) }
+func (a colorStops) Less(i, j int) bool { return a[i].Offset < a[j].Offset }
+func (a colorStops) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// The GLSL for paints, included by the fragment shaders. paint(p) is the
+// color, not premultiplied, at p in viewport coordinates. It is formatted
+// with the GRADIENT_ and SPREAD_ constants, MAX_GRADIENT_STOPS and the
+// image kind.
+const paintShaderFormat = `
+#define GRADIENT_LINEAR %d
+#define GRADIENT_RADIAL %d
+#define GRADIENT_SWEEP %d
+#define SPREAD_REPEAT %d
+#define SPREAD_REFLECT %d
+#define MAX_GRADIENT_STOPS %d
+#define PAINT_IMAGE %d
+
+uniform vec4 u_Color;
+uniform int u_PaintKind;
+uniform int u_Spread;
+uniform int u_NumStops;
+// The rows of the transform from the viewport to the gradient or image.
+uniform vec3 u_ToGradientX;
+uniform vec3 u_ToGradientY;
+// p0 and p1, then r0, r1, the start angle and the end angle.
+uniform vec4 u_Points;
+uniform vec4 u_Radii;
+uniform float u_Offsets[MAX_GRADIENT_STOPS];
+uniform vec4 u_Colors[MAX_GRADIENT_STOPS];
+// The image's texture, its top left corner and size, whether it tiles and
+// the opacity to draw it with.
+uniform sampler2D u_Image;
+uniform vec4 u_ImageRect;
+uniform int u_Repl;
+uniform float u_Opacity;
+
+// See paint.offset. Returns -1e30 where the gradient doesn't reach.
+float gradientOffset(vec2 q)
+{
+    vec2 p0 = u_Points.xy;
+    vec2 p1 = u_Points.zw;
+    if (u_PaintKind == GRADIENT_LINEAR) {
+        vec2 d = p1 - p0;
+        float l2 = dot(d, d);
+        return l2 == 0.0 ? 0.0 : dot(q - p0, d) / l2;
+    }
+    if (u_PaintKind == GRADIENT_SWEEP) {
+        float sweep = u_Radii.w - u_Radii.z;
+        if (sweep == 0.0) {
+            return 0.0;
+        }
+        vec2 v = q - p0;
+        float a = mod(atan(v.y, v.x) - u_Radii.z, 6.283185307179586);
+        return a / sweep;
+    }
+    vec2 cd = p1 - p0;
+    vec2 pd = q - p0;
+    float r0 = u_Radii.x;
+    float dr = u_Radii.y - r0;
+    float a = dot(cd, cd) - dr * dr;
+    float b = dot(pd, cd) + r0 * dr;
+    float c = dot(pd, pd) - r0 * r0;
+    if (abs(a) < 1e-6) {
+        if (b == 0.0) {
+            return -1e30;
+        }
+        float t = c / (2.0 * b);
+        return r0 + t * dr >= 0.0 ? t : -1e30;
+    }
+    float disc = b * b - a * c;
+    if (disc < 0.0) {
+        return -1e30;
+    }
+    float s = sqrt(disc);
+    float t0 = max((b + s) / a, (b - s) / a);
+    float t1 = min((b + s) / a, (b - s) / a);
+    if (r0 + t0 * dr >= 0.0) {
+        return t0;
+    }
+    return r0 + t1 * dr >= 0.0 ? t1 : -1e30;
+}
+
+vec4 paint(vec2 p)
+{
+    if (u_PaintKind == 0) {
+        return u_Color;
+    }
+    vec3 v = vec3(p, 1.0);
+    vec2 q = vec2(dot(u_ToGradientX, v), dot(u_ToGradientY, v));
+    if (u_PaintKind == PAINT_IMAGE) {
+        vec2 uv = (q - u_ImageRect.xy) / u_ImageRect.zw;
+        vec4 c = texture(u_Image, uv) * u_Opacity;
+        if (u_Repl == 0 && (any(lessThan(uv, vec2(0.0))) || any(greaterThanEqual(uv, vec2(1.0))))) {
+            return vec4(0.0);
+        }
+        return c.a <= 0.0 ? vec4(0.0) : vec4(c.rgb / c.a, c.a);
+    }
+    float t = gradientOffset(q);
+    if (t < -1e29) {
+        return vec4(0.0);
+    }
+    if (u_Spread == SPREAD_REPEAT) {
+        t = fract(t);
+    } else if (u_Spread == SPREAD_REFLECT) {
+        t = mod(t, 2.0);
+        if (t > 1.0) {
+            t = 2.0 - t;
+        }
+    }
+    vec4 c = u_Colors[u_NumStops - 1];
+    c.rgb *= c.a;
+    if (t <= u_Offsets[0]) {
+        c = vec4(u_Colors[0].rgb * u_Colors[0].a, u_Colors[0].a);
+    } else {
+        for (int i = 0; i + 1 < u_NumStops; i++) {
+            if (t < u_Offsets[i + 1]) {
+                float f = (t - u_Offsets[i]) / (u_Offsets[i + 1] - u_Offsets[i]);
+                vec4 c0 = vec4(u_Colors[i].rgb * u_Colors[i].a, u_Colors[i].a);
+                vec4 c1 = vec4(u_Colors[i + 1].rgb * u_Colors[i + 1].a, u_Colors[i + 1].a);
+                c = mix(c0, c1, f);
+                break;
+            }
+        }
+    }
+    return c.a <= 0.0 ? vec4(0.0) : vec4(c.rgb / c.a, c.a);
+}
+`
+
+// paintShader returns the GLSL for paints.
+func paintShader() string {
+	return fmt.Sprintf(paintShaderFormat, GRADIENT_LINEAR, GRADIENT_RADIAL, GRADIENT_SWEEP,
+		SPREAD_REPEAT, SPREAD_REFLECT, MAX_GRADIENT_STOPS, paintImage)
+}
+
+// DoPaint makes the paint of a DRAW_OP_PAINT current.
+func (dl *DisplayList) DoPaint(program *gl.Program) {
+	dl.cur_paint, dl.cur_integer, dl.cur_float, dl.cur_byte = dl.paintAt(dl.cur_integer, dl.cur_float, dl.cur_byte)
+	dl.setPaintUniforms(*program)
+	CheckForGLErrors()
+}
+
+// setPaintUniforms passes the current color or paint to program, which
+// includes the paint shader.
+func (dl *DisplayList) setPaintUniforms(program gl.Program) {
+	c := dl.cur_color
+	program.GetUniformLocation("u_Color").Uniform4f(c[0], c[1], c[2], c[3])
+	program.GetUniformLocation("u_Height").Uniform1f(dl.cur_height)
+	p := dl.cur_paint
+	if p == nil {
+		program.GetUniformLocation("u_PaintKind").Uniform1i(0)
+		return
+	}
+	program.GetUniformLocation("u_PaintKind").Uniform1i(p.kind)
+	m := p.toGradient
+	program.GetUniformLocation("u_ToGradientX").Uniform3f(m.A, m.C, m.E)
+	program.GetUniformLocation("u_ToGradientY").Uniform3f(m.B, m.D, m.F)
+	if p.kind == paintImage {
+		r := p.image.pix.Rect
+		program.GetUniformLocation("u_Image").Uniform1i(0)
+		program.GetUniformLocation("u_ImageRect").Uniform4f(float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy()))
+		repl := 0
+		if p.repl {
+			repl = 1
+		}
+		program.GetUniformLocation("u_Repl").Uniform1i(repl)
+		program.GetUniformLocation("u_Opacity").Uniform1f(p.opacity)
+		return
+	}
+	program.GetUniformLocation("u_Spread").Uniform1i(p.spread)
+	program.GetUniformLocation("u_NumStops").Uniform1i(len(p.offsets))
+	program.GetUniformLocation("u_Points").Uniform4f(p.p0.X, p.p0.Y, p.p1.X, p.p1.Y)
+	program.GetUniformLocation("u_Radii").Uniform4f(p.r0, p.r1, p.start, p.end)
+	program.GetUniformLocation("u_Offsets").Uniform1fv(len(p.offsets), p.offsets)
+	colors := make([]float32, 0, 4*len(p.colors))
+	for _, c := range p.colors {
+		colors = append(colors, float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, float32(c.A)/255)
+	}
+	program.GetUniformLocation("u_Colors").Uniform4fv(len(p.colors), colors)
+}
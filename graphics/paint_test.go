@@ -0,0 +1,138 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+var (
+	black      = color.RGBA{0, 0, 0, 0xff}
+	blackToRed = []ColorStop{{0, black}, {1, red}}
+)
+
+// paintSquare rasterizes the 20 by 20 pixel square painted with g.
+func paintSquare(g *Gradient) *image.RGBA {
+	dl := &DisplayList{}
+	dl.SetPaint(g)
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 20}, {0, 20}}})
+	return rasterize(dl)
+}
+
+func TestLinearGradient(t *testing.T) {
+	img := paintSquare(NewLinearGradient(Pointf{0, 0}, Pointf{20, 0}, blackToRed...))
+	AssertRGBA(t, color.RGBA{6, 0, 0, 0xff}, img, 0, 5)
+	AssertRGBA(t, color.RGBA{121, 0, 0, 0xff}, img, 9, 5)
+	AssertRGBA(t, color.RGBA{121, 0, 0, 0xff}, img, 9, 15)
+	AssertRGBA(t, color.RGBA{249, 0, 0, 0xff}, img, 19, 5)
+}
+
+func TestGradientSpread(t *testing.T) {
+	g := NewLinearGradient(Pointf{5, 0}, Pointf{15, 0}, blackToRed...)
+	img := paintSquare(g)
+	AssertRGBA(t, black, img, 2, 5)
+	AssertRGBA(t, red, img, 17, 5)
+
+	g = NewLinearGradient(Pointf{0, 0}, Pointf{10, 0}, blackToRed...)
+	g.Spread = SPREAD_REPEAT
+	img = paintSquare(g)
+	AssertRGBA(t, color.RGBA{64, 0, 0, 0xff}, img, 2, 5)
+	AssertRGBA(t, color.RGBA{64, 0, 0, 0xff}, img, 12, 5)
+
+	g.Spread = SPREAD_REFLECT
+	img = paintSquare(g)
+	AssertRGBA(t, color.RGBA{64, 0, 0, 0xff}, img, 2, 5)
+	AssertRGBA(t, color.RGBA{191, 0, 0, 0xff}, img, 12, 5)
+}
+
+func TestGradientStops(t *testing.T) {
+	// Out of order, and interpolated premultiplied so the fade to
+	// transparent blue stays red.
+	g := NewLinearGradient(Pointf{0, 0}, Pointf{20, 0},
+		ColorStop{1, color.RGBA{0, 0, 0xff, 0}}, ColorStop{0, red})
+	img := paintSquare(g)
+	AssertRGBA(t, color.RGBA{249, 0, 0, 249}, img, 0, 5)
+	AssertRGBA(t, color.RGBA{134, 0, 0, 134}, img, 9, 5)
+	AssertRGBA(t, color.RGBA{6, 0, 0, 6}, img, 19, 5)
+}
+
+func TestRadialGradient(t *testing.T) {
+	c := Pointf{10, 10}
+	img := paintSquare(NewRadialGradient(c, 0, c, 10, blackToRed...))
+	AssertRGBA(t, color.RGBA{18, 0, 0, 0xff}, img, 10, 10)
+	AssertRGBA(t, color.RGBA{141, 0, 0, 0xff}, img, 15, 10)
+	AssertRGBA(t, color.RGBA{141, 0, 0, 0xff}, img, 4, 10)
+	AssertRGBA(t, red, img, 0, 0)
+}
+
+func TestTwoPointConicalGradient(t *testing.T) {
+	// Circles of the same radius sweep out a band, leaving the rest
+	// unpainted.
+	img := paintSquare(NewRadialGradient(Pointf{5, 10}, 2, Pointf{15, 10}, 2, blackToRed...))
+	AssertRGBA(t, color.RGBA{190, 0, 0, 0xff}, img, 10, 10)
+	AssertRGBA(t, color.RGBA{}, img, 10, 3)
+	AssertRGBA(t, color.RGBA{}, img, 10, 17)
+}
+
+func TestSweepGradient(t *testing.T) {
+	img := paintSquare(NewSweepGradient(Pointf{10, 10}, 0, 2*math.Pi, blackToRed...))
+	AssertRGBA(t, color.RGBA{4, 0, 0, 0xff}, img, 15, 10)
+	AssertRGBA(t, color.RGBA{60, 0, 0, 0xff}, img, 10, 15)
+	AssertRGBA(t, color.RGBA{123, 0, 0, 0xff}, img, 5, 10)
+	AssertRGBA(t, color.RGBA{195, 0, 0, 0xff}, img, 10, 4)
+}
+
+func TestGradientTransform(t *testing.T) {
+	g := NewLinearGradient(Pointf{0, 0}, Pointf{20, 0}, blackToRed...)
+	g.Transform = Rotate(math.Pi / 2)
+	img := paintSquare(g)
+	AssertRGBA(t, color.RGBA{121, 0, 0, 0xff}, img, 3, 9)
+
+	// Positioned in the drawing coordinates current at SetPaint.
+	dl := &DisplayList{}
+	dl.Transform(Translate(Pointf{5, 0}))
+	dl.SetPaint(NewLinearGradient(Pointf{0, 0}, Pointf{10, 0}, blackToRed...))
+	dl.Transform(Translate(Pointf{5, 0}))
+	dl.DrawQuads([][4]Pointf{{{-10, 0}, {10, 0}, {10, 20}, {-10, 20}}})
+	img = rasterize(dl)
+	AssertRGBA(t, color.RGBA{64, 0, 0, 0xff}, img, 7, 5)
+}
+
+func TestPaintOpacityAndColor(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetOpacity(0.5)
+	dl.SetPaint(NewLinearGradient(Pointf{0, 0}, Pointf{20, 0}, blackToRed...))
+	dl.DrawQuads([][4]Pointf{{{0, 0}, {20, 0}, {20, 10}, {0, 10}}})
+	// SetColor replaces the paint.
+	dl.SetColor(red)
+	dl.DrawQuads([][4]Pointf{{{0, 10}, {20, 10}, {20, 20}, {0, 20}}})
+	img := rasterize(dl)
+	AssertRGBA(t, color.RGBA{61, 0, 0, 0x80}, img, 9, 5)
+	AssertRGBA(t, color.RGBA{0x80, 0, 0, 0x80}, img, 9, 15)
+
+	// And survives encoding.
+	b, err := dl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o DisplayList
+	if err := o.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	AssertTrue(t, dl.Equal(&o))
+}
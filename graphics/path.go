@@ -0,0 +1,173 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"errors"
+	"math"
+)
+
+// Path is a sequence of subpaths, each a connected run of straight lines and
+// Arcs. A straight line is an Arc whose depth is 0.
+type Path struct {
+	subpaths []subpath
+}
+
+type subpath struct {
+	start    Pointf
+	segments []*Arc
+	closed   bool
+}
+
+// Empty reports whether the Path has no segments.
+func (p *Path) Empty() bool {
+	for _, s := range p.subpaths {
+		if len(s.segments) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Current returns the end of the last subpath. ok is false if there isn't
+// one.
+func (p *Path) Current() (pt Pointf, ok bool) {
+	if len(p.subpaths) == 0 {
+		return pt, false
+	}
+	return p.subpaths[len(p.subpaths)-1].end(), true
+}
+
+// MoveTo starts a new subpath at pt.
+func (p *Path) MoveTo(pt Pointf) {
+	if n := len(p.subpaths); n > 0 && len(p.subpaths[n-1].segments) == 0 {
+		p.subpaths[n-1] = subpath{start: pt}
+		return
+	}
+	p.subpaths = append(p.subpaths, subpath{start: pt})
+}
+
+// LineTo adds a straight line from the current point to pt.
+func (p *Path) LineTo(pt Pointf) {
+	p.ArcTo(pt, 0)
+}
+
+// ArcTo adds the Arc of depth d from the current point to pt. See Arc.d.
+func (p *Path) ArcTo(pt Pointf, d float32) {
+	s := p.last(pt)
+	s.segments = append(s.segments, NewArc(s.end(), pt, d))
+}
+
+// CircularArc adds the arc of the circle with centre c and radius r that
+// starts at angle start and turns through sweep radians, as Arcs from
+// ArcsFromCenter. It is joined to the current point by a straight line.
+func (p *Path) CircularArc(c Pointf, r, start, sweep float32) error {
+	arcs, err := ArcsFromCenter(c, r, start, sweep)
+	if err != nil {
+		return err
+	}
+	s := p.last(arcs[0].P0())
+	if s.end() != arcs[0].P0() {
+		s.segments = append(s.segments, NewArc(s.end(), arcs[0].P0(), 0))
+	}
+	s.segments = append(s.segments, arcs...)
+	return nil
+}
+
+// Close joins the end of the current subpath to its start. Drawing resumes
+// from the start.
+func (p *Path) Close() error {
+	if len(p.subpaths) == 0 {
+		return errors.New("graphics: closing an empty Path")
+	}
+	s := &p.subpaths[len(p.subpaths)-1]
+	if s.end() != s.start {
+		s.segments = append(s.segments, NewArc(s.end(), s.start, 0))
+	}
+	s.closed = true
+	p.subpaths = append(p.subpaths, subpath{start: s.start})
+	return nil
+}
+
+// Bounds returns the smallest Rectanglef enclosing the Path's segments.
+func (p *Path) Bounds() Rectanglef {
+	var r Rectanglef
+	first := true
+	for _, s := range p.subpaths {
+		for _, a := range s.segments {
+			if first {
+				r = a.Bounds()
+				first = false
+			} else {
+				r = r.Union(a.Bounds())
+			}
+		}
+	}
+	return r
+}
+
+// last returns the subpath being added to, starting one at pt if there
+// isn't one.
+func (p *Path) last(pt Pointf) *subpath {
+	if len(p.subpaths) == 0 {
+		p.MoveTo(pt)
+	}
+	return &p.subpaths[len(p.subpaths)-1]
+}
+
+func (s *subpath) end() Pointf {
+	if n := len(s.segments); n > 0 {
+		return s.segments[n-1].P1()
+	}
+	return s.start
+}
+
+// Flatten returns the subpaths of p as closed polygons, with each Arc
+// replaced by straight lines no further than tolerance from it.
+func (p *Path) Flatten(tolerance float32) [][]Pointf {
+	var ps [][]Pointf
+	for _, s := range p.subpaths {
+		if len(s.segments) == 0 {
+			continue
+		}
+		poly := []Pointf{s.start}
+		for _, a := range s.segments {
+			poly = a.flatten(poly, tolerance)
+		}
+		ps = append(ps, poly)
+	}
+	return ps
+}
+
+// flatten appends the points after P0 of straight lines no further than
+// tolerance from the Arc to ps.
+func (arc *Arc) flatten(ps []Pointf, tolerance float32) []Pointf {
+	if arc.d == 0 {
+		return append(ps, arc.P1())
+	}
+	c, r := arc.Center(), arc.Radius()
+	start, sweep := arc.Angles()
+	// A chord that turns through theta is r * (1 - cos(theta/2)) from the
+	// arc.
+	step := 2 * math.Acos(math.Max(-1, 1-float64(tolerance/r)))
+	n := 1
+	if step > 0 {
+		n = int(math.Ceil(math.Abs(float64(sweep)) / step))
+	}
+	for i := 1; i < n; i++ {
+		ps = append(ps, c.Add(polar(r, start+sweep*float32(i)/float32(n))))
+	}
+	return append(ps, arc.P1())
+}
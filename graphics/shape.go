@@ -0,0 +1,343 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/gl"
+)
+
+// The kinds of DRAW_OP_SHAPE. Each is drawn from its signed distance
+// function: the distance from a point to the shape's edge, negative inside.
+const (
+	SHAPE_ARC = iota
+	SHAPE_CIRCLE
+	SHAPE_PIE
+	SHAPE_ROUNDED_RECT
+	SHAPE_SEGMENT
+	SHAPE_BAND
+)
+
+// A DRAW_OP_SHAPE has a kind in the integers and SHAPE_FLOATS floats: the
+// bounding quad in viewport coordinates, the transform from the viewport to
+// the shape's coordinates, the size of a pixel in the shape's coordinates
+// and SHAPE_PARAMS parameters.
+//
+// The parameters of the round shapes are the centre, the radius, the unit
+// vector through the middle of the sweep, the sine and cosine of half the
+// sweep and the half width of the stroke, 0 when filling. A rounded rect
+// has its centre, half size, corner radius, two unused values and the
+// stroke half width. A band is the part of a circle's stroke with butt
+// ends that strokes an arc.
+const (
+	SHAPE_PARAMS = 8
+	SHAPE_FLOATS = 8 + 6 + 1 + SHAPE_PARAMS
+)
+
+// FillCircle fills the circle with centre c and radius r.
+func (dl *DisplayList) FillCircle(c Pointf, r float32) {
+	dl.roundShape(SHAPE_CIRCLE, c, r, 0, 2*math.Pi, 0)
+}
+
+// StrokeCircle strokes the circle with centre c and radius r with a line
+// width wide.
+func (dl *DisplayList) StrokeCircle(c Pointf, r, width float32) {
+	if width > 0 {
+		dl.roundShape(SHAPE_CIRCLE, c, r, 0, 2*math.Pi, width/2)
+	}
+}
+
+// FillPie fills the wedge of the circle with centre c and radius r that
+// starts at angle start and turns through sweep radians.
+func (dl *DisplayList) FillPie(c Pointf, r, start, sweep float32) {
+	dl.roundShape(SHAPE_PIE, c, r, start, sweep, 0)
+}
+
+// StrokePie strokes the outline of the wedge drawn by FillPie.
+func (dl *DisplayList) StrokePie(c Pointf, r, start, sweep, width float32) {
+	if width > 0 {
+		dl.roundShape(SHAPE_PIE, c, r, start, sweep, width/2)
+	}
+}
+
+// FillArc fills the region between a and its chord.
+func (dl *DisplayList) FillArc(a *Arc) {
+	if a.d == 0 {
+		return
+	}
+	start, sweep := a.Angles()
+	dl.roundShape(SHAPE_SEGMENT, a.Center(), a.Radius(), start, sweep, 0)
+}
+
+// StrokeArc strokes a with a line width wide and round ends.
+func (dl *DisplayList) StrokeArc(a *Arc, width float32) {
+	if width <= 0 {
+		return
+	}
+	if a.d == 0 {
+		dl.strokeLine(a.P0(), a.P1(), width)
+		return
+	}
+	start, sweep := a.Angles()
+	dl.roundShape(SHAPE_ARC, a.Center(), a.Radius(), start, sweep, width/2)
+}
+
+// StrokeCircularArc strokes the arc of the circle with centre c and radius
+// r that starts at angle start and turns through sweep radians. Unlike an
+// Arc, it may sweep up to a whole circle.
+func (dl *DisplayList) StrokeCircularArc(c Pointf, r, start, sweep, width float32) {
+	if width > 0 {
+		dl.roundShape(SHAPE_ARC, c, r, start, sweep, width/2)
+	}
+}
+
+// FillRoundedRect fills r with its corners rounded to radius.
+func (dl *DisplayList) FillRoundedRect(r Rectanglef, radius float32) {
+	dl.roundedRect(r, radius, 0)
+}
+
+// StrokeRoundedRect strokes the outline of the shape drawn by
+// FillRoundedRect with a line width wide.
+func (dl *DisplayList) StrokeRoundedRect(r Rectanglef, radius, width float32) {
+	if width > 0 {
+		dl.roundedRect(r, radius, width/2)
+	}
+}
+
+// strokeLine strokes the segment from p0 to p1 as a rounded rect.
+func (dl *DisplayList) strokeLine(p0, p1 Pointf, width float32) {
+	hw := width / 2
+	dl.Save()
+	dl.Transform(Translate(p0).Mul(Rotate(angle(p1.Sub(p0)))))
+	dl.roundedRect(Rect(-hw, -hw, length(p1.Sub(p0))+hw, hw), hw, 0)
+	dl.Restore()
+}
+
+func (dl *DisplayList) roundShape(kind uint32, c Pointf, r, start, sweep, hw float32) {
+	if r <= 0 || sweep == 0 {
+		return
+	}
+	sweep = MaxF(-2*math.Pi, MinF(sweep, 2*math.Pi))
+	u := polar(1, start+sweep/2)
+	s, cos := math.Sincos(float64(AbsF(sweep) / 2))
+	e := r + hw
+	dl.drawShape(kind, Rect(c.X-e, c.Y-e, c.X+e, c.Y+e),
+		[SHAPE_PARAMS]float32{c.X, c.Y, r, u.X, u.Y, float32(s), float32(cos), hw})
+}
+
+func (dl *DisplayList) roundedRect(r Rectanglef, radius, hw float32) {
+	r = r.Canon()
+	if r.Empty() {
+		return
+	}
+	c := r.Min.Add(r.Max).Mul(0.5)
+	b := r.Size().Mul(0.5)
+	radius = MaxF(0, MinF(radius, MinF(b.X, b.Y)))
+	dl.drawShape(SHAPE_ROUNDED_RECT, Rect(r.Min.X-hw, r.Min.Y-hw, r.Max.X+hw, r.Max.Y+hw),
+		[SHAPE_PARAMS]float32{c.X, c.Y, b.X, b.Y, radius, 0, 0, hw})
+}
+
+// drawShape records a DRAW_OP_SHAPE covering bounds, in the current drawing
+// coordinates, with room for the anti-aliased edge.
+func (dl *DisplayList) drawShape(kind uint32, bounds Rectanglef, params [SHAPE_PARAMS]float32) {
+	m := dl.current().transform
+	toLocal, ok := m.Invert()
+	if !ok {
+		return
+	}
+	pixel := PixelSize(m)
+	b := Rect(bounds.Min.X-pixel, bounds.Min.Y-pixel, bounds.Max.X+pixel, bounds.Max.Y+pixel)
+
+	dl.opCodes = append(dl.opCodes, DRAW_OP_SHAPE)
+	dl.integers = append(dl.integers, kind)
+	for _, p := range [...]Pointf{b.Min, {b.Max.X, b.Min.Y}, b.Max, {b.Min.X, b.Max.Y}} {
+		p = m.Transform(p)
+		dl.W = MaxF(dl.W, p.X)
+		dl.H = MaxF(dl.H, p.Y)
+		dl.floats = append(dl.floats, p.X, p.Y)
+	}
+	dl.floats = append(dl.floats, toLocal.A, toLocal.B, toLocal.C, toLocal.D, toLocal.E, toLocal.F, pixel)
+	dl.floats = append(dl.floats, params[:]...)
+}
+
+// shapeDistance returns the signed distance from p to the shape of kind
+// with parameters k. shapeFragmentShader computes the same thing.
+func shapeDistance(kind uint32, p Pointf, k []float32) float32 {
+	q := p.Sub(Pointf{k[0], k[1]})
+	var d float32
+	if kind == SHAPE_ROUNDED_RECT {
+		r := k[4]
+		ex := AbsF(q.X) - k[2] + r
+		ey := AbsF(q.Y) - k[3] + r
+		d = length(Pointf{MaxF(ex, 0), MaxF(ey, 0)}) + MinF(MaxF(ex, ey), 0) - r
+	} else {
+		// The round shapes are symmetric about the middle of their sweep: y
+		// is along it and x across it.
+		r, s, c := k[2], k[5], k[6]
+		a := Pointf{AbsF(q.X*k[4] - q.Y*k[3]), q.X*k[3] + q.Y*k[4]}
+		l := length(a) - r
+		switch kind {
+		case SHAPE_CIRCLE:
+			d = l
+		case SHAPE_PIE:
+			t := MaxF(0, MinF(a.X*s+a.Y*c, r))
+			m := length(a.Sub(Pointf{s * t, c * t}))
+			if c*a.X-s*a.Y < 0 {
+				m = -m
+			}
+			d = MaxF(l, m)
+		case SHAPE_SEGMENT:
+			d = MaxF(l, r*c-a.Y)
+		case SHAPE_ARC:
+			// Past the ends, the distance is to the nearer end.
+			if c*a.X > s*a.Y {
+				d = length(a.Sub(Pointf{s * r, c * r}))
+			} else {
+				d = AbsF(l)
+			}
+			return d - k[7]
+		case SHAPE_BAND:
+			// Inside, the ends are the rays from the centre through the
+			// ends of the arc.
+			t := MaxF(0, MinF(a.X*s+a.Y*c, r+k[7]))
+			m := length(a.Sub(Pointf{s * t, c * t}))
+			if c*a.X-s*a.Y < 0 {
+				m = -m
+			}
+			return MaxF(AbsF(l)-k[7], m)
+		}
+	}
+	if k[7] > 0 {
+		d = AbsF(d) - k[7]
+	}
+	return d
+}
+
+// shapeCoverage returns the fraction of a pixel of size pixel covered by
+// a shape whose edge is d from the pixel's centre.
+func shapeCoverage(d, pixel float32) float32 {
+	return MaxF(0, MinF(0.5-d/pixel, 1))
+}
+
+// The fragment shader for DRAW_OP_SHAPE. It is formatted with the SHAPE_
+// constants and the paint shader.
+const shapeFragmentShader = `
+#version 400
+
+#define SHAPE_ARC %d
+#define SHAPE_CIRCLE %d
+#define SHAPE_PIE %d
+#define SHAPE_ROUNDED_RECT %d
+#define SHAPE_SEGMENT %d
+#define SHAPE_BAND %d
+%s
+uniform int u_Kind;
+uniform vec4 u_Params0;
+uniform vec4 u_Params1;
+uniform float u_Pixel;
+uniform float u_Height;
+
+// The rows of the transform from the viewport to the shape's coordinates.
+uniform vec3 u_ToLocalX;
+uniform vec3 u_ToLocalY;
+
+out vec4 out_Color;
+
+// See shapeDistance.
+float shapeDistance(vec2 p)
+{
+    vec2 q = p - u_Params0.xy;
+    float d;
+    if (u_Kind == SHAPE_ROUNDED_RECT) {
+        float r = u_Params1.x;
+        vec2 e = abs(q) - u_Params0.zw + r;
+        d = length(max(e, 0.0)) + min(max(e.x, e.y), 0.0) - r;
+    } else {
+        float r = u_Params0.z;
+        vec2 u = vec2(u_Params0.w, u_Params1.x);
+        vec2 sc = u_Params1.yz;
+        vec2 a = vec2(abs(q.x * u.y - q.y * u.x), dot(q, u));
+        float l = length(a) - r;
+        if (u_Kind == SHAPE_CIRCLE) {
+            d = l;
+        } else if (u_Kind == SHAPE_PIE) {
+            float m = length(a - sc * clamp(dot(a, sc), 0.0, r));
+            d = max(l, sc.y * a.x - sc.x * a.y < 0.0 ? -m : m);
+        } else if (u_Kind == SHAPE_SEGMENT) {
+            d = max(l, r * sc.y - a.y);
+        } else if (u_Kind == SHAPE_BAND) {
+            float m = length(a - sc * clamp(dot(a, sc), 0.0, r + u_Params1.w));
+            return max(abs(l) - u_Params1.w, sc.y * a.x - sc.x * a.y < 0.0 ? -m : m);
+        } else {
+            d = sc.y * a.x > sc.x * a.y ? length(a - sc * r) : abs(l);
+            return d - u_Params1.w;
+        }
+    }
+    if (u_Params1.w > 0.0) {
+        d = abs(d) - u_Params1.w;
+    }
+    return d;
+}
+
+void main()
+{
+    // GL puts the origin at the bottom left.
+    vec3 p = vec3(gl_FragCoord.x, u_Height - gl_FragCoord.y, 1.0);
+    vec2 local = vec2(dot(u_ToLocalX, p), dot(u_ToLocalY, p));
+    float coverage = clamp(0.5 - shapeDistance(local) / u_Pixel, 0.0, 1.0);
+    vec4 c = paint(p.xy);
+    out_Color = vec4(c.rgb, c.a * coverage);
+}` // shapeFragmentShader
+
+// The program for DRAW_OP_SHAPE, made when first needed.
+var (
+	shapeProgram      gl.Program
+	shapeProgramReady bool
+)
+
+func useShapeProgram() gl.Program {
+	if !shapeProgramReady {
+		shapeProgram = createProgram(defaultVertexShader, fmt.Sprintf(shapeFragmentShader,
+			SHAPE_ARC, SHAPE_CIRCLE, SHAPE_PIE, SHAPE_ROUNDED_RECT, SHAPE_SEGMENT, SHAPE_BAND, paintShader()))
+		shapeProgramReady = true
+	}
+	shapeProgram.Use()
+	return shapeProgram
+}
+
+func (dl *DisplayList) DoShape(program *gl.Program) {
+	kind := dl.integers[dl.cur_integer]
+	dl.cur_integer++
+	f := dl.floats[dl.cur_float : dl.cur_float+SHAPE_FLOATS]
+	dl.cur_float += SHAPE_FLOATS
+
+	sp := useShapeProgram()
+	defer program.Use()
+	sp.GetUniformLocation("u_Viewport").Uniform2f(1/dl.cur_width, 1/dl.cur_height)
+	dl.setPaintUniforms(sp)
+	sp.GetUniformLocation("u_Kind").Uniform1i(int(kind))
+	sp.GetUniformLocation("u_ToLocalX").Uniform3f(f[8], f[10], f[12])
+	sp.GetUniformLocation("u_ToLocalY").Uniform3f(f[9], f[11], f[13])
+	sp.GetUniformLocation("u_Pixel").Uniform1f(f[14])
+	sp.GetUniformLocation("u_Params0").Uniform4f(f[15], f[16], f[17], f[18])
+	sp.GetUniformLocation("u_Params1").Uniform4f(f[19], f[20], f[21], f[22])
+
+	// The same triangles as DoQuads.
+	drawTriangles(&sp, []float32{
+		f[0], f[1], f[2], f[3], f[4], f[5],
+		f[0], f[1], f[4], f[5], f[6], f[7]})
+}
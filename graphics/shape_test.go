@@ -0,0 +1,143 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+var red = color.RGBA{0xff, 0, 0, 0xff}
+
+func rasterize(dl *DisplayList) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	dl.Rasterize(img)
+	return img
+}
+
+// AssertPartial asserts that the pixel at x, y is partly covered.
+func AssertPartial(t *testing.T, img *image.RGBA, x, y int) {
+	if a := img.RGBAAt(x, y).A; a == 0 || a == 0xff {
+		t.Errorf("pixel %d,%d: expected partial coverage, got alpha %d", x, y, a)
+	}
+}
+
+func TestFillCircle(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.FillCircle(Pointf{10, 10}, 5.25)
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 10, 10)
+	AssertRGBA(t, red, img, 14, 10)
+	AssertRGBA(t, color.RGBA{}, img, 3, 10)
+	AssertRGBA(t, color.RGBA{}, img, 14, 14)
+	// The edge passes 0.27 pixels from this pixel's centre.
+	AssertPartial(t, img, 15, 10)
+
+	// The same circle, scaled.
+	dl = &DisplayList{}
+	dl.SetColor(red)
+	dl.Transform(Scale(2, 2))
+	dl.FillCircle(Pointf{5, 5}, 2.625)
+	img = rasterize(dl)
+	AssertRGBA(t, red, img, 14, 10)
+	AssertPartial(t, img, 15, 10)
+	AssertRGBA(t, color.RGBA{}, img, 16, 10)
+}
+
+func TestStrokeCircle(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.StrokeCircle(Pointf{10, 10}, 6, 3)
+	img := rasterize(dl)
+
+	AssertRGBA(t, color.RGBA{}, img, 10, 10)
+	AssertRGBA(t, red, img, 16, 10)
+	AssertRGBA(t, red, img, 10, 3)
+	AssertRGBA(t, color.RGBA{}, img, 18, 10)
+}
+
+func TestFillPie(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.FillPie(Pointf{10, 10}, 8, 0, math.Pi/2)
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 13, 13)
+	AssertRGBA(t, red, img, 11, 16)
+	AssertRGBA(t, color.RGBA{}, img, 6, 13)
+	AssertRGBA(t, color.RGBA{}, img, 13, 6)
+	AssertRGBA(t, color.RGBA{}, img, 17, 17)
+}
+
+func TestArcShapes(t *testing.T) {
+	a, err := ArcFromCenter(Pointf{10, 10}, 6, 0, math.Pi/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.StrokeArc(a, 3)
+	img := rasterize(dl)
+	AssertRGBA(t, red, img, 14, 14)
+	AssertRGBA(t, red, img, 16, 10)
+	AssertRGBA(t, color.RGBA{}, img, 10, 10)
+	AssertRGBA(t, color.RGBA{}, img, 14, 5)
+
+	// Between the arc and its chord.
+	dl = &DisplayList{}
+	dl.SetColor(red)
+	dl.FillArc(a)
+	img = rasterize(dl)
+	AssertRGBA(t, red, img, 13, 13)
+	AssertRGBA(t, color.RGBA{}, img, 11, 11)
+	AssertRGBA(t, color.RGBA{}, img, 17, 17)
+
+	// A straight Arc is a line with round ends.
+	dl = &DisplayList{}
+	dl.SetColor(red)
+	dl.StrokeArc(NewArc(Pointf{2, 10}, Pointf{18, 10}, 0), 4)
+	dl.FillArc(NewArc(Pointf{2, 2}, Pointf{18, 2}, 0))
+	img = rasterize(dl)
+	AssertRGBA(t, red, img, 10, 9)
+	AssertRGBA(t, red, img, 1, 10)
+	AssertRGBA(t, red, img, 18, 10)
+	AssertPartial(t, img, 0, 10)
+	AssertRGBA(t, color.RGBA{}, img, 10, 12)
+	AssertRGBA(t, color.RGBA{}, img, 0, 7)
+	AssertRGBA(t, color.RGBA{}, img, 10, 2)
+}
+
+func TestRoundedRect(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.FillRoundedRect(Rect(2, 2, 18, 18), 6)
+	img := rasterize(dl)
+	AssertRGBA(t, color.RGBA{}, img, 2, 2)
+	AssertRGBA(t, red, img, 10, 2)
+	AssertRGBA(t, red, img, 10, 10)
+	AssertRGBA(t, red, img, 4, 6)
+
+	dl = &DisplayList{}
+	dl.SetColor(red)
+	dl.StrokeRoundedRect(Rect(2, 2, 18, 18), 6, 2)
+	img = rasterize(dl)
+	AssertRGBA(t, color.RGBA{}, img, 10, 10)
+	AssertRGBA(t, red, img, 10, 2)
+	AssertRGBA(t, color.RGBA{}, img, 2, 2)
+}
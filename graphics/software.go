@@ -0,0 +1,328 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/google/gojiraw/graphics/drawop"
+)
+
+// Rasterize draws the DisplayList into dst without GL. It produces the same
+// pixels as Draw: a pixel is covered when its centre is inside a primitive,
+// shapes are anti-aliased by their distance from the pixel's centre and
+// colors are blended source-over. Anti-aliased quads and fills cover each
+// pixel by the exact fraction of its area inside them, which Draw
+// approximates. dst's origin is the viewport's top
+// left corner.
+func (dl *DisplayList) Rasterize(dst *image.RGBA) {
+	dl.checkLayers("Rasterize")
+	sr := softwareRasterizer{dl: dl, dst: dst, clip: dst.Bounds(), op: drawop.SoverD}
+	// Cached layers skip their ops.
+	for ; sr.opCode < len(dl.opCodes); sr.opCode++ {
+		switch dl.opCodes[sr.opCode] {
+		case DRAW_OP_BEGIN_LAYER:
+			sr.doBeginLayer()
+		case DRAW_OP_CLIP:
+			sr.doClip()
+		case DRAW_OP_COLOR:
+			sr.doColor()
+		case DRAW_OP_END_LAYER:
+			sr.doEndLayer()
+		case DRAW_OP_IMAGE:
+			sr.doImage()
+		case DRAW_OP_MASKED:
+			sr.doMasked()
+		case DRAW_OP_PAINT:
+			sr.doPaint()
+		case DRAW_OP_PATH:
+			sr.doPath()
+		case DRAW_OP_QUADS:
+			sr.doQuads()
+		case DRAW_OP_SHAPE:
+			sr.doShape()
+		}
+	}
+}
+
+// softwareRasterizer holds the state of a Rasterize call.
+type softwareRasterizer struct {
+	dl  *DisplayList
+	dst *image.RGBA
+
+	opCode, integer, float, byte int
+
+	color color.RGBA
+	paint *paint
+	clip  image.Rectangle
+
+	// The mask, if any, and the op of a DRAW_OP_MASKED.
+	mask *paint
+	op   drawop.Drawop
+
+	// The layers begun and not yet ended.
+	layers []softwareLayer
+}
+
+func (sr *softwareRasterizer) doClip() {
+	dl := sr.dl
+	clipped := dl.integers[sr.integer] != 0
+	sr.integer++
+	f := dl.floats[sr.float : sr.float+4]
+	sr.float += 4
+
+	sr.clip = sr.dst.Bounds()
+	if clipped {
+		// Match glScissor, which truncates to whole pixels.
+		r := image.Rect(int(f[0]), int(f[1]), int(f[0])+int(f[2]-f[0]), int(f[1])+int(f[3]-f[1]))
+		sr.clip = sr.clip.Intersect(r)
+	}
+}
+
+func (sr *softwareRasterizer) doColor() {
+	b := sr.dl.bytes[sr.byte : sr.byte+4]
+	sr.byte += 4
+	sr.color = color.RGBA{b[0], b[1], b[2], b[3]}
+	sr.paint = nil
+}
+
+func (sr *softwareRasterizer) doPaint() {
+	sr.paint, sr.integer, sr.float, sr.byte = sr.dl.paintAt(sr.integer, sr.float, sr.byte)
+}
+
+// colorAt returns the color of the current paint, in the mask if there is
+// one, at the centre of the pixel at x, y.
+func (sr *softwareRasterizer) colorAt(x, y int) color.RGBA {
+	v := Pointf{float32(x) + .5, float32(y) + .5}
+	c := sr.color
+	if sr.paint != nil {
+		c = sr.paint.at(v)
+	}
+	if sr.mask != nil {
+		c.A = uint8(float32(c.A)*sr.mask.maskAt(v) + .5)
+	}
+	return c
+}
+
+func (sr *softwareRasterizer) doQuads() {
+	dl := sr.dl
+	n := int(dl.integers[sr.integer])
+	antialias := dl.integers[sr.integer+1] != 0
+	sr.integer += 2
+	for i := 0; i < n; i++ {
+		sr.fillQuad(dl.floats[sr.float:sr.float+8], antialias)
+		sr.float += 8
+	}
+}
+
+// fillQuad fills the quad whose corners are f.
+func (sr *softwareRasterizer) fillQuad(f []float32, antialias bool) {
+	p := [4]Pointf{{f[0], f[1]}, {f[2], f[3]}, {f[4], f[5]}, {f[6], f[7]}}
+	if antialias {
+		sr.fillCoverage([][]Pointf{p[:]}, FILL_NONZERO)
+		return
+	}
+	// The same triangles as DoQuads.
+	sr.fillTriangle(p[0], p[1], p[2], nil)
+	sr.fillTriangle(p[0], p[2], p[3], nil)
+}
+
+// doImage fills the op's quad with the image as the paint.
+func (sr *softwareRasterizer) doImage() {
+	saved := sr.paint
+	var quad []float32
+	var antialias bool
+	sr.paint, quad, antialias, sr.integer, sr.float = sr.dl.imageAt(sr.integer, sr.float)
+	sr.fillQuad(quad, antialias)
+	sr.paint = saved
+}
+
+// doPath fills a row of pixels at a time. The pixels whose centres are
+// between crossings of the row's centre line by the edges where the fill
+// rule holds are inside. Like fillTriangle, a pixel whose centre is on a
+// left or top edge is inside and one on a right or bottom edge isn't.
+func (sr *softwareRasterizer) doPath() {
+	var rule uint32
+	var antialias bool
+	var contours [][]Pointf
+	rule, antialias, contours, sr.integer, sr.float = sr.dl.pathContours(sr.integer, sr.float)
+	if antialias {
+		sr.fillCoverage(contours, rule)
+		return
+	}
+
+	var edges [][2]Pointf
+	b := Rectanglef{contours[0][0], contours[0][0]}
+	for _, c := range contours {
+		for i, p := range c {
+			if q := c[(i+1)%len(c)]; p.Y != q.Y {
+				edges = append(edges, [2]Pointf{p, q})
+			}
+			b = b.Union(Rectanglef{p, p})
+		}
+	}
+	r := image.Rect(
+		int(math.Floor(float64(b.Min.X))), int(math.Floor(float64(b.Min.Y))),
+		int(math.Ceil(float64(b.Max.X))), int(math.Ceil(float64(b.Max.Y)))).Intersect(sr.clip)
+
+	var xs crossings
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		cy := float32(y) + .5
+		xs = xs[:0]
+		for _, e := range edges {
+			p, q, dir := e[0], e[1], 1
+			if p.Y > q.Y {
+				p, q, dir = q, p, -1
+			}
+			if cy >= p.Y && cy < q.Y {
+				xs = append(xs, crossing{p.X + (cy-p.Y)*(q.X-p.X)/(q.Y-p.Y), dir})
+			}
+		}
+		sort.Sort(xs)
+
+		winding := 0
+		for i := 0; i+1 < len(xs); i++ {
+			winding += xs[i].dir
+			if winding == 0 || (rule == FILL_EVEN_ODD && winding%2 == 0) {
+				continue
+			}
+			x0 := int(math.Ceil(float64(xs[i].x - .5)))
+			x1 := int(math.Ceil(float64(xs[i+1].x - .5)))
+			if x0 < r.Min.X {
+				x0 = r.Min.X
+			}
+			if x1 > r.Max.X {
+				x1 = r.Max.X
+			}
+			for x := x0; x < x1; x++ {
+				sr.blend(x, y, sr.colorAt(x, y), 1)
+			}
+		}
+	}
+}
+
+// A crossing is where an edge going down (dir 1) or up (dir -1) crosses
+// the centre line of a row of pixels.
+type crossing struct {
+	x   float32
+	dir int
+}
+
+type crossings []crossing
+
+func (xs crossings) Len() int           { return len(xs) }
+func (xs crossings) Less(i, j int) bool { return xs[i].x < xs[j].x }
+func (xs crossings) Swap(i, j int)      { xs[i], xs[j] = xs[j], xs[i] }
+
+func (sr *softwareRasterizer) doShape() {
+	dl := sr.dl
+	kind := dl.integers[sr.integer]
+	sr.integer++
+	f := dl.floats[sr.float : sr.float+SHAPE_FLOATS]
+	sr.float += SHAPE_FLOATS
+
+	toLocal := Matrix{f[8], f[9], f[10], f[11], f[12], f[13]}
+	pixel, params := f[14], f[15:]
+	coverage := func(p Pointf) float32 {
+		return shapeCoverage(shapeDistance(kind, toLocal.Transform(p), params), pixel)
+	}
+	p := [4]Pointf{{f[0], f[1]}, {f[2], f[3]}, {f[4], f[5]}, {f[6], f[7]}}
+	// The same triangles as DoShape.
+	sr.fillTriangle(p[0], p[1], p[2], coverage)
+	sr.fillTriangle(p[0], p[2], p[3], coverage)
+}
+
+// fillTriangle fills the pixels whose centres are inside a, b, c. Pixels
+// on an edge belong to the triangle only if the edge is a top or left edge
+// so that triangles sharing an edge don't both cover a pixel. coverage, if
+// not nil, gives the coverage at a pixel centre.
+func (sr *softwareRasterizer) fillTriangle(a, b, c Pointf, coverage func(Pointf) float32) {
+	area := cross(b.Sub(a), c.Sub(a))
+	if area == 0 {
+		return
+	}
+	if area < 0 {
+		b, c = c, b
+	}
+
+	r := image.Rect(
+		int(math.Floor(float64(MinF(a.X, MinF(b.X, c.X))))),
+		int(math.Floor(float64(MinF(a.Y, MinF(b.Y, c.Y))))),
+		int(math.Ceil(float64(MaxF(a.X, MaxF(b.X, c.X))))),
+		int(math.Ceil(float64(MaxF(a.Y, MaxF(b.Y, c.Y)))))).Intersect(sr.clip)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := Pointf{float32(x) + .5, float32(y) + .5}
+			if inside(a, b, p) && inside(b, c, p) && inside(c, a, p) {
+				if coverage == nil {
+					sr.blend(x, y, sr.colorAt(x, y), 1)
+				} else {
+					sr.blend(x, y, sr.colorAt(x, y), coverage(p))
+				}
+			}
+		}
+	}
+}
+
+// blend composites c with its alpha scaled by coverage over the pixel at
+// x, y. Other ops than source-over are blended with the pixel by coverage.
+func (sr *softwareRasterizer) blend(x, y int, c color.RGBA, coverage float32) {
+	i := sr.dst.PixOffset(x, y)
+	pix := sr.dst.Pix[i : i+4]
+	if sr.op != drawop.SoverD {
+		a := float32(c.A) / 255
+		s := [4]float32{float32(c.R) / 255 * a, float32(c.G) / 255 * a, float32(c.B) / 255 * a, a}
+		var d [4]float32
+		for j := range d {
+			d[j] = float32(pix[j]) / 255
+		}
+		r := sr.op.Composite(s, d)
+		for j := range r {
+			v := d[j] + (r[j]-d[j])*coverage
+			pix[j] = uint8(MaxF(0, MinF(v, 1))*255 + .5)
+		}
+		return
+	}
+	a := float32(c.A) / 255 * coverage
+	if a <= 0 {
+		return
+	}
+	// image.RGBA is premultiplied.
+	pix[0] = uint8(float32(c.R)*a + float32(pix[0])*(1-a) + .5)
+	pix[1] = uint8(float32(c.G)*a + float32(pix[1])*(1-a) + .5)
+	pix[2] = uint8(float32(c.B)*a + float32(pix[2])*(1-a) + .5)
+	pix[3] = uint8(255*a + float32(pix[3])*(1-a) + .5)
+}
+
+// cross returns the z component of the cross product of u and v.
+func cross(u, v Pointf) float32 {
+	return u.X*v.Y - u.Y*v.X
+}
+
+// inside reports whether p is on the inner side of the edge from a to b of
+// a triangle with positive area, applying the top-left rule to points on
+// the edge.
+func inside(a, b, p Pointf) bool {
+	e := cross(b.Sub(a), p.Sub(a))
+	if e != 0 {
+		return e > 0
+	}
+	d := b.Sub(a)
+	return d.Y < 0 || (d.Y == 0 && d.X > 0)
+}
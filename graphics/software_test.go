@@ -0,0 +1,86 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func AssertRGBA(t *testing.T, expected color.RGBA, img *image.RGBA, x, y int) {
+	if c := img.RGBAAt(x, y); c != expected {
+		t.Errorf("pixel %d,%d: expected %v, got %v", x, y, expected, c)
+	}
+}
+
+func TestRasterizeQuads(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(color.RGBA{0xff, 0, 0, 0xff})
+	dl.DrawQuads([][4]Pointf{{{2, 2}, {6, 2}, {6, 6}, {2, 6}}})
+	// Translucent, so pixels on the diagonal would show being drawn twice.
+	dl.SetColor(color.RGBA{0, 0, 0xff, 0x80})
+	dl.DrawQuads([][4]Pointf{{{4, 0}, {10, 0}, {10, 10}, {4, 10}}})
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	dl.Rasterize(img)
+
+	AssertRGBA(t, color.RGBA{}, img, 1, 1)
+	AssertRGBA(t, color.RGBA{0xff, 0, 0, 0xff}, img, 2, 2)
+	AssertRGBA(t, color.RGBA{0xff, 0, 0, 0xff}, img, 3, 5)
+	AssertRGBA(t, color.RGBA{}, img, 3, 6)
+	blue := color.RGBA{0, 0, 0x80, 0x80}
+	for i := 4; i < 10; i++ {
+		AssertRGBA(t, blue, img, 13-i, i)
+		if i >= 6 {
+			AssertRGBA(t, blue, img, i, i)
+		}
+	}
+	AssertRGBA(t, color.RGBA{0x7f, 0, 0x80, 0xff}, img, 5, 3)
+}
+
+func TestRasterizeTransformAndClip(t *testing.T) {
+	dl := &DisplayList{}
+	dl.Transform(Translate(Pointf{2, 0}))
+	dl.Save()
+	dl.ClipRect(Rect(0, 0, 4, 4))
+	dl.SetColor(color.RGBA{0, 0xff, 0, 0xff})
+	dl.DrawPoints([]Pointf{{2, 2}})
+	dl.DrawQuads([][4]Pointf{{{0, 6}, {2, 6}, {2, 8}, {0, 8}}})
+	dl.Restore()
+	dl.DrawQuads([][4]Pointf{{{0, 6}, {2, 6}, {2, 8}, {0, 8}}})
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	dl.Rasterize(img)
+	green := color.RGBA{0, 0xff, 0, 0xff}
+	// A point has no size by default so draws nothing.
+	AssertRGBA(t, color.RGBA{}, img, 4, 2)
+	// The quad outside the clip only shows after Restore.
+	AssertRGBA(t, green, img, 2, 6)
+	AssertRGBA(t, green, img, 3, 7)
+	AssertRGBA(t, color.RGBA{}, img, 1, 7)
+	AssertRGBA(t, color.RGBA{}, img, 4, 7)
+
+	dl = &DisplayList{}
+	dl.ClipRect(Rect(0, 0, 4, 4))
+	dl.SetColor(green)
+	dl.SetPointSize(4)
+	dl.DrawPoints([]Pointf{{4, 4}})
+	img = image.NewRGBA(image.Rect(0, 0, 10, 10))
+	dl.Rasterize(img)
+	AssertRGBA(t, green, img, 2, 2)
+	AssertRGBA(t, green, img, 3, 3)
+	AssertRGBA(t, color.RGBA{}, img, 4, 4)
+}
@@ -0,0 +1,396 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"math"
+)
+
+// How a StrokeStyle joins segments.
+const (
+	JOIN_MITER = iota
+	JOIN_ROUND
+	JOIN_BEVEL
+)
+
+// How a StrokeStyle ends open subpaths and dashes.
+const (
+	CAP_BUTT = iota
+	CAP_ROUND
+	CAP_SQUARE
+)
+
+// DEFAULT_MITER_LIMIT is the miter limit of a StrokeStyle that doesn't set
+// one.
+const DEFAULT_MITER_LIMIT = 4
+
+// Tangents whose cross product is smaller than this are parallel.
+const tangentTolerance = 1e-6
+
+// StrokeStyle says how to stroke a Path. The zero value strokes nothing.
+type StrokeStyle struct {
+	Width float32
+
+	// A JOIN_ value.
+	Join int
+
+	// Miter joins longer than MiterLimit times the Width are beveled
+	// instead. 0 means DEFAULT_MITER_LIMIT.
+	MiterLimit float32
+
+	// A CAP_ value.
+	Cap int
+
+	// Dashes alternates the lengths of dashes and the gaps between them,
+	// repeated along each subpath. An odd number of lengths is used twice
+	// over. Empty means a solid stroke.
+	Dashes []float32
+
+	// How far into Dashes each subpath starts.
+	DashOffset float32
+}
+
+// Stroke returns the outline of p stroked with s: a Path of closed
+// subpaths that, filled with the nonzero rule, covers the stroke. The
+// outlines of Arcs are Arcs.
+func (p *Path) Stroke(s StrokeStyle) *Path {
+	o := outlineSink{new(Path)}
+	stroke(p, s, o)
+	return o.path
+}
+
+// StrokePath strokes p with s by filling its outline, so translucent
+// strokes cover each pixel once and the pieces don't seam.
+func (dl *DisplayList) StrokePath(p *Path, s StrokeStyle) {
+	dl.FillPath(p.Stroke(s), FILL_NONZERO)
+}
+
+// strokeSink receives the pieces of a stroke. The stroke is their union.
+type strokeSink interface {
+	// A convex polygon.
+	polygon(ps ...Pointf)
+
+	// A wedge of the circle with centre c and radius r.
+	wedge(c Pointf, r, start, sweep float32)
+
+	// hw either side of an arc of the circle with centre c and radius r,
+	// with butt ends.
+	band(c Pointf, r, hw, start, sweep float32)
+}
+
+func stroke(p *Path, s StrokeStyle, sink strokeSink) {
+	if !(s.Width > 0) {
+		return
+	}
+	if s.MiterLimit <= 0 {
+		s.MiterLimit = DEFAULT_MITER_LIMIT
+	}
+	st := stroker{style: s, hw: s.Width / 2, sink: sink}
+	for _, sp := range p.subpaths {
+		for _, d := range dash(sp, s.Dashes, s.DashOffset) {
+			st.subpath(d)
+		}
+	}
+}
+
+type stroker struct {
+	style StrokeStyle
+	hw    float32
+	sink  strokeSink
+}
+
+func (st *stroker) subpath(sp subpath) {
+	if len(sp.segments) == 0 {
+		return
+	}
+	var segs []*Arc
+	for _, a := range sp.segments {
+		if a.Length() > 0 {
+			segs = append(segs, a)
+		}
+	}
+	if len(segs) == 0 {
+		st.dot(sp.start)
+		return
+	}
+
+	for i, a := range segs {
+		st.segment(a)
+		if i > 0 {
+			st.join(segs[i-1], a)
+		}
+	}
+	if sp.closed {
+		st.join(segs[len(segs)-1], segs[0])
+		return
+	}
+	t0, _ := segs[0].tangents()
+	_, t1 := segs[len(segs)-1].tangents()
+	st.cap(segs[0].P0(), t0.Mul(-1))
+	st.cap(segs[len(segs)-1].P1(), t1)
+}
+
+func (st *stroker) segment(a *Arc) {
+	if a.d != 0 {
+		start, sweep := a.Angles()
+		st.sink.band(a.Center(), a.Radius(), st.hw, start, sweep)
+		return
+	}
+	p0, p1 := a.P0(), a.P1()
+	n := normal(unit(p1.Sub(p0))).Mul(st.hw)
+	st.sink.polygon(p0.Add(n), p1.Add(n), p1.Sub(n), p0.Sub(n))
+}
+
+// join fills the gap on the outside of the turn from the end of a to the
+// start of b.
+func (st *stroker) join(a, b *Arc) {
+	_, t1 := a.tangents()
+	t0, _ := b.tangents()
+	turn := cross(t1, t0)
+	if AbsF(turn) < tangentTolerance && dot(t1, t0) > 0 {
+		return
+	}
+	side := float32(1)
+	if turn > 0 {
+		side = -1
+	}
+	p := b.P0()
+	n1, n0 := normal(t1).Mul(side), normal(t0).Mul(side)
+
+	switch st.style.Join {
+	case JOIN_ROUND:
+		sweep := math.Atan2(float64(cross(n1, n0)), float64(dot(n1, n0)))
+		st.sink.wedge(p, st.hw, angle(n1), float32(sweep))
+		return
+	case JOIN_MITER:
+		// The tip of the miter is along the bisector of the normals, 1 /
+		// cos(phi) times hw away where phi is half the turn.
+		if u := n1.Add(n0); length(u) > 0 {
+			u = unit(u)
+			if cos := dot(u, n1); cos > 0 && 1/cos <= st.style.MiterLimit {
+				st.sink.polygon(p, p.Add(n1.Mul(st.hw)), p.Add(u.Mul(st.hw/cos)), p.Add(n0.Mul(st.hw)))
+				return
+			}
+		}
+	}
+	st.sink.polygon(p, p.Add(n1.Mul(st.hw)), p.Add(n0.Mul(st.hw)))
+}
+
+// cap ends the stroke at p, where the path leaves in direction t.
+func (st *stroker) cap(p, t Pointf) {
+	n := normal(t).Mul(st.hw)
+	switch st.style.Cap {
+	case CAP_ROUND:
+		st.sink.wedge(p, st.hw, angle(n), -math.Pi)
+	case CAP_SQUARE:
+		e := t.Mul(st.hw)
+		st.sink.polygon(p.Add(n), p.Add(n).Add(e), p.Sub(n).Add(e), p.Sub(n))
+	}
+}
+
+// dot caps a subpath of zero length, which has no direction.
+func (st *stroker) dot(p Pointf) {
+	switch st.style.Cap {
+	case CAP_ROUND:
+		st.sink.wedge(p, st.hw, 0, 2*math.Pi)
+	case CAP_SQUARE:
+		h := st.hw
+		st.sink.polygon(Pointf{p.X - h, p.Y - h}, Pointf{p.X + h, p.Y - h}, Pointf{p.X + h, p.Y + h}, Pointf{p.X - h, p.Y + h})
+	}
+}
+
+// dash returns the dashes of sp for the StrokeStyle Dashes pattern
+// starting offset into it.
+func dash(sp subpath, pattern []float32, offset float32) []subpath {
+	if len(pattern)%2 == 1 {
+		pattern = append(append([]float32(nil), pattern...), pattern...)
+	}
+	var total float32
+	for _, d := range pattern {
+		if d < 0 {
+			return []subpath{sp}
+		}
+		total += d
+	}
+	if !(total > 0) || len(sp.segments) == 0 {
+		return []subpath{sp}
+	}
+
+	// Find where in the pattern the subpath starts.
+	pos := float32(math.Mod(float64(offset), float64(total)))
+	if pos < 0 {
+		pos += total
+	}
+	if pos >= total {
+		pos = 0
+	}
+	// A dash of length 0 at the start is a dot.
+	i := 0
+	for pos > pattern[i] || (pos > 0 && pos == pattern[i]) {
+		pos -= pattern[i]
+		i = (i + 1) % len(pattern)
+	}
+	remain := pattern[i] - pos
+	on := i%2 == 0
+	startsOn := on
+
+	var dashes []subpath
+	cur := subpath{start: sp.start}
+	for _, seg := range sp.segments {
+		l := seg.Length()
+		done := float32(0)
+		for l-done > remain {
+			next := done + remain
+			if on {
+				cur.segments = append(cur.segments, section(seg, done/l, next/l))
+				dashes = append(dashes, cur)
+			} else {
+				cur = subpath{start: section(seg, next/l, next/l).P0()}
+			}
+			done = next
+			on = !on
+			i = (i + 1) % len(pattern)
+			remain = pattern[i]
+		}
+		if on && l > done {
+			cur.segments = append(cur.segments, section(seg, done/l, 1))
+		}
+		remain -= l - done
+	}
+
+	switch {
+	case !on:
+	case len(dashes) == 0:
+		// One dash covers the whole subpath.
+		return []subpath{sp}
+	case sp.closed && startsOn:
+		// The last dash runs on into the first.
+		dashes[0].segments = append(cur.segments, dashes[0].segments...)
+		dashes[0].start = cur.start
+	default:
+		dashes = append(dashes, cur)
+	}
+	return dashes
+}
+
+// section returns the part of a between the fractions t0 and t1 of its
+// length.
+func section(a *Arc, t0, t1 float32) *Arc {
+	if t1 <= t0 {
+		p := a.P0()
+		switch {
+		case t0 >= 1:
+			p = a.P1()
+		case t0 > 0:
+			b, _, _ := a.Split(t0)
+			p = b.P1()
+		}
+		return NewArc(p, p, 0)
+	}
+	if t1 < 1 {
+		a, _, _ = a.Split(t1)
+		t0 /= t1
+	}
+	if t0 > 0 {
+		_, a, _ = a.Split(t0)
+	}
+	return a
+}
+
+// tangents returns the unit tangents in the direction of travel at the
+// start and end of a.
+func (a *Arc) tangents() (t0, t1 Pointf) {
+	p0, p1 := a.P0(), a.P1()
+	if a.d == 0 {
+		t := unit(p1.Sub(p0))
+		return t, t
+	}
+	c := a.Center()
+	t0, t1 = unit(normal(p0.Sub(c))), unit(normal(p1.Sub(c)))
+	if _, sweep := a.Angles(); sweep < 0 {
+		t0, t1 = t0.Mul(-1), t1.Mul(-1)
+	}
+	return t0, t1
+}
+
+// outlineSink collects the pieces of a stroke as a Path.
+type outlineSink struct {
+	path *Path
+}
+
+func (o outlineSink) polygon(ps ...Pointf) {
+	// Every piece winds the same way so that the nonzero rule fills their
+	// union.
+	var area float32
+	for i, p := range ps {
+		area += cross(p, ps[(i+1)%len(ps)])
+	}
+	if area == 0 {
+		return
+	}
+	if area < 0 {
+		r := make([]Pointf, len(ps))
+		for i, p := range ps {
+			r[len(ps)-1-i] = p
+		}
+		ps = r
+	}
+	o.path.MoveTo(ps[0])
+	for _, p := range ps[1:] {
+		o.path.LineTo(p)
+	}
+	o.path.Close()
+}
+
+func (o outlineSink) wedge(c Pointf, r, start, sweep float32) {
+	if sweep < 0 {
+		start, sweep = start+sweep, -sweep
+	}
+	if sweep >= 2*math.Pi {
+		o.path.MoveTo(c.Add(polar(r, start)))
+	} else {
+		o.path.MoveTo(c)
+	}
+	o.path.CircularArc(c, r, start, MinF(sweep, 2*math.Pi))
+	o.path.Close()
+}
+
+func (o outlineSink) band(c Pointf, r, hw, start, sweep float32) {
+	if sweep < 0 {
+		start, sweep = start+sweep, -sweep
+	}
+	o.path.MoveTo(c.Add(polar(r+hw, start)))
+	o.path.CircularArc(c, r+hw, start, sweep)
+	if r > hw {
+		o.path.CircularArc(c, r-hw, start+sweep, -sweep)
+	} else {
+		o.path.LineTo(c)
+	}
+	o.path.Close()
+}
+
+// normal returns v turned a quarter turn towards increasing angles.
+func normal(v Pointf) Pointf {
+	return Pointf{-v.Y, v.X}
+}
+
+// unit returns v scaled to length 1.
+func unit(v Pointf) Pointf {
+	return v.Mul(1 / length(v))
+}
+
+func dot(u, v Pointf) float32 {
+	return u.X*v.X + u.Y*v.Y
+}
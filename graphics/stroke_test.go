@@ -0,0 +1,214 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphics
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+func polyline(ps ...Pointf) *Path {
+	p := new(Path)
+	p.MoveTo(ps[0])
+	for _, q := range ps[1:] {
+		p.LineTo(q)
+	}
+	return p
+}
+
+// pieces returns the number of segments in each subpath of p.
+func pieces(p *Path) []int {
+	var n []int
+	for _, s := range p.subpaths {
+		if len(s.segments) > 0 {
+			n = append(n, len(s.segments))
+		}
+	}
+	return n
+}
+
+func TestStrokeCaps(t *testing.T) {
+	line := polyline(Pointf{2, 10}, Pointf{18, 10})
+	for _, c := range []struct {
+		cap  int
+		x, n int
+	}{{CAP_BUTT, 2, 1}, {CAP_SQUARE, 0, 3}, {CAP_ROUND, 0, 3}} {
+		o := line.Stroke(StrokeStyle{Width: 4, Cap: c.cap})
+		testhelpers.AssertInt(t, c.n, len(pieces(o)))
+		r := o.Bounds()
+		AssertPointEqual(t, Pointf{float32(c.x), 8}, r.Min)
+		AssertPointEqual(t, Pointf{float32(20 - c.x), 12}, r.Max)
+	}
+
+	// A zero length subpath is a dot.
+	dot := polyline(Pointf{5, 5}, Pointf{5, 5})
+	testhelpers.AssertInt(t, 0, len(pieces(dot.Stroke(StrokeStyle{Width: 4}))))
+	o := dot.Stroke(StrokeStyle{Width: 4, Cap: CAP_ROUND})
+	testhelpers.AssertInt(t, 1, len(pieces(o)))
+	AssertPointEqual(t, Pointf{3, 3}, o.Bounds().Min)
+}
+
+func TestStrokeJoins(t *testing.T) {
+	corner := polyline(Pointf{2, 2}, Pointf{10, 2}, Pointf{10, 10})
+	o := corner.Stroke(StrokeStyle{Width: 2, Join: JOIN_MITER})
+	n := pieces(o)
+	if len(n) != 3 || n[2] != 4 {
+		t.Errorf("expected two bodies and a miter, got %v", n)
+	}
+	tip := false
+	for _, a := range o.subpaths[2].segments {
+		tip = tip || a.P1().Eq(Pointf{11, 1})
+	}
+	AssertTrue(t, tip)
+	n = pieces(corner.Stroke(StrokeStyle{Width: 2, Join: JOIN_BEVEL}))
+	if len(n) != 3 || n[2] != 3 {
+		t.Errorf("expected two bodies and a bevel, got %v", n)
+	}
+
+	// Too sharp for the miter limit.
+	sharp := polyline(Pointf{0, 0}, Pointf{10, 0}, Pointf{0, 1})
+	n = pieces(sharp.Stroke(StrokeStyle{Width: 2, Join: JOIN_MITER}))
+	if len(n) != 3 || n[2] != 3 {
+		t.Errorf("expected a bevel past the miter limit, got %v", n)
+	}
+	n = pieces(sharp.Stroke(StrokeStyle{Width: 2, Join: JOIN_MITER, MiterLimit: 100}))
+	if len(n) != 3 || n[2] != 4 {
+		t.Errorf("expected a miter within the miter limit, got %v", n)
+	}
+
+	// Straight on needs no join and a closed path joins its ends.
+	n = pieces(polyline(Pointf{0, 0}, Pointf{5, 0}, Pointf{10, 0}).Stroke(StrokeStyle{Width: 2}))
+	testhelpers.AssertInt(t, 2, len(n))
+	square := polyline(Pointf{0, 0}, Pointf{10, 0}, Pointf{10, 10}, Pointf{0, 10})
+	square.Close()
+	testhelpers.AssertInt(t, 8, len(pieces(square.Stroke(StrokeStyle{Width: 2}))))
+}
+
+func TestStrokeArcIsExact(t *testing.T) {
+	c := Pointf{10, 10}
+	p := new(Path)
+	if err := p.CircularArc(c, 5, 0, math.Pi/2); err != nil {
+		t.Fatal(err)
+	}
+	o := p.Stroke(StrokeStyle{Width: 2})
+	arcs := 0
+	for _, s := range o.subpaths {
+		for _, a := range s.segments {
+			if a.Depth() == 0 {
+				continue
+			}
+			arcs++
+			AssertPointEqual(t, c, a.Center())
+			if r := a.Radius(); AbsF(r-6) > FLOAT_TOL && AbsF(r-4) > FLOAT_TOL {
+				t.Errorf("offset radius %f", r)
+			}
+		}
+	}
+	testhelpers.AssertInt(t, 2, arcs)
+}
+
+// dashLengths returns the lengths of the dashes of sp.
+func dashLengths(sp subpath, pattern []float32, offset float32) []float32 {
+	var ls []float32
+	for _, d := range dash(sp, pattern, offset) {
+		var l float32
+		for _, a := range d.segments {
+			l += a.Length()
+		}
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+func TestDash(t *testing.T) {
+	line := polyline(Pointf{2, 10}, Pointf{18, 10}).subpaths[0]
+	for _, c := range []struct {
+		pattern []float32
+		offset  float32
+		lengths []float32
+	}{
+		{[]float32{4, 2}, 0, []float32{4, 4, 4}},
+		{[]float32{4, 2}, 2, []float32{2, 4, 4}},
+		{[]float32{4, 2}, -2, []float32{4, 4, 2}},
+		{[]float32{3}, 0, []float32{3, 3, 3}},
+		{[]float32{0, 5}, 0, []float32{0, 0, 0, 0}},
+		{nil, 0, []float32{16}},
+	} {
+		ls := dashLengths(line, c.pattern, c.offset)
+		if len(ls) != len(c.lengths) {
+			t.Errorf("%v offset %v: expected %v, got %v", c.pattern, c.offset, c.lengths, ls)
+			continue
+		}
+		for i := range ls {
+			AssertFloatEqual(t, c.lengths[i], ls[i])
+		}
+	}
+
+	// On a closed path the dash over the start is one dash.
+	square := polyline(Pointf{0, 0}, Pointf{10, 0}, Pointf{10, 10}, Pointf{0, 10})
+	square.Close()
+	ls := dashLengths(square.subpaths[0], []float32{6, 4}, 3)
+	if len(ls) != 4 {
+		t.Fatalf("expected 4 dashes, got %v", ls)
+	}
+	AssertFloatEqual(t, 6, ls[0])
+
+	// Dots.
+	o := polyline(Pointf{2, 10}, Pointf{18, 10}).Stroke(StrokeStyle{Width: 2, Cap: CAP_ROUND, Dashes: []float32{0, 5}})
+	testhelpers.AssertInt(t, 4, len(pieces(o)))
+}
+
+func TestStrokePath(t *testing.T) {
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.StrokePath(polyline(Pointf{2, 3}, Pointf{18, 3}), StrokeStyle{Width: 4, Cap: CAP_ROUND})
+	p := new(Path)
+	p.CircularArc(Pointf{10, 10}, 6, 0, math.Pi/2)
+	dl.StrokePath(p, StrokeStyle{Width: 3})
+	img := rasterize(dl)
+
+	AssertRGBA(t, red, img, 10, 2)
+	AssertRGBA(t, red, img, 1, 3)
+	AssertRGBA(t, color.RGBA{}, img, 10, 6)
+	AssertRGBA(t, red, img, 14, 14)
+	AssertRGBA(t, red, img, 16, 10)
+	AssertRGBA(t, red, img, 10, 16)
+	// Butt ends.
+	AssertRGBA(t, color.RGBA{}, img, 16, 9)
+	AssertRGBA(t, color.RGBA{}, img, 9, 16)
+	AssertRGBA(t, color.RGBA{}, img, 10, 10)
+}
+
+func TestStrokePathInteriorCovered(t *testing.T) {
+	// Diagonal segments and a miter, which the pieces of a stroke would
+	// seam along.
+	ps := []Pointf{{2.3, 15.7}, {10.2, 4.1}, {18.6, 14.3}}
+	dl := &DisplayList{}
+	dl.SetColor(red)
+	dl.StrokePath(polyline(ps...), StrokeStyle{Width: 5})
+	img := rasterize(dl)
+
+	for i := 1; i < len(ps); i++ {
+		for _, f := range []float32{0.2, 0.4, 0.5, 0.6, 0.8} {
+			p := ps[i-1].Add(ps[i].Sub(ps[i-1]).Mul(f))
+			AssertRGBA(t, red, img, int(p.X), int(p.Y))
+		}
+	}
+	AssertRGBA(t, red, img, 10, 4)
+	AssertRGBA(t, red, img, 10, 6)
+}
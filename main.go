@@ -15,13 +15,42 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/content/dom"
 	"github.com/google/gojiraw/window"
 	"github.com/go-gl/glfw3/v3.0/glfw"
 )
 
+var (
+	record = flag.String("record", "", "record the session's events and frames to this file")
+	replay = flag.String("replay", "", "replay a recording made with -record and check its frames")
+	open   = flag.String("open", "", "open this document")
+	save   = flag.String("save", "", "save the document to this file when the window closes")
+	binary = flag.Bool("binary", false, "save in the binary format rather than JSON")
+	snap   = flag.Bool("snap", false, "snap dragged vertices to other elements and into alignment with them")
+	grid   = flag.Float64("grid", 0, "snap dragged vertices to a grid of this spacing")
+)
+
 func main() {
+	flag.Parse()
+
+	if *replay != "" {
+		f, err := os.Open(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := window.Replay(f); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s replayed identically", *replay)
+		return
+	}
+
 	// Initialize glfw.
 	if !glfw.Init() {
 		log.Panic("Couldn't initialize glfw3")
@@ -33,7 +62,63 @@ func main() {
 	width := 256
 	height := 256
 	window := window.NewWindow(width, height)
+	window.Frame().SetSnapping(content.Snapping{
+		Grid:     float32(*grid),
+		Vertices: *snap,
+		Edges:    *snap,
+		Align:    *snap,
+	})
+
+	if *open != "" {
+		f, err := os.Open(*open)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = window.Frame().Load(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("opening %s: %v", *open, err)
+		}
+	}
+
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := window.Record(f); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// This will block until the window is closed.
 	window.Open()
+
+	if err := window.StopRecording(); err != nil {
+		log.Print("writing the recording: ", err)
+	}
+
+	if *save != "" {
+		format := dom.FORMAT_JSON
+		if *binary {
+			format = dom.FORMAT_BINARY
+		}
+		if err := saveDocument(window, *save, format); err != nil {
+			log.Fatalf("saving %s: %v", *save, err)
+		}
+	}
+}
+
+// saveDocument writes the document of w to the file name.
+func saveDocument(w *window.Window, name string, format dom.Format) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := w.Frame().Save(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
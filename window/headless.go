@@ -0,0 +1,101 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/graphics"
+)
+
+// Headless is a Platform without a display. Its Surfaces draw with the
+// software rasterizer and take their input from a script of Events given
+// to Post.
+type Headless struct{}
+
+func (Headless) NewSurface(width, height int, title string) (Surface, error) {
+	return &HeadlessSurface{width: width, height: height}, nil
+}
+
+// HeadlessSurface is the Surface of the Headless Platform.
+type HeadlessSurface struct {
+	width, height int
+
+	post    func(ev content.Event)
+	pending []content.Event
+	closed  bool
+
+	// The most recently presented frame.
+	image *image.RGBA
+}
+
+// The color that a HeadlessSurface is cleared to.
+var headlessBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+func (s *HeadlessSurface) Size() (width, height int) {
+	return s.width, s.height
+}
+
+func (s *HeadlessSurface) SetEventSink(post func(ev content.Event)) {
+	s.post = post
+}
+
+// Post adds ev, in Surface coordinates, to the input that the next call to
+// PollEvents delivers. An EVENT_RESIZE changes the Surface's size when it is
+// delivered.
+func (s *HeadlessSurface) Post(ev content.Event) {
+	s.pending = append(s.pending, ev)
+}
+
+func (s *HeadlessSurface) PollEvents() {
+	pending := s.pending
+	s.pending = nil
+	for _, ev := range pending {
+		if ev.Type == content.EVENT_RESIZE {
+			s.width, s.height = ev.Width, ev.Height
+		}
+		if s.post != nil {
+			s.post(ev)
+		}
+	}
+}
+
+func (s *HeadlessSurface) Present(dl *graphics.DisplayList) {
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(headlessBackground), image.ZP, draw.Src)
+	dl.Rasterize(img)
+	s.image = img
+}
+
+// Image returns the most recently presented frame or nil.
+func (s *HeadlessSurface) Image() *image.RGBA {
+	return s.image
+}
+
+// Close makes ShouldClose return true.
+func (s *HeadlessSurface) Close() {
+	s.closed = true
+}
+
+func (s *HeadlessSurface) ShouldClose() bool {
+	return s.closed
+}
+
+func (s *HeadlessSurface) Destroy() {
+	s.image = nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+	"github.com/rjkroege/wikitools/testhelpers"
+)
+
+// newHeadlessWindow returns a Window with its HeadlessSurface.
+func newHeadlessWindow(t *testing.T, width, height int) (*Window, *HeadlessSurface) {
+	w := NewWindowOn(Headless{}, width, height)
+	if err := w.Create(); err != nil {
+		t.Fatal(err)
+	}
+	return w, w.Surface().(*HeadlessSurface)
+}
+
+func Test_headlessPipeline(t *testing.T) {
+	w, s := newHeadlessWindow(t, 200, 200)
+	w.Step()
+	if c := s.Image().RGBAAt(100, 100); c != headlessBackground {
+		t.Errorf("empty frame isn't blank: %v", c)
+	}
+
+	// A click adds a translucent quad around the click point.
+	at := graphics.Pointf{100, 100}
+	s.Post(content.Event{Type: content.EVENT_MOUSEMOVE, Point: at})
+	s.Post(content.Event{Type: content.EVENT_MOUSEDOWN, Button: 0})
+	s.Post(content.Event{Type: content.EVENT_MOUSEUP, Button: 0})
+	w.Step()
+	testhelpers.AssertInt(t, 1, w.frame.Document().Len())
+	img := s.Image()
+	if c := img.RGBAAt(100, 100); c != (color.RGBA{0xe6, 0xe6, 0xe6, 0xff}) {
+		t.Errorf("quad not drawn: %v", c)
+	}
+	if c := img.RGBAAt(100, 150); c != headlessBackground {
+		t.Errorf("quad too big: %v", c)
+	}
+
+	// Zooming in about the click point grows the quad on screen.
+	s.Post(content.Event{Type: content.EVENT_KEYDOWN, Key: content.KEY_LEFT_CONTROL, Modifiers: content.MOD_CONTROL})
+	for i := 0; i < 8; i++ {
+		s.Post(content.Event{Type: content.EVENT_WHEEL, DY: -SCROLL_LINES * SCROLL_LINE_HEIGHT})
+	}
+	w.Step()
+	if c := s.Image().RGBAAt(100, 150); c == headlessBackground {
+		t.Errorf("quad didn't grow with the zoom")
+	}
+
+	s.Post(content.Event{Type: content.EVENT_RESIZE, Width: 50, Height: 40})
+	w.Step()
+	testhelpers.AssertInt(t, 50, s.Image().Bounds().Dx())
+	testhelpers.AssertInt(t, 40, int(w.height))
+
+	s.Close()
+	w.Open()
+}
+
+func Test_scriptedDrag(t *testing.T) {
+	w, s := newHeadlessWindow(t, 200, 200)
+	w.Play(new(content.Script).ClickAt(graphics.Pointf{100, 100}, 0))
+	testhelpers.AssertInt(t, 1, w.frame.Document().Len())
+	qe := w.frame.Document().At(0).(*dom.QuadElement)
+
+	// Drag vertex 2 of element 0 by (10, 5) and check the document and the
+	// pixels.
+	w.Play(new(content.Script).
+		PressAt(graphics.Pointf{145, 145}, 0).
+		DragBy(graphics.Pointf{10, 5}, 3).
+		Release(0))
+	testhelpers.AssertInt(t, 1, w.frame.Document().Len())
+	if v := qe.Vertex(2); !v.Eq(graphics.Pointf{155, 150}) {
+		t.Errorf("vertex 2 at %v", v)
+	}
+	if c := s.Image().RGBAAt(148, 146); c == headlessBackground {
+		t.Errorf("quad didn't grow where the vertex was dragged")
+	}
+
+	// Scrolled down by 20 pixels, the same screen point is 20 pixels
+	// further into the content.
+	w.Play(new(content.Script).Wheel(0, 20).MoveTo(graphics.Pointf{155, 130}))
+	if e, v := w.frame.FindElementAtPoint(graphics.Pointf{155, 150}); e != qe || v != 2 {
+		t.Errorf("lost vertex 2: %v %d", e, v)
+	}
+	if c := s.Image().RGBAAt(100, 140); c == headlessBackground {
+		t.Errorf("quad not drawn scrolled")
+	}
+}
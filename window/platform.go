@@ -0,0 +1,52 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/graphics"
+)
+
+// Platform is a source of Surfaces: glfw on a desktop or Headless in
+// tests.
+type Platform interface {
+	// NewSurface creates a width by height pixel Surface with the given
+	// title.
+	NewSurface(width, height int, title string) (Surface, error)
+}
+
+// Surface is the part of a Platform that a Window draws into and receives
+// events from.
+type Surface interface {
+	// Size returns the size of the Surface in pixels.
+	Size() (width, height int)
+
+	// SetEventSink makes the Surface pass its events to post. The events
+	// are in Surface coordinates.
+	SetEventSink(post func(ev content.Event))
+
+	// PollEvents passes the events that have arrived since the last call to
+	// the event sink without waiting for more.
+	PollEvents()
+
+	// Present shows dl, replacing whatever the Surface showed before.
+	Present(dl *graphics.DisplayList)
+
+	// ShouldClose reports whether the Surface has been asked to close.
+	ShouldClose() bool
+
+	// Destroy releases the Surface's resources.
+	Destroy()
+}
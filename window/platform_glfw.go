@@ -0,0 +1,158 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"time"
+
+	"github.com/go-gl/gl"
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/graphics"
+
+	glfw "github.com/go-gl/glfw3/v3.0/glfw"
+)
+
+// Glfw is the Platform of OpenGL windows made with glfw. glfw must have been
+// initialized.
+type Glfw struct{}
+
+type glfwSurface struct {
+	w *glfw.Window
+
+	// TODO(vollick): Passing around one program like this is a stopgap. We
+	// should really be initializing our shader library here.
+	program gl.Program
+}
+
+// Based on https://raw.github.com/go-gl/examples/master/glfw/simplewindow
+func (Glfw) NewSurface(width, height int, title string) (Surface, error) {
+	glfw.WindowHint(glfw.ContextVersionMajor, 4)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenglForwardCompatible, glfw.True)
+	glfw.WindowHint(glfw.OpenglProfile, glfw.OpenglCoreProfile)
+	// DisplayList.DoPath fills with the stencil buffer.
+	glfw.WindowHint(glfw.StencilBits, 8)
+
+	w, err := glfw.CreateWindow(width, height, title, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	w.MakeContextCurrent()
+
+	// Apparantly, this enables vsync?
+	glfw.SwapInterval(1)
+
+	gl.Init()
+	gl.GetError()
+
+	return &glfwSurface{w: w, program: graphics.CreateDefaultShaders()}, nil
+}
+
+func (s *glfwSurface) Size() (width, height int) {
+	return s.w.GetSize()
+}
+
+func (s *glfwSurface) SetEventSink(post func(ev content.Event)) {
+	s.w.SetSizeCallback(func(_ *glfw.Window, w, h int) {
+		post(content.Event{Type: content.EVENT_RESIZE, Time: glfwTime(), Width: w, Height: h})
+	})
+
+	s.w.SetMouseButtonCallback(func(_ *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		typ := content.EVENT_MOUSEUP
+		if action == glfw.Press {
+			typ = content.EVENT_MOUSEDOWN
+		}
+		post(content.Event{Type: typ, Time: glfwTime(), Button: uint32(button), Modifiers: uint32(mods)})
+	})
+
+	// glfw's scroll offsets are the opposite of the DOM's: positive yoff
+	// scrolls up (i.e. towards the start of the content) and positive xoff
+	// scrolls left.
+	s.w.SetScrollCallback(func(_ *glfw.Window, xoff, yoff float64) {
+		post(content.Event{
+			Type: content.EVENT_WHEEL,
+			Time: glfwTime(),
+			DX:   float32(-xoff * SCROLL_LINES * SCROLL_LINE_HEIGHT),
+			DY:   float32(-yoff * SCROLL_LINES * SCROLL_LINE_HEIGHT),
+		})
+	})
+
+	s.w.SetKeyCallback(func(_ *glfw.Window, key glfw.Key, _ int, action glfw.Action, mods glfw.ModifierKey) {
+		typ := content.EVENT_KEYDOWN
+		if action == glfw.Release {
+			typ = content.EVENT_KEYUP
+		}
+		post(content.Event{Type: typ, Time: glfwTime(), Key: content.Key(key), Modifiers: uint32(mods), Repeat: action == glfw.Repeat})
+	})
+
+	s.w.SetCharacterCallback(func(_ *glfw.Window, char uint) {
+		post(content.Event{Type: content.EVENT_TEXTINPUT, Time: glfwTime(), Text: rune(char)})
+	})
+
+	s.w.SetCursorEnterCallback(func(_ *glfw.Window, entered bool) {
+		typ := content.EVENT_MOUSELEAVE
+		if entered {
+			typ = content.EVENT_MOUSEENTER
+		}
+		post(content.Event{Type: typ, Time: glfwTime()})
+	})
+
+	s.w.SetFocusCallback(func(_ *glfw.Window, focused bool) {
+		typ := content.EVENT_BLUR
+		if focused {
+			typ = content.EVENT_FOCUS
+		}
+		post(content.Event{Type: typ, Time: glfwTime()})
+	})
+
+	s.w.SetCursorPositionCallback(func(_ *glfw.Window, x, y float64) {
+		post(content.Event{Type: content.EVENT_MOUSEMOVE, Time: glfwTime(), Point: graphics.Pointf{float32(x), float32(y)}})
+	})
+}
+
+func (s *glfwSurface) PollEvents() {
+	glfw.PollEvents()
+}
+
+func (s *glfwSurface) Present(dl *graphics.DisplayList) {
+	width, height := s.Size()
+
+	gl.ClearColor(1, 1, 1, 0)
+	graphics.CheckForGLErrors()
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+	graphics.CheckForGLErrors()
+
+	gl.Enable(gl.BLEND)
+	graphics.DefaultBlend()
+	graphics.CheckForGLErrors()
+
+	dl.Draw(&s.program, float32(width), float32(height))
+	s.w.SwapBuffers()
+}
+
+func (s *glfwSurface) ShouldClose() bool {
+	return s.w.ShouldClose()
+}
+
+func (s *glfwSurface) Destroy() {
+	s.program.Delete()
+	s.w.Destroy()
+}
+
+// glfw doesn't timestamp its events so use the time at which the callback
+// runs.
+func glfwTime() time.Duration {
+	return time.Duration(glfw.GetTime() * float64(time.Second))
+}
@@ -0,0 +1,177 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/content/dom"
+	"github.com/google/gojiraw/graphics"
+)
+
+// The version of the recordings that Record writes. A recording is a gob
+// stream of a recordingHeader followed by a recordEntry for each Event the
+// Window handled and each frame it rendered, in order.
+const RECORDING_VERSION = 3
+
+type recordingHeader struct {
+	Version       int
+	Width, Height int
+
+	// The Window's double click settings.
+	Interval time.Duration
+	Slop     float32
+
+	// The Frame's document when recording started, in dom.FORMAT_BINARY,
+	// and what its drags snap to.
+	Document []byte
+	Snapping content.Snapping
+}
+
+// Exactly one of the fields is set.
+type recordEntry struct {
+	// An Event in Surface coordinates as given to HandleEvent.
+	Event *content.Event
+
+	// The encoded DisplayList of a rendered frame.
+	Frame []byte
+}
+
+// recorder writes a recording.
+type recorder struct {
+	enc *gob.Encoder
+	// The first error writing the recording. Nothing more is written after
+	// an error.
+	err error
+}
+
+func (r *recorder) write(e *recordEntry) {
+	if r.err == nil {
+		r.err = r.enc.Encode(e)
+	}
+}
+
+func (r *recorder) event(ev content.Event) {
+	r.write(&recordEntry{Event: &ev})
+}
+
+func (r *recorder) frame(dl *graphics.DisplayList) {
+	if r.err != nil {
+		return
+	}
+	data, err := dl.MarshalBinary()
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.write(&recordEntry{Frame: data})
+}
+
+// Record starts writing every Event the Window handles, with its
+// timestamp, and every frame it renders to w. Replay reproduces the
+// recording from a new Frame with the document and snapping the Frame has
+// now, so start recording before the Window handles any Events.
+func (window *Window) Record(w io.Writer) error {
+	r := &recorder{enc: gob.NewEncoder(w)}
+	var doc bytes.Buffer
+	if err := window.frame.Save(&doc, dom.FORMAT_BINARY); err != nil {
+		return err
+	}
+	h := recordingHeader{
+		Version:  RECORDING_VERSION,
+		Width:    int(window.width),
+		Height:   int(window.height),
+		Interval: window.clicks.Interval,
+		Slop:     window.clicks.Slop,
+		Document: doc.Bytes(),
+		Snapping: window.frame.Snapping(),
+	}
+	if err := r.enc.Encode(&h); err != nil {
+		return err
+	}
+	window.recorder = r
+	return nil
+}
+
+// StopRecording stops the recording started by Record and returns the
+// first error that writing it met.
+func (window *Window) StopRecording() error {
+	r := window.recorder
+	window.recorder = nil
+	if r == nil {
+		return nil
+	}
+	return r.err
+}
+
+// Replay feeds the Events of a recording made by Record to a new headless
+// Window and checks that each frame it renders has the same DisplayList as
+// the recorded one. It returns an error describing the first difference.
+func Replay(rd io.Reader) error {
+	dec := gob.NewDecoder(rd)
+	var h recordingHeader
+	if err := dec.Decode(&h); err != nil {
+		return fmt.Errorf("reading the recording: %v", err)
+	}
+	if h.Version != RECORDING_VERSION {
+		return fmt.Errorf("recording version %d isn't %d", h.Version, RECORDING_VERSION)
+	}
+
+	window := NewWindowOn(Headless{}, h.Width, h.Height)
+	window.SetDoubleClick(h.Interval, h.Slop)
+	if err := window.Create(); err != nil {
+		return err
+	}
+	if err := window.frame.Load(bytes.NewReader(h.Document)); err != nil {
+		return fmt.Errorf("loading the recorded document: %v", err)
+	}
+	window.frame.SetSnapping(h.Snapping)
+	s := window.surface.(*HeadlessSurface)
+
+	events, frames := 0, 0
+	for {
+		var e recordEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading the recording after %d events: %v", events, err)
+		}
+
+		switch {
+		case e.Event != nil:
+			// Through the Surface so that it sees resizes.
+			s.Post(*e.Event)
+			s.PollEvents()
+			for _, ev := range window.queue.Drain() {
+				window.HandleEvent(ev)
+			}
+			events++
+		case e.Frame != nil:
+			want := new(graphics.DisplayList)
+			if err := want.UnmarshalBinary(e.Frame); err != nil {
+				return fmt.Errorf("frame %d: %v", frames, err)
+			}
+			if got := window.render(); !got.Equal(want) {
+				return fmt.Errorf("frame %d after %d events differs from the recording", frames, events)
+			}
+			frames++
+		}
+	}
+}
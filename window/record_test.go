@@ -0,0 +1,121 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/graphics"
+)
+
+// recordSession plays a few interactions on a new headless Window while
+// recording them.
+func recordSession(t *testing.T) (*Window, *bytes.Buffer) {
+	w, s := newHeadlessWindow(t, 200, 200)
+	w.SetDoubleClick(time.Second, 10)
+	var b bytes.Buffer
+	if err := w.Record(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Play(new(content.Script).ClickAt(graphics.Pointf{100, 100}, 0))
+	w.Play(new(content.Script).
+		PressAt(graphics.Pointf{145, 145}, 0).
+		DragBy(graphics.Pointf{10, 5}, 3).
+		Release(0).
+		ClickAt(graphics.Pointf{20, 20}, 0).
+		Wait(500*time.Millisecond).
+		ClickAt(graphics.Pointf{25, 20}, 0))
+	s.Post(content.Event{Type: content.EVENT_RESIZE, Width: 100, Height: 150})
+	w.Step()
+	w.Play(new(content.Script).Hold(content.MOD_CONTROL).Wheel(0, -96))
+	return w, &b
+}
+
+func Test_replay(t *testing.T) {
+	w, b := recordSession(t)
+	if err := w.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	// The double click didn't add a second element.
+	if n := w.frame.Document().Len(); n != 2 {
+		t.Errorf("expected 2 elements, got %d", n)
+	}
+	if err := Replay(bytes.NewReader(b.Bytes())); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_replayDiverges(t *testing.T) {
+	w, b := recordSession(t)
+	// A change that the recorded events don't explain.
+	w.frame.AddElement(graphics.Pointf{50, 50})
+	w.Render()
+	if err := w.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	err := Replay(bytes.NewReader(b.Bytes()))
+	if err == nil || !strings.Contains(err.Error(), "frame 4 ") {
+		t.Errorf("expected a difference in frame 4, got %v", err)
+	}
+
+	if err := Replay(bytes.NewReader(b.Bytes()[:b.Len()-3])); err == nil {
+		t.Error("replayed a truncated recording")
+	}
+}
+
+func Test_replayOpenedDocument(t *testing.T) {
+	w, _ := newHeadlessWindow(t, 200, 200)
+	w.frame.AddElement(graphics.Pointf{100, 100})
+	var b bytes.Buffer
+	if err := w.Record(&b); err != nil {
+		t.Fatal(err)
+	}
+	// Drags the element that was there before recording started.
+	w.Play(new(content.Script).
+		PressAt(graphics.Pointf{145, 145}, 0).
+		DragBy(graphics.Pointf{10, 5}, 3).
+		Release(0))
+	if err := w.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(bytes.NewReader(b.Bytes())); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_replaySnapping(t *testing.T) {
+	w, _ := newHeadlessWindow(t, 200, 200)
+	w.frame.SetSnapping(content.Snapping{Grid: 8})
+	var b bytes.Buffer
+	if err := w.Record(&b); err != nil {
+		t.Fatal(err)
+	}
+	w.Play(new(content.Script).
+		ClickAt(graphics.Pointf{100, 100}, 0).
+		PressAt(graphics.Pointf{145, 145}, 0).
+		DragBy(graphics.Pointf{11, 5}, 3).
+		Release(0))
+	if err := w.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(bytes.NewReader(b.Bytes())); err != nil {
+		t.Error(err)
+	}
+}
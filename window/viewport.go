@@ -0,0 +1,107 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"github.com/google/gojiraw/graphics"
+)
+
+const (
+	MIN_ZOOM = 1. / 16.
+	MAX_ZOOM = 32.
+)
+
+// Viewport is a pan/zoom region: it shows the Frame's content scaled by
+// zoom with the content's origin at origin in Window coordinates. Positive
+// y extends down the page and positive x increases towards the right. When
+// scrolled, the origin is up and to the left of the Window so origin.X and
+// origin.Y are <= 0. The zero Viewport has a zoom of 1.
+type Viewport struct {
+	origin graphics.Pointf
+	zoom   float32
+
+	// Size of the Window.
+	width, height float32
+
+	// Extent of the content in content coordinates.
+	cw, ch float32
+}
+
+func NewViewport(width, height float32) Viewport {
+	return Viewport{zoom: 1, width: width, height: height, cw: width, ch: height}
+}
+
+func (v *Viewport) init() {
+	if v.zoom == 0 {
+		v.zoom = 1
+	}
+}
+
+// Transform returns the transform from content to Window coordinates.
+func (v *Viewport) Transform() graphics.Matrix {
+	v.init()
+	return graphics.Translate(v.origin).Mul(graphics.Scale(v.zoom, v.zoom))
+}
+
+// ToContent maps p from Window to content coordinates.
+func (v *Viewport) ToContent(p graphics.Pointf) graphics.Pointf {
+	v.init()
+	return p.Sub(v.origin).Div(v.zoom)
+}
+
+func (v *Viewport) Origin() graphics.Pointf {
+	return v.origin
+}
+
+func (v *Viewport) Zoom() float32 {
+	v.init()
+	return v.zoom
+}
+
+// Resize tells the Viewport the size of the Window.
+func (v *Viewport) Resize(width, height float32) {
+	v.width, v.height = width, height
+	v.clamp()
+}
+
+// SetContentSize tells the Viewport how far the content extends.
+func (v *Viewport) SetContentSize(cw, ch float32) {
+	v.cw, v.ch = cw, ch
+	v.clamp()
+}
+
+// PanBy moves the content by dx, dy Window pixels. Scrolling stops at the
+// start and the end of the content.
+func (v *Viewport) PanBy(dx, dy float32) {
+	v.origin = v.origin.Add(graphics.Pointf{dx, dy})
+	v.clamp()
+}
+
+// ZoomAbout multiplies the zoom by factor keeping the content under
+// Window point p fixed.
+func (v *Viewport) ZoomAbout(p graphics.Pointf, factor float32) {
+	v.init()
+	c := v.ToContent(p)
+	v.zoom = graphics.MinF(MAX_ZOOM, graphics.MaxF(MIN_ZOOM, v.zoom*factor))
+	v.origin = p.Sub(c.Mul(v.zoom))
+	v.clamp()
+}
+
+func (v *Viewport) clamp() {
+	v.init()
+	// Consider putting Max in some kind of base-like class.
+	v.origin.X = graphics.MinF(0, graphics.MaxF(v.width-v.cw*v.zoom, v.origin.X))
+	v.origin.Y = graphics.MinF(0, graphics.MaxF(v.height-v.ch*v.zoom, v.origin.Y))
+}
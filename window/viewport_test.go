@@ -0,0 +1,56 @@
+// Copyright 2014 The Gojiraw Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"testing"
+
+	"github.com/google/gojiraw/graphics"
+)
+
+func Test_viewportZoom(t *testing.T) {
+	v := NewViewport(100, 100)
+	v.SetContentSize(400, 400)
+
+	v.ZoomAbout(graphics.Pointf{100, 100}, 2)
+	if o := v.Origin(); !o.Eq(graphics.Pointf{-100, -100}) {
+		t.Errorf("zoom about the corner has origin %v", o)
+	}
+	m := v.Transform()
+	if p := m.Transform(graphics.Pointf{50, 50}); !p.Eq(graphics.Pointf{0, 0}) {
+		t.Errorf("transform doesn't map content to the window: %v", p)
+	}
+	if p := v.ToContent(graphics.Pointf{0, 0}); !p.Eq(graphics.Pointf{50, 50}) {
+		t.Errorf("ToContent isn't the inverse of the transform: %v", p)
+	}
+
+	// The content is 800 wide at this zoom.
+	v.PanBy(-1000, 0)
+	if o := v.Origin(); o.X != -700 {
+		t.Errorf("panned past the end: %v", o)
+	}
+
+	v.ZoomAbout(graphics.Pointf{0, 0}, 1000)
+	if v.Zoom() != MAX_ZOOM {
+		t.Errorf("zoom not limited: %f", v.Zoom())
+	}
+
+	// Zooming out until the content is smaller than the window pins it to
+	// the origin.
+	v.ZoomAbout(graphics.Pointf{50, 50}, 0)
+	if v.Zoom() != MIN_ZOOM || !v.Origin().Eq(graphics.ZP) {
+		t.Errorf("bad zoom out %f %v", v.Zoom(), v.Origin())
+	}
+}
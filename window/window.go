@@ -12,24 +12,26 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-
 package window
 
 import (
-	"image"
 	"log"
+	"math"
+	"time"
 
 	"github.com/google/gojiraw/content"
 	"github.com/google/gojiraw/graphics"
-	"github.com/go-gl/gl"
-
-	glfw "github.com/go-gl/glfw3/v3.0/glfw"
 )
 
 // Stores the current mouse pointer position.
 type Mousepointer struct {
-	x, y       int
+	// In Window coordinates, not rounded to whole pixels.
+	x, y       float32
 	buttonmask uint32
+
+	// The Window is panning the Frame because of a middle button drag whose
+	// default action the Frame didn't prevent.
+	panning bool
 }
 
 // TODO: when this gets big, it might want to be a package.
@@ -38,130 +40,244 @@ type Window struct {
 	frame         *content.Frame
 	pointer       Mousepointer
 
-	// Position of the Frame's origin in the viewport. Starts as 0, 0 where the
-	// Window (aka viewport) will show the upper left corner of the content.Frame.
-	// rjk presumes that positive y extends down the page and positive x increase
-	// towards the right. As a result, x, y are <= 0.
-	//
-	// rjk:  T S cs = ws for Translation and Scale and cs is in content coordinates. Verify.
-	x, y float32
+	// The modifier keys down as of the most recent key or mouse button
+	// event.
+	mods uint32
+
+	// Counts the presses that make up double clicks.
+	clicks content.ClickCounter
 
-	// Width, height of the frame. Must start the same size as the Window.
-	fw, fh float32
+	// Events from the Surface waiting to be handled.
+	queue *content.EventQueue
 
-	// TODO(rjkroege): When the mouse wheel handling is rational and actually
-	// ships us deltas, then we can remove this code.
-	previous_absolute_displacement int
+	// Where the Window shows the Frame.
+	platform Platform
+	surface  Surface
+
+	// Maps the Frame's content into the Window. Starts out showing the upper
+	// left corner of the content.Frame at a zoom of 1.
+	viewport Viewport
 
 	// Event handling interface
 	ev content.EventHandler
+
+	// Set while recording.
+	recorder *recorder
 }
 
+// Lines to scroll per unit of scroll wheel or trackpad motion and pixels
+// per line.
+const (
+	SCROLL_LINES       = 3
+	SCROLL_LINE_HEIGHT = 16
+	// Zoom multiplies by this per unit of ctrl-scrolling.
+	ZOOM_STEP = 1.1
+)
+
+// NewWindow returns a Window that will open on the glfw Platform.
 func NewWindow(width int, height int) *Window {
-	c := content.NewFrame()
-	return &Window{uint32(width), uint32(height), c, Mousepointer{0, 0, 0}, 0.0, 0.0,
-		float32(width), float32(height), 0, c}
+	return NewWindowOn(Glfw{}, width, height)
 }
 
-func (window *Window) RunMessageLoop(w *glfw.Window, program *gl.Program) {
-	gl.GetError()
-	for !w.ShouldClose() {
-		// TODO(rjkroege): full generality: provide the transform to bring the Frame into
-		// Window coordinates and the width and height.
-		window.fw, window.fh = window.frame.Draw(window.x, window.y, float32(window.width), float32(window.height), program)
-		w.SwapBuffers()
-		glfw.PollEvents()
+// NewWindowOn returns a Window that will open on Platform p.
+func NewWindowOn(p Platform, width int, height int) *Window {
+	c := content.NewFrame()
+	return &Window{
+		width:    uint32(width),
+		height:   uint32(height),
+		frame:    c,
+		viewport: NewViewport(float32(width), float32(height)),
+		ev:       c,
+		queue:    content.NewEventQueue(),
+		platform: p,
 	}
 }
 
-// Based on https://raw.github.com/go-gl/examples/master/glfw/simplewindow
-func (window *Window) Open() {
-	glfw.WindowHint(glfw.ContextVersionMajor, 4)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
-	glfw.WindowHint(glfw.OpenglForwardCompatible, glfw.True)
-	glfw.WindowHint(glfw.OpenglProfile, glfw.OpenglCoreProfile)
+// Events returns the channel of Events from the Surface in Surface
+// coordinates. An application that receives them here instead of calling
+// Step passes them to HandleEvent and then calls Render.
+func (window *Window) Events() <-chan content.Event {
+	return window.queue.Events()
+}
+
+// SetDoubleClick sets the longest time and the largest distance in Window
+// pixels between presses that make up a multiple click. Zero values restore
+// the defaults.
+func (window *Window) SetDoubleClick(interval time.Duration, slop float32) {
+	window.clicks.Interval = interval
+	window.clicks.Slop = slop
+}
+
+// Frame returns the Frame that the Window shows.
+func (window *Window) Frame() *content.Frame {
+	return window.frame
+}
+
+// Surface returns the Surface made by Create or nil.
+func (window *Window) Surface() Surface {
+	return window.surface
+}
 
+// Create makes the Window's Surface on its Platform.
+func (window *Window) Create() error {
 	// TODO: what's go style here? How do you get clang-format for go?
 	// TODO(rjkroege): sizes should be uints
-	glfwWindow, err := glfw.CreateWindow(int(window.width), int(window.height), "Testing", nil, nil)
-
+	s, err := window.platform.NewSurface(int(window.width), int(window.height), "Testing")
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	s.SetEventSink(window.queue.Post)
+	window.surface = s
+	return nil
+}
 
-	defer glfwWindow.Destroy()
-
-	glfwWindow.MakeContextCurrent()
-
-	// Apparantly, this enables vsync?
-	glfw.SwapInterval(1)
-
-	gl.Init()
-
-	glfwWindow.SetSizeCallback(func(_ *glfw.Window, w, h int) {
-		window.onResize(w, h)
-	})
-
-	glfwWindow.SetMouseButtonCallback(func(_ *glfw.Window, button glfw.MouseButton, action glfw.Action, _ glfw.ModifierKey) {
-		window.onMouseBtn(button, action)
-	})
+// Open creates the Window's Surface if Create hasn't and runs the message
+// loop until the Surface is closed.
+func (window *Window) Open() {
+	if window.surface == nil {
+		if err := window.Create(); err != nil {
+			log.Panic(err)
+		}
+	}
+	defer window.surface.Destroy()
 
-	// TODO(rjkroege): Figure out how these events should be dealt with.
-	// glfw.SetMouseWheelCallback(func(d int) {
-	// 	window.onMouseWheel(d)
-	// })
+	for !window.surface.ShouldClose() {
+		window.Step()
+	}
+}
 
-	// glfw.SetKeyCallback(func(k, s int) {
-	// 	window.onKey(k, s)
-	// })
+// Step handles the events that have arrived without waiting for more and
+// then renders the Frame.
+func (window *Window) Step() {
+	window.surface.PollEvents()
+	for _, ev := range window.queue.Drain() {
+		window.HandleEvent(ev)
+	}
+	window.Render()
+}
 
-	// glfw.SetCharCallback(func(k, s int) {
-	// 	window.onChar(k, s)
-	// })
+// Play feeds the Events of s, in Surface coordinates, through the
+// Window's event queue one at a time and then renders the Frame if the
+// Window has a Surface.
+func (window *Window) Play(s *content.Script) {
+	for _, ev := range s.Events() {
+		window.queue.Post(ev)
+		for _, ev := range window.queue.Drain() {
+			window.HandleEvent(ev)
+		}
+	}
+	if window.surface != nil {
+		window.Render()
+	}
+}
 
-	// TODO(vollick): Passing around one program like this is a stopgap. We
-	// should really be initializing our shader library here.
-	program := graphics.CreateDefaultShaders()
-	defer program.Delete()
+// Render draws the Frame through the viewport and presents it on the
+// Surface.
+func (window *Window) Render() {
+	window.render()
+}
 
-	glfwWindow.SetCursorPositionCallback(func(_ *glfw.Window, x, y float64) {
-		window.onMousePos(int(x), int(y))
-	})
+// render renders the Frame and returns its DisplayList.
+func (window *Window) render() *graphics.DisplayList {
+	dl := window.frame.DisplayList(window.viewport.Transform())
+	window.viewport.SetContentSize(window.frame.Extent())
+	if window.recorder != nil {
+		window.recorder.frame(dl)
+	}
+	window.surface.Present(dl)
+	return dl
+}
 
-	window.RunMessageLoop(glfwWindow, &program)
+// HandleEvent performs the Window's handling of Surface Event ev: it
+// delivers ev to the Frame in Frame coordinates and then performs the
+// default action unless the Frame prevented it.
+func (window *Window) HandleEvent(ev content.Event) {
+	if window.recorder != nil {
+		window.recorder.event(ev)
+	}
+	switch ev.Type {
+	case content.EVENT_RESIZE:
+		window.onResize(ev.Width, ev.Height)
+	case content.EVENT_MOUSEDOWN:
+		window.onMouseBtn(ev.Button, 1, ev.Modifiers, ev.Time)
+	case content.EVENT_MOUSEUP:
+		window.onMouseBtn(ev.Button, 0, ev.Modifiers, ev.Time)
+	case content.EVENT_MOUSEMOVE:
+		window.onMousePos(ev.Point.X, ev.Point.Y, ev.Time)
+	case content.EVENT_WHEEL:
+		window.onScroll(ev.DX, ev.DY, ev.Time)
+	case content.EVENT_KEYDOWN:
+		state := uint32(1)
+		if ev.Repeat {
+			state = 2
+		}
+		window.onKey(ev.Key, state, ev.Modifiers)
+	case content.EVENT_KEYUP:
+		window.onKey(ev.Key, 0, ev.Modifiers)
+	case content.EVENT_TEXTINPUT:
+		window.onChar(ev.Text)
+	case content.EVENT_MOUSEENTER, content.EVENT_MOUSELEAVE:
+		// Where the pointer was last seen.
+		content.Deliver(window.ev, window.mouseEvent(ev.Type, ev.Time))
+	case content.EVENT_FOCUS, content.EVENT_BLUR:
+		content.Deliver(window.ev, ev)
+	}
 }
 
 func (window *Window) onResize(w, h int) {
 	window.width = uint32(w)
 	window.height = uint32(h)
+	window.viewport.Resize(float32(w), float32(h))
 	log.Printf("Resize %d %d", window.width, window.height)
 }
 
-func (window *Window) onMouseBtn(button glfw.MouseButton, action glfw.Action) {
-	state := uint32(action)
-	if button < 0 || button > 31 || state < 0 || state > 1 {
-		log.Fatal("button/state values from glfw are silly: ", button, state)
+// onMouseBtn delivers a press (state 1) or release (state 0) of button at
+// time t. mods is an or of content.MOD_ values.
+func (window *Window) onMouseBtn(b, state, mods uint32, t time.Duration) {
+	if b > 31 || state > 1 {
+		log.Fatal("button/state values from the platform are silly: ", b, state)
 	}
 
-	b := uint32(button)
+	window.mods = mods
 
 	// log.Printf("onMouseButton. state = %d, button = %d", state, b)
-	p := window.mousePositionInFrame()
 	if state == 1 {
 		window.pointer.buttonmask |= 1 << b
-		window.ev.Mousedown(p, b, window.pointer.buttonmask)
+		ev := window.mouseEvent(content.EVENT_MOUSEDOWN, t)
+		ev.Button = b
+		ev.ClickCount = window.clicks.Press(b, graphics.Pointf{window.pointer.x, window.pointer.y}, t)
+		r := content.Deliver(window.ev, ev)
+		// The default action of the middle button is to pan.
+		if 1<<b == content.MOUSE_BUTTON_MIDDLE && r != content.EVD_PREVDEF {
+			window.pointer.panning = true
+		}
 	} else {
 		window.pointer.buttonmask &= ^(1 << b)
-		window.ev.Mouseup(p, b, window.pointer.buttonmask)
+		ev := window.mouseEvent(content.EVENT_MOUSEUP, t)
+		ev.Button = b
+		ev.ClickCount = window.clicks.Release(b)
+		content.Deliver(window.ev, ev)
+		if 1<<b == content.MOUSE_BUTTON_MIDDLE {
+			window.pointer.panning = false
+		}
 	}
 }
 
 // Get the position of the mouse in the coordinates of the frame.
-// The sub is right for y-axis but since we can't scroll x-axis.
-func (w *Window) mousePositionInFrame() image.Point {
-	p := image.Pt(w.pointer.x, w.pointer.y)
-	p = p.Sub(image.Pt(int(w.x), int(w.y)))
-	return p
+func (w *Window) mousePositionInFrame() graphics.Pointf {
+	return w.viewport.ToContent(graphics.Pointf{w.pointer.x, w.pointer.y})
+}
+
+// mouseEvent returns an Event of type typ with the state of the mouse at
+// time t for the Frame.
+func (w *Window) mouseEvent(typ content.EventType, t time.Duration) content.Event {
+	return content.Event{
+		Type:      typ,
+		Point:     w.mousePositionInFrame(),
+		Buttons:   w.pointer.buttonmask,
+		Modifiers: w.mods,
+		Time:      t,
+	}
 }
 
 // Mini-essay on the way of scrolling. In scrolling, there are two
@@ -175,53 +291,63 @@ func (w *Window) mousePositionInFrame() image.Point {
 // The principle here is to handle the event as "close" to where it
 // arrives as possible. In particular: scrolling will happen in the
 // browser.
-func (window *Window) onMouseWheel(absolute_displacement int) {
-	log.Printf("mouse wheel abs: %d\n", absolute_displacement)
-
-	// TODO(rjkroege): displacement is stupid because of glfw issue. Fix.
-	// delta is not a delta. I am instead interested in the difference.
-	delta := absolute_displacement - window.previous_absolute_displacement
-	window.previous_absolute_displacement = absolute_displacement
-	// log.Printf("mouse wheel delta: %d\n", delta)
-
-	dx := float32(delta)
-	// Scrolling is currently only in one dimension because of glfw limitation.
-	// TODO(rjkroege): enable two-dimensional scrolling.
-
-	p := window.mousePositionInFrame()
-	if window.ev.Wheel(p, window.pointer.buttonmask, dx, 0, 0) != content.EVD_PREVDEF {
-		// window.x = content.Max(window.width - window.fw, window.x + float32(delta.x))
-		// Consider putting Max in some kind of base-like class.
-		window.y = graphics.MaxF(float32(window.height)-window.fh, window.y+dx)
-
-		// Can't scroll before the start of the content area (need x limit in the future.)
-		if window.y > 0.0 {
-			window.y = 0.0
-		}
-
-		log.Printf("window.y is max of %f %f\n", float32(window.height)-window.fh, window.y+float32(delta))
-
-		if window.y >= 0.0 {
-			log.Print("i have the scrolling sign backwards")
-		}
+//
+// dx, dy follow the DOM convention: deltas in pixels, positive to scroll
+// down and right.
+func (window *Window) onScroll(dx, dy float32, t time.Duration) {
+	ev := window.mouseEvent(content.EVENT_WHEEL, t)
+	ev.DX, ev.DY = dx, dy
+	if content.Deliver(window.ev, ev) == content.EVD_PREVDEF {
+		return
+	}
+	if window.mods&content.MOD_CONTROL != 0 {
+		pt := graphics.Pointf{window.pointer.x, window.pointer.y}
+		steps := -dy / (SCROLL_LINES * SCROLL_LINE_HEIGHT)
+		window.viewport.ZoomAbout(pt, float32(math.Pow(ZOOM_STEP, float64(steps))))
+	} else {
+		window.viewport.PanBy(-dx, -dy)
 	}
 }
 
-// TODO(rjkroege): Add support for delivering of key events.
-func (window *Window) onKey(key, state int) {
-	log.Printf("key: %d, %d\n", key, state)
+// onKey delivers a key press (state 1), auto-repeat (state 2) or release
+// (state 0) to the Frame. mods is an or of content.MOD_ values.
+func (window *Window) onKey(key content.Key, state, mods uint32) {
+	window.mods = mods
+	if state == 0 {
+		window.ev.Keyup(key, mods)
+		return
+	}
+	if window.ev.Keydown(key, mods, state == 2) == content.EVD_PREVDEF {
+		return
+	}
+
+	// Default actions: keyboard scrolling.
+	page := float32(window.height) * 0.9
+	switch key {
+	case content.KEY_PAGE_UP:
+		window.viewport.PanBy(0, page)
+	case content.KEY_PAGE_DOWN:
+		window.viewport.PanBy(0, -page)
+	case content.KEY_HOME:
+		window.viewport.PanBy(0, -window.viewport.Origin().Y)
+	case content.KEY_END:
+		window.viewport.PanBy(0, -window.viewport.ch*window.viewport.Zoom())
+	}
 }
 
-func (window *Window) onChar(key, state int) {
-	log.Printf("char: %d, %d\n", key, state)
+func (window *Window) onChar(r rune) {
+	window.ev.Textinput(r)
 }
 
-func (window *Window) onMousePos(x, y int) {
+func (window *Window) onMousePos(x, y float32, t time.Duration) {
+	dx := x - window.pointer.x
+	dy := y - window.pointer.y
 	window.pointer.x = x
 	window.pointer.y = y
 
-	p := window.mousePositionInFrame()
-
 	// TODO(rjkroege): filter/collapse/schedule the events as desirable.
-	window.ev.Mousemove(p, window.pointer.buttonmask)
+	r := content.Deliver(window.ev, window.mouseEvent(content.EVENT_MOUSEMOVE, t))
+	if window.pointer.panning && r != content.EVD_PREVDEF {
+		window.viewport.PanBy(dx, dy)
+	}
 }
@@ -16,29 +16,77 @@ package window
 
 import (
 	"github.com/google/gojiraw/content"
+	"github.com/google/gojiraw/graphics"
 	"github.com/rjkroege/wikitools/testhelpers"
-	"image"
 	"testing"
+	"time"
 )
 
 // Mock event handler.
 type mockeventhandler struct {
+	// Returned from the handlers.
+	result uint32
+
+	// The keys pressed and text entered.
+	keys []content.Key
+	text []rune
+
+	// The wheel deltas.
+	wheel [][2]float32
+
+	// The mouse button events.
+	mouse []content.MouseEvent
+
+	// How many times the pointer left and the window focus changes.
+	left  int
+	focus []bool
 }
 
-func (f *mockeventhandler) Mousedown(pt image.Point, button, buttons uint32) uint32 {
-	return content.EVD_NON
+func (f *mockeventhandler) Mousedown(ev content.MouseEvent) uint32 {
+	f.mouse = append(f.mouse, ev)
+	return f.result
 }
 
-func (f *mockeventhandler) Mouseup(pt image.Point, button, buttons uint32) uint32 {
-	return content.EVD_NON
+func (f *mockeventhandler) Mouseup(ev content.MouseEvent) uint32 {
+	f.mouse = append(f.mouse, ev)
+	return f.result
 }
 
-func (f *mockeventhandler) Mousemove(pt image.Point, buttons uint32) uint32 {
-	return content.EVD_NON
+func (f *mockeventhandler) Mousemove(ev content.MouseEvent) uint32 {
+	return f.result
 }
 
-func (f *mockeventhandler) Wheel(pt image.Point, buttons uint32, dx, dy, dz float32) uint32 {
-	return content.EVD_NON
+func (f *mockeventhandler) Wheel(ev content.MouseEvent, dx, dy, dz float32) uint32 {
+	f.wheel = append(f.wheel, [2]float32{dx, dy})
+	return f.result
+}
+
+func (f *mockeventhandler) Keydown(key content.Key, mods uint32, repeat bool) uint32 {
+	f.keys = append(f.keys, key)
+	return f.result
+}
+
+func (f *mockeventhandler) Keyup(key content.Key, mods uint32) uint32 {
+	return f.result
+}
+
+func (f *mockeventhandler) Textinput(r rune) uint32 {
+	f.text = append(f.text, r)
+	return f.result
+}
+
+func (f *mockeventhandler) Mouseenter(ev content.MouseEvent) uint32 {
+	return f.result
+}
+
+func (f *mockeventhandler) Mouseleave(ev content.MouseEvent) uint32 {
+	f.left++
+	return f.result
+}
+
+func (f *mockeventhandler) Windowfocus(focused bool) uint32 {
+	f.focus = append(f.focus, focused)
+	return f.result
 }
 
 func Test_windowCreation(t *testing.T) {
@@ -60,19 +108,19 @@ func Test_onMouseBtn(t *testing.T) {
 	testhelpers.AssertInt(t, 2, content.MOUSE_BUTTON_MIDDLE)
 	testhelpers.AssertInt(t, 4, content.MOUSE_BUTTON_RIGHT)
 
-	w.onMouseBtn(0, 1)
+	w.onMouseBtn(0, 1, 0, 0)
 	testhelpers.AssertInt(t, int(w.pointer.buttonmask), content.MOUSE_BUTTON_LEFT)
-	w.onMouseBtn(0, 0)
+	w.onMouseBtn(0, 0, 0, 0)
 	testhelpers.AssertInt(t, int(w.pointer.buttonmask), content.MOUSE_BUTTON_NONE)
 
-	w.onMouseBtn(1, 1)
+	w.onMouseBtn(1, 1, 0, 0)
 	testhelpers.AssertInt(t, int(w.pointer.buttonmask), content.MOUSE_BUTTON_MIDDLE)
-	w.onMouseBtn(1, 0)
+	w.onMouseBtn(1, 0, 0, 0)
 	testhelpers.AssertInt(t, int(w.pointer.buttonmask), content.MOUSE_BUTTON_NONE)
 
-	w.onMouseBtn(2, 1)
+	w.onMouseBtn(2, 1, 0, 0)
 	testhelpers.AssertInt(t, int(w.pointer.buttonmask), content.MOUSE_BUTTON_RIGHT)
-	w.onMouseBtn(2, 0)
+	w.onMouseBtn(2, 0, 0, 0)
 	testhelpers.AssertInt(t, int(w.pointer.buttonmask), content.MOUSE_BUTTON_NONE)
 }
 
@@ -80,7 +128,182 @@ func Test_onMousePos(t *testing.T) {
 	w := new(Window)
 	w.ev = new(mockeventhandler)
 
-	w.onMousePos(1, 3)
+	w.onMousePos(1, 3, 0)
 	testhelpers.AssertInt(t, 1, int(w.pointer.x))
 	testhelpers.AssertInt(t, 3, int(w.pointer.y))
 }
+
+// newScrollableWindow returns a 100x100 Window showing 1000x1000 of content.
+func newScrollableWindow() *Window {
+	w := &Window{width: 100, height: 100, viewport: NewViewport(100, 100)}
+	w.viewport.SetContentSize(1000, 1000)
+	return w
+}
+
+func Test_middleButtonPan(t *testing.T) {
+	w := newScrollableWindow()
+	m := new(mockeventhandler)
+	w.ev = m
+
+	w.onMousePos(50, 50, 0)
+	w.onMouseBtn(1, 1, 0, 0)
+	w.onMousePos(40, 30, 0)
+	testhelpers.AssertInt(t, -10, int(w.viewport.origin.X))
+	testhelpers.AssertInt(t, -20, int(w.viewport.origin.Y))
+
+	// Can't pan before the start of the content.
+	w.onMousePos(80, 80, 0)
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.X))
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.Y))
+	w.onMouseBtn(1, 0, 0, 0)
+
+	// The Frame can prevent the pan.
+	m.result = content.EVD_PREVDEF
+	w.onMouseBtn(1, 1, 0, 0)
+	w.onMousePos(10, 10, 0)
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.X))
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.Y))
+}
+
+func Test_onKey(t *testing.T) {
+	w := newScrollableWindow()
+	m := new(mockeventhandler)
+	w.ev = m
+
+	w.onKey(content.KEY_A, 1, content.MOD_SHIFT)
+	w.onChar('A')
+	w.onKey(content.KEY_A, 0, content.MOD_SHIFT)
+	testhelpers.AssertInt(t, content.MOD_SHIFT, int(w.mods))
+	if len(m.keys) != 1 || m.keys[0] != content.KEY_A || len(m.text) != 1 || m.text[0] != 'A' {
+		t.Errorf("bad key delivery %v %v", m.keys, m.text)
+	}
+
+	w.onKey(content.KEY_END, 1, 0)
+	testhelpers.AssertInt(t, -900, int(w.viewport.origin.Y))
+	w.onKey(content.KEY_HOME, 1, 0)
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.Y))
+
+	m.result = content.EVD_PREVDEF
+	w.onKey(content.KEY_PAGE_DOWN, 1, 0)
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.Y))
+}
+
+func Test_onScroll(t *testing.T) {
+	w := newScrollableWindow()
+	m := new(mockeventhandler)
+	w.ev = m
+
+	line := float32(SCROLL_LINES * SCROLL_LINE_HEIGHT)
+
+	// Scrolling down moves the content up.
+	w.onScroll(0, line, 0)
+	testhelpers.AssertInt(t, -SCROLL_LINES*SCROLL_LINE_HEIGHT, int(w.viewport.origin.Y))
+	if len(m.wheel) != 1 || m.wheel[0] != [2]float32{0, SCROLL_LINES * SCROLL_LINE_HEIGHT} {
+		t.Errorf("bad wheel deltas %v", m.wheel)
+	}
+
+	// Two dimensions, and no scrolling before the start.
+	w.onScroll(line, -2*line, 0)
+	testhelpers.AssertInt(t, -SCROLL_LINES*SCROLL_LINE_HEIGHT, int(w.viewport.origin.X))
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.Y))
+
+	m.result = content.EVD_PREVDEF
+	w.onScroll(0, line, 0)
+	testhelpers.AssertInt(t, 0, int(w.viewport.origin.Y))
+}
+
+func Test_zoomMapsMouse(t *testing.T) {
+	w := newScrollableWindow()
+	m := new(mockeventhandler)
+	w.ev = m
+
+	w.onMousePos(50, 50, 0)
+	w.onKey(content.KEY_LEFT_CONTROL, 1, content.MOD_CONTROL)
+	w.onScroll(0, -SCROLL_LINES*SCROLL_LINE_HEIGHT, 0)
+	if z := w.viewport.Zoom(); z != ZOOM_STEP {
+		t.Errorf("bad zoom %f", z)
+	}
+	// The content under the pointer stays put.
+	if p := w.mousePositionInFrame(); !near(p, graphics.Pointf{50, 50}) {
+		t.Errorf("zoom moved the content under the pointer to %v", p)
+	}
+	w.onMousePos(61, 61, 0)
+	if p := w.mousePositionInFrame(); !near(p, graphics.Pointf{60, 60}) {
+		t.Errorf("mouse not mapped through the zoom: %v", p)
+	}
+}
+
+func near(p, q graphics.Pointf) bool {
+	d := p.Sub(q)
+	return graphics.AbsF(d.X) < 1e-3 && graphics.AbsF(d.Y) < 1e-3
+}
+
+func Test_clickCount(t *testing.T) {
+	w := newScrollableWindow()
+	m := new(mockeventhandler)
+	w.ev = m
+	ms := time.Millisecond
+
+	click := func(x, y float32, at time.Duration) int {
+		w.onMousePos(x, y, at)
+		w.onMouseBtn(0, 1, content.MOD_SHIFT, at)
+		w.onMouseBtn(0, 0, content.MOD_SHIFT, at+50*ms)
+		return m.mouse[len(m.mouse)-1].ClickCount
+	}
+	testhelpers.AssertInt(t, 1, click(10, 10, 0))
+	testhelpers.AssertInt(t, 2, click(12.5, 11, 300*ms))
+	testhelpers.AssertInt(t, 3, click(12.5, 11, 600*ms))
+	// Too late.
+	testhelpers.AssertInt(t, 1, click(12.5, 11, 1200*ms))
+	// Too far.
+	testhelpers.AssertInt(t, 1, click(30, 11, 1300*ms))
+
+	// The press and the release both carry the count, the modifiers, the
+	// time and the unrounded position.
+	down, up := m.mouse[len(m.mouse)-2], m.mouse[len(m.mouse)-1]
+	if down.ClickCount != 1 || down.Modifiers != content.MOD_SHIFT || down.Time != 1300*ms || down.Buttons != content.MOUSE_BUTTON_LEFT {
+		t.Errorf("bad mousedown %+v", down)
+	}
+	if up.Time != 1350*ms || up.Buttons != 0 || !near(up.Point, graphics.Pointf{30, 11}) {
+		t.Errorf("bad mouseup %+v", up)
+	}
+
+	// Another button starts again.
+	w.onMouseBtn(2, 1, 0, 1400*ms)
+	testhelpers.AssertInt(t, 1, m.mouse[len(m.mouse)-1].ClickCount)
+	w.onMouseBtn(2, 0, 0, 1400*ms)
+
+	// A longer interval and larger slop.
+	w.SetDoubleClick(time.Second, 30)
+	testhelpers.AssertInt(t, 1, click(0, 0, 2000*ms))
+	testhelpers.AssertInt(t, 2, click(25, 25, 2900*ms))
+}
+
+func Test_HandleEvent(t *testing.T) {
+	w := newScrollableWindow()
+	m := new(mockeventhandler)
+	w.ev = m
+
+	w.HandleEvent(content.Event{Type: content.EVENT_MOUSEMOVE, Point: graphics.Pointf{20.5, 30}})
+	w.HandleEvent(content.Event{Type: content.EVENT_MOUSEDOWN, Button: 0, Modifiers: content.MOD_ALT, Time: time.Second})
+	w.HandleEvent(content.Event{Type: content.EVENT_MOUSEUP, Button: 0, Time: time.Second})
+	w.HandleEvent(content.Event{Type: content.EVENT_KEYDOWN, Key: content.KEY_B, Repeat: true})
+	w.HandleEvent(content.Event{Type: content.EVENT_TEXTINPUT, Text: 'b'})
+	w.HandleEvent(content.Event{Type: content.EVENT_RESIZE, Width: 200, Height: 150})
+	w.HandleEvent(content.Event{Type: content.EVENT_MOUSELEAVE})
+	w.HandleEvent(content.Event{Type: content.EVENT_BLUR})
+	w.HandleEvent(content.Event{Type: content.EVENT_FOCUS})
+
+	if len(m.mouse) != 2 || m.mouse[0].Point != (graphics.Pointf{20.5, 30}) || m.mouse[0].Modifiers != content.MOD_ALT || m.mouse[0].Time != time.Second {
+		t.Errorf("bad mouse delivery %+v", m.mouse)
+	}
+	if len(m.keys) != 1 || m.keys[0] != content.KEY_B || len(m.text) != 1 {
+		t.Errorf("bad key delivery %v %v", m.keys, m.text)
+	}
+	testhelpers.AssertInt(t, 1, m.left)
+	if len(m.focus) != 2 || m.focus[0] || !m.focus[1] {
+		t.Errorf("bad focus delivery %v", m.focus)
+	}
+	testhelpers.AssertInt(t, 200, int(w.width))
+	testhelpers.AssertInt(t, 150, int(w.height))
+}